@@ -0,0 +1,107 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sensitiveHeaders lists security-relevant headers that broad pattern rules
+// should not rename by accident.
+var sensitiveHeaders = []string{
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Origin",
+	"Authorization",
+	"Content-Security-Policy",
+	"Cookie",
+	"Permissions-Policy",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Referrer-Policy",
+	"Set-Cookie",
+	"Strict-Transport-Security",
+	"Www-Authenticate",
+	"X-Content-Type-Options",
+	"X-Frame-Options",
+	"X-Xss-Protection",
+}
+
+// Warning describes a configuration that is valid but probably unintended.
+type Warning struct {
+	// Field locates the list of the offending rule, such as "renameData" or
+	// "variants[beta].requestRenameData", as Validate does.
+	Field string
+	// Rule is the index of the offending rule in that list.
+	Rule    int
+	Message string
+	// Headers lists the sensitive headers affected by the rule, if any.
+	Headers []string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s[%d]: %s", w.Field, w.Rule, w.Message)
+}
+
+// Analyze reports configuration footguns that New accepts, such as wildcard
+// or regex rules broad enough to rename security-sensitive headers. All rules
+// are analyzed, once Renames and ExtendsFile are merged as in Validate. Rules
+// that fail validation are ignored here, New reports them.
+func (c *Config) Analyze() []Warning {
+	resolved, err := c.resolve()
+	if err != nil {
+		resolved = c
+	}
+	return resolved.analyze()
+}
+
+// analyze is Analyze on a resolved configuration.
+func (c *Config) analyze() []Warning {
+	warnings := analyzeRules("renameData", c.RenameData)
+	warnings = append(warnings, analyzeRules("requestRenameData", c.RequestRenameData)...)
+	names := make([]string, 0, len(c.Variants))
+	for name := range c.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		variant := c.Variants[name]
+		warnings = append(warnings, analyzeRules(fmt.Sprintf("variants[%s].renameData", name), variant.RenameData)...)
+		warnings = append(warnings, analyzeRules(fmt.Sprintf("variants[%s].requestRenameData", name), variant.RequestRenameData)...)
+	}
+	hosts := make([]string, 0, len(c.HostRules))
+	for host := range c.HostRules {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		warnings = append(warnings, analyzeRules(fmt.Sprintf("hostRules[%s]", host), c.HostRules[host])...)
+	}
+	return warnings
+}
+
+// analyzeRules reports the footguns of one list of rules, located under field.
+func analyzeRules(field string, renames []RenameRule) []Warning {
+	var warnings []Warning
+	for i, rename := range renames {
+		rule, err := compileRule(rename)
+		if err != nil || !rule.isPattern() {
+			continue
+		}
+
+		var affected []string
+		for _, name := range sensitiveHeaders {
+			if _, ok := rule.newName(name); ok {
+				affected = append(affected, name)
+			}
+		}
+		if len(affected) > 0 {
+			warnings = append(warnings, Warning{
+				Field:   field,
+				Rule:    i,
+				Message: fmt.Sprintf("pattern %q matches security-sensitive headers: %s", rename.ExistingHeaderName, strings.Join(affected, ", ")),
+				Headers: affected,
+			})
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,89 @@
+package traefik_header_rename_plugin
+
+import (
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		desc        string
+		renames     []RenameRule
+		expWarnings int
+		expHeaders  []string
+	}{
+		{
+			desc: "Should not warn about exact names",
+			renames: []RenameRule{
+				{ExistingHeaderName: "Authorization", NewHeaderName: "X-Authorization"},
+			},
+		},
+		{
+			desc: "Should not warn about narrow patterns",
+			renames: []RenameRule{
+				{ExistingHeaderName: "X-Legacy-*", NewHeaderName: "X-$1"},
+			},
+		},
+		{
+			desc: "Should enumerate sensitive headers swept up by a broad pattern",
+			renames: []RenameRule{
+				{ExistingHeaderName: "X-*", NewHeaderName: "X-Legacy-$1"},
+			},
+			expWarnings: 1,
+			expHeaders:  []string{"X-Content-Type-Options", "X-Frame-Options", "X-Xss-Protection"},
+		},
+		{
+			desc: "Should enumerate sensitive headers swept up by a broad regex",
+			renames: []RenameRule{
+				{ExistingHeaderName: "^X-Legacy-.*$", NewHeaderName: "X-New", Regex: true},
+				{ExistingHeaderName: "^.*$", NewHeaderName: "X-Legacy-$0", Regex: true},
+			},
+			expWarnings: 1,
+			expHeaders:  sensitiveHeaders,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: test.renames,
+			}
+
+			warnings := config.Analyze()
+			if len(warnings) != test.expWarnings {
+				t.Fatalf("expected %d warnings, got %v", test.expWarnings, warnings)
+			}
+			if len(warnings) > 0 && !testEq(warnings[0].Headers, test.expHeaders) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expHeaders, warnings[0].Headers)
+			}
+		})
+	}
+}
+
+func TestAnalyzeAllRules(t *testing.T) {
+	config := &Config{
+		Renames: map[string]string{"X-*": "X-Legacy-$1"},
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "Cook*", NewHeaderName: "X-$1"},
+		},
+		Variants: map[string]RuleVariant{
+			"b": {RequestRenameData: []RenameRule{{ExistingHeaderName: "Author*", NewHeaderName: "X-$1"}}},
+		},
+		HostRules: map[string][]RenameRule{
+			"example.com": {{ExistingHeaderName: "Set-*", NewHeaderName: "X-$1"}},
+		},
+	}
+
+	var warnings []string
+	for _, warning := range config.Analyze() {
+		warnings = append(warnings, warning.String())
+	}
+	expected := []string{
+		`renameData[0]: pattern "X-*" matches security-sensitive headers: X-Content-Type-Options, X-Frame-Options, X-Xss-Protection`,
+		`requestRenameData[0]: pattern "Cook*" matches security-sensitive headers: Cookie`,
+		`variants[b].requestRenameData[0]: pattern "Author*" matches security-sensitive headers: Authorization`,
+		`hostRules[example.com][0]: pattern "Set-*" matches security-sensitive headers: Set-Cookie`,
+	}
+	if !testEq(warnings, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, warnings)
+	}
+}
@@ -0,0 +1,34 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+)
+
+// apply applies the rules to header and records the renames in the audit
+// headers and webhook, if configured. status is the response status code, 0
+// for requests.
+func (r *renameHeaders) apply(rules *ruleIndex, header http.Header, req *http.Request, status int) ([]RenamedHeader, error) {
+	var original http.Header
+	if r.originalHeaderPrefix != "" {
+		original = header.Clone()
+	}
+
+	renamed, err := rules.applyLogged(header, req, status, r.decisionLog(status))
+	if err != nil {
+		return renamed, err
+	}
+	if err := r.sanitizeRenamed(header, renamed); err != nil {
+		return renamed, err
+	}
+
+	r.auditWebhook.renamed(req, status, renamed)
+	for _, rename := range renamed {
+		if r.renamedFromHeader != "" {
+			header.Add(r.renamedFromHeader, rename.From)
+		}
+		if values, ok := original[rename.From]; ok {
+			header[http.CanonicalHeaderKey(r.originalHeaderPrefix+rename.From)] = values
+		}
+	}
+	return renamed, nil
+}
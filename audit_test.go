@@ -0,0 +1,48 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuditHeaders(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Token", NewHeaderName: "X-Token"},
+			{ExistingHeaderName: "X-Token", NewHeaderName: "X-Auth-Token"},
+			{ExistingHeaderName: "Missing", NewHeaderName: "Other"},
+		},
+		RenamedFromHeader:    "X-Renamed-From",
+		OriginalHeaderPrefix: "X-Original-",
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Legacy-Token", "secret")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expRespHeader := map[string][]string{
+		"X-Auth-Token":              {"secret"},
+		"X-Renamed-From":            {"X-Legacy-Token", "X-Token"},
+		"X-Original-X-Legacy-Token": {"secret"},
+		"X-Original-X-Token":        nil,
+		"X-Original-Missing":        nil,
+	}
+	for k, expected := range expRespHeader {
+		values := recorder.Result().Header[k]
+
+		if !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CoalesceRule writes the first non-empty of several headers to a target.
+type CoalesceRule struct {
+	// Sources lists the candidate headers, in order of preference. List the
+	// target first to keep a value already set.
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+	// RemoveSources removes all source headers other than the target.
+	RemoveSources bool `json:"removeSources"`
+}
+
+// compileCoalesceRules validates the rules and canonicalizes their header names.
+func compileCoalesceRules(rules []CoalesceRule) ([]CoalesceRule, error) {
+	compiled := make([]CoalesceRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Target == "" || len(rule.Sources) == 0 {
+			return nil, fmt.Errorf("coalesce rule %d: sources and target cannot be empty", i)
+		}
+		if strings.HasPrefix(rule.Target, ":") {
+			return nil, fmt.Errorf("coalesce rule %d: target %q is a pseudo-header", i, rule.Target)
+		}
+		rule.Target = http.CanonicalHeaderKey(rule.Target)
+
+		sources := make([]string, len(rule.Sources))
+		for j, source := range rule.Sources {
+			if source == "" {
+				return nil, fmt.Errorf("coalesce rule %d: source names cannot be empty", i)
+			}
+			sources[j] = http.CanonicalHeaderKey(source)
+		}
+		rule.Sources = sources
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// coalesceHeaders applies the rules to header in order. The target is left
+// untouched when no source has a non-empty value.
+func coalesceHeaders(header http.Header, rules []CoalesceRule) {
+	for _, rule := range rules {
+		for _, source := range rule.Sources {
+			if values := nonEmpty(header[source]); len(values) > 0 {
+				header[rule.Target] = values
+				break
+			}
+		}
+		if rule.RemoveSources {
+			for _, source := range rule.Sources {
+				if source != rule.Target {
+					delete(header, source)
+				}
+			}
+		}
+	}
+}
+
+// nonEmpty returns a copy of the values that are not blank.
+func nonEmpty(values []string) []string {
+	var kept []string
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,112 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestCoalesce(t *testing.T) {
+	tests := []struct {
+		desc      string
+		rule      CoalesceRule
+		reqHeader http.Header
+		expHeader http.Header
+	}{
+		{
+			desc:      "Should write the first non-empty source",
+			rule:      CoalesceRule{Sources: []string{"x-client-ip", "X-Forwarded-Client", "X-Legacy-Ip"}, Target: "x-real-ip"},
+			reqHeader: http.Header{"X-Client-Ip": {" "}, "X-Forwarded-Client": {"198.51.100.1"}, "X-Legacy-Ip": {"198.51.100.2"}},
+			expHeader: http.Header{"X-Real-Ip": {"198.51.100.1"}, "X-Client-Ip": {" "}, "X-Legacy-Ip": {"198.51.100.2"}},
+		},
+		{
+			desc:      "Should remove the sources",
+			rule:      CoalesceRule{Sources: []string{"X-Client-Ip", "X-Legacy-Ip"}, Target: "X-Real-Ip", RemoveSources: true},
+			reqHeader: http.Header{"X-Legacy-Ip": {"198.51.100.2"}},
+			expHeader: http.Header{"X-Real-Ip": {"198.51.100.2"}, "X-Legacy-Ip": nil},
+		},
+		{
+			desc:      "Should keep the target when listed first",
+			rule:      CoalesceRule{Sources: []string{"X-Real-Ip", "X-Legacy-Ip"}, Target: "X-Real-Ip", RemoveSources: true},
+			reqHeader: http.Header{"X-Real-Ip": {"198.51.100.1"}, "X-Legacy-Ip": {"198.51.100.2"}},
+			expHeader: http.Header{"X-Real-Ip": {"198.51.100.1"}, "X-Legacy-Ip": nil},
+		},
+		{
+			desc:      "Should leave the target alone without source",
+			rule:      CoalesceRule{Sources: []string{"X-Client-Ip"}, Target: "X-Real-Ip"},
+			reqHeader: http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			expHeader: http.Header{"X-Real-Ip": {"198.51.100.1"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+			}
+
+			config := &Config{RequestCoalesce: []CoalesceRule{test.rule}}
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = test.reqHeader
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			for k, expected := range test.expHeader {
+				if values := forwarded[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{{ExistingHeaderName: "X-Upstream-Trace", NewHeaderName: "X-B3-Trace"}},
+		Coalesce:   []CoalesceRule{{Sources: []string{"Traceparent", "X-B3-Trace"}, Target: "X-Trace"}},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Upstream-Trace", "abc")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expected := []string{"abc"}
+	if values := recorder.Header()["X-Trace"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+}
+
+func TestCompileCoalesceRulesErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		rules []CoalesceRule
+	}{
+		{desc: "Should reject a missing target", rules: []CoalesceRule{{Sources: []string{"X-Foo"}}}},
+		{desc: "Should reject missing sources", rules: []CoalesceRule{{Target: "X-Foo"}}},
+		{desc: "Should reject empty sources", rules: []CoalesceRule{{Sources: []string{""}, Target: "X-Foo"}}},
+		{desc: "Should reject pseudo-header targets", rules: []CoalesceRule{{Sources: []string{"X-Foo"}, Target: ":authority"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileCoalesceRules(test.rules); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
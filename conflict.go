@@ -0,0 +1,93 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Strategies for renaming into a header that already exists.
+const (
+	// ConflictOverwrite replaces the existing values, the default.
+	ConflictOverwrite = "overwrite"
+	// ConflictAppend adds the renamed values after the existing ones.
+	ConflictAppend = "append"
+	// ConflictSkip leaves both headers untouched.
+	ConflictSkip = "skip"
+	// ConflictFail fails the request with Config.ConflictStatus, 500 Internal
+	// Server Error by default.
+	ConflictFail = "fail"
+)
+
+// ConflictError reports a rename into an existing header by a rule failing on conflicts.
+type ConflictError struct {
+	Rule   string
+	Header string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("rename rule %s: header %s already exists", e.Rule, e.Header)
+}
+
+// existingValues returns the values already stored under newName, literally or
+// in canonical form, ignoring the header being renamed.
+func existingValues(header http.Header, name, newName string) []string {
+	var existing []string
+	if newName != name {
+		existing = append(existing, header[newName]...)
+	}
+	if canonical := http.CanonicalHeaderKey(newName); canonical != newName && canonical != name {
+		existing = append(existing, header[canonical]...)
+	}
+	return existing
+}
+
+// strictRules returns copies of the rules leaving conflicts to the default
+// strategy that fail on conflicts with different values instead.
+func strictRules(rules []*compiledRule) []*compiledRule {
+	strict := make([]*compiledRule, len(rules))
+	for i, rule := range rules {
+		strict[i] = rule
+		if rule.OnConflict == "" {
+			copied := *rule
+			copied.strict = true
+			strict[i] = &copied
+		}
+	}
+	return strict
+}
+
+// equalValues reports whether a and b hold the same values in the same order.
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// errorStatus returns the status of the response replacing one whose rules
+// failed with err.
+func (r *renameHeaders) errorStatus(err error) int {
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return r.conflictStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// fail replaces the response with an empty error response and discards the
+// body written afterwards.
+func (r *responseWriter) fail(status int) {
+	header := r.Header()
+	for name := range header {
+		delete(header, name)
+	}
+	r.headerWritten = true
+	r.discard = true
+	r.ResponseWriter.WriteHeader(status)
+}
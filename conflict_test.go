@@ -0,0 +1,244 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOnConflict(t *testing.T) {
+	tests := []struct {
+		desc          string
+		newName       string
+		onConflict    string
+		expStatus     int
+		expRespHeader http.Header
+	}{
+		{
+			desc:      "Should overwrite by default",
+			newName:   "Bar",
+			expStatus: http.StatusOK,
+			expRespHeader: map[string][]string{
+				"Bar": {"f1", "f2"},
+				"Foo": nil,
+			},
+		},
+		{
+			desc:       "Should overwrite existing values",
+			newName:    "Bar",
+			onConflict: ConflictOverwrite,
+			expStatus:  http.StatusOK,
+			expRespHeader: map[string][]string{
+				"Bar": {"f1", "f2"},
+				"Foo": nil,
+			},
+		},
+		{
+			desc:       "Should append after existing values",
+			newName:    "Bar",
+			onConflict: ConflictAppend,
+			expStatus:  http.StatusOK,
+			expRespHeader: map[string][]string{
+				"Bar": {"b1", "f1", "f2"},
+				"Foo": nil,
+			},
+		},
+		{
+			desc:       "Should merge the canonical form of a literal target",
+			newName:    "bar",
+			onConflict: ConflictAppend,
+			expStatus:  http.StatusOK,
+			expRespHeader: map[string][]string{
+				"bar": {"b1", "f1", "f2"},
+				"Bar": nil,
+				"Foo": nil,
+			},
+		},
+		{
+			desc:       "Should leave both headers when skipping",
+			newName:    "Bar",
+			onConflict: ConflictSkip,
+			expStatus:  http.StatusOK,
+			expRespHeader: map[string][]string{
+				"Bar": {"b1"},
+				"Foo": {"f1", "f2"},
+			},
+		},
+		{
+			desc:       "Should fail the response",
+			newName:    "Bar",
+			onConflict: ConflictFail,
+			expStatus:  http.StatusInternalServerError,
+			expRespHeader: map[string][]string{
+				"Bar": nil,
+				"Foo": nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "Foo", NewHeaderName: test.newName, OnConflict: test.onConflict},
+				},
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["Foo"] = []string{"f1", "f2"}
+				rw.Header()["Bar"] = []string{"b1"}
+				_, _ = rw.Write([]byte("body"))
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != test.expStatus {
+				t.Errorf("expected status %d, got %d", test.expStatus, recorder.Code)
+			}
+			if test.expStatus != http.StatusOK && recorder.Body.Len() != 0 {
+				t.Errorf("expected the body to be discarded, got %q", recorder.Body.String())
+			}
+			for k, expected := range test.expRespHeader {
+				values := recorder.Result().Header[k]
+
+				if !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestOnConflictFailRequest(t *testing.T) {
+	config := &Config{
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-User", NewHeaderName: "X-User", OnConflict: ConflictFail},
+		},
+	}
+
+	called := false
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Legacy-User", "alice")
+	req.Header.Set("X-User", "mallory")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if called {
+		t.Error("expected the request not to be forwarded")
+	}
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		desc           string
+		onConflict     string
+		conflictStatus int
+		barValues      []string
+		expStatus      int
+		expBar         []string
+	}{
+		{
+			desc:      "Should fail on a conflict with different values",
+			barValues: []string{"b1"},
+			expStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:           "Should fail with the configured status",
+			conflictStatus: http.StatusBadGateway,
+			barValues:      []string{"b1"},
+			expStatus:      http.StatusBadGateway,
+		},
+		{
+			desc:      "Should accept a conflict with the same values",
+			barValues: []string{"f1"},
+			expStatus: http.StatusOK,
+			expBar:    []string{"f1"},
+		},
+		{
+			desc:       "Should keep an explicit onConflict",
+			onConflict: ConflictAppend,
+			barValues:  []string{"b1"},
+			expStatus:  http.StatusOK,
+			expBar:     []string{"b1", "f1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "Foo", NewHeaderName: "Bar", OnConflict: test.onConflict},
+				},
+				Strict:         true,
+				ConflictStatus: test.conflictStatus,
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["Foo"] = []string{"f1"}
+				rw.Header()["Bar"] = test.barValues
+				_, _ = rw.Write([]byte("body"))
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != test.expStatus {
+				t.Errorf("expected status %d, got %d", test.expStatus, recorder.Code)
+			}
+			if values := recorder.Result().Header["Bar"]; !testEq(values, test.expBar) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expBar, values)
+			}
+		})
+	}
+}
+
+func TestStrictRequest(t *testing.T) {
+	config := &Config{
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-User", NewHeaderName: "X-User"},
+		},
+		Strict:         true,
+		ConflictStatus: http.StatusBadRequest,
+	}
+
+	handler, err := New(context.Background(), http.NotFoundHandler(), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Legacy-User", "alice")
+	req.Header.Set("X-User", "mallory")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
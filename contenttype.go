@@ -0,0 +1,56 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// contentTypes is a parsed list of media types such as "application/json",
+// where the subtype may be "*" to match any.
+type contentTypes []string
+
+// parseContentTypes validates and normalizes a list of media types.
+func parseContentTypes(values []string) (contentTypes, error) {
+	types := make(contentTypes, 0, len(values))
+	for _, value := range values {
+		mediaType, err := parseMediaType(value)
+		if err != nil {
+			return nil, fmt.Errorf("contentTypes: %w", err)
+		}
+		if typ, subtype, _ := strings.Cut(mediaType, "/"); typ == "*" && subtype != "*" {
+			return nil, fmt.Errorf("contentTypes: %q has a wildcard type but not subtype", value)
+		}
+		types = append(types, mediaType)
+	}
+	return types, nil
+}
+
+// parseMediaType returns the lower-case media type of a Content-Type value,
+// without parameters.
+func parseMediaType(value string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid media type %q: %w", value, err)
+	}
+	if !strings.Contains(mediaType, "/") {
+		return "", fmt.Errorf("invalid media type %q: missing subtype", value)
+	}
+	return mediaType, nil
+}
+
+// contains reports whether the Content-Type value matches one of the types.
+// Values that cannot be parsed match none.
+func (c contentTypes) contains(value string) bool {
+	mediaType, err := parseMediaType(value)
+	if err != nil {
+		return false
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+	for _, t := range c {
+		if t == mediaType || t == "*/*" || t == typ+"/*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentTypes(t *testing.T) {
+	tests := []struct {
+		desc        string
+		types       []string
+		contentType string
+		expRenamed  bool
+	}{
+		{desc: "Should rename on a matching media type", types: []string{"application/json"}, contentType: "application/json", expRenamed: true},
+		{desc: "Should ignore parameters and case", types: []string{"application/json"}, contentType: "Application/JSON; charset=utf-8", expRenamed: true},
+		{desc: "Should match a wildcard subtype", types: []string{"text/*"}, contentType: "text/plain", expRenamed: true},
+		{desc: "Should not rename on other media types", types: []string{"application/json"}, contentType: "text/html; charset=utf-8"},
+		{desc: "Should not rename without Content-Type", types: []string{"*/*"}},
+		{desc: "Should not rename on an invalid Content-Type", types: []string{"*/*"}, contentType: "json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{RenameData: []RenameRule{
+				{ExistingHeaderName: "Cache-Control", NewHeaderName: "X-Upstream-Cache-Control", ContentTypes: test.types},
+			}}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				if test.contentType != "" {
+					rw.Header().Set("Content-Type", test.contentType)
+				}
+				rw.Header().Set("Cache-Control", "no-store")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			renamed := recorder.Header().Get("X-Upstream-Cache-Control") != ""
+			if renamed != test.expRenamed {
+				t.Errorf("expected renamed: %t, got %t", test.expRenamed, renamed)
+			}
+		})
+	}
+}
+
+func TestParseContentTypesErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		types []string
+	}{
+		{desc: "Should reject a missing subtype", types: []string{"json"}},
+		{desc: "Should reject a wildcard type with a subtype", types: []string{"*/json"}},
+		{desc: "Should reject invalid media types", types: []string{"application/json; charset"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := parseContentTypes(test.types); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CookieRename renames one cookie in the Cookie request header and in
+// Set-Cookie response headers. Cookie names are case-sensitive.
+type CookieRename struct {
+	ExistingCookieName string `json:"existingCookieName"`
+	NewCookieName      string `json:"newCookieName"`
+}
+
+// cookieRenames maps existing cookie names to new ones.
+type cookieRenames map[string]string
+
+// compileCookieRenames validates the configured cookie renames.
+func compileCookieRenames(renames []CookieRename) (cookieRenames, error) {
+	if len(renames) == 0 {
+		return nil, nil
+	}
+
+	compiled := make(cookieRenames, len(renames))
+	for i, rename := range renames {
+		if !isToken(rename.ExistingCookieName) || !isToken(rename.NewCookieName) {
+			return nil, fmt.Errorf("cookie rename %d: invalid cookie name %q -> %q", i, rename.ExistingCookieName, rename.NewCookieName)
+		}
+		if _, ok := compiled[rename.ExistingCookieName]; ok {
+			return nil, fmt.Errorf("cookie rename %d: cookie %q is renamed twice", i, rename.ExistingCookieName)
+		}
+		compiled[rename.ExistingCookieName] = rename.NewCookieName
+	}
+	return compiled, nil
+}
+
+// applyRequest renames the cookies sent in the Cookie headers.
+func (c cookieRenames) applyRequest(header http.Header) {
+	if len(c) == 0 {
+		return
+	}
+	for i, value := range header["Cookie"] {
+		pairs := strings.Split(value, ";")
+		changed := false
+		for j, pair := range pairs {
+			if renamed, ok := c.rename(pair); ok {
+				pairs[j] = renamed
+				changed = true
+			}
+		}
+		if changed {
+			header["Cookie"][i] = strings.Join(pairs, ";")
+		}
+	}
+}
+
+// applyResponse renames the cookies set by Set-Cookie headers, keeping their
+// values and attributes as sent.
+func (c cookieRenames) applyResponse(header http.Header) {
+	if len(c) == 0 {
+		return
+	}
+	for i, value := range header["Set-Cookie"] {
+		if renamed, ok := c.rename(value); ok {
+			header["Set-Cookie"][i] = renamed
+		}
+	}
+}
+
+// rename replaces the cookie name at the start of a "name=value..." string,
+// keeping any leading whitespace and everything after the name.
+func (c cookieRenames) rename(s string) (string, bool) {
+	start := len(s) - len(strings.TrimLeft(s, " \t"))
+	end := strings.IndexByte(s, '=')
+	if end < start {
+		return s, false
+	}
+
+	newName, ok := c[strings.TrimRight(s[start:end], " \t")]
+	if !ok {
+		return s, false
+	}
+	return s[:start] + newName + s[end:], true
+}
+
+// CookieAttributes rewrites attributes of Set-Cookie response headers.
+type CookieAttributes struct {
+	// CookieName restricts the rewrite to one cookie, after cookies are
+	// renamed; all cookies are rewritten when empty.
+	CookieName string `json:"cookieName"`
+	// Domain, Path and SameSite replace the attribute when set, adding it
+	// if the cookie lacks it. SameSite is one of Strict, Lax or None.
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	SameSite string `json:"sameSite"`
+	// Secure adds the Secure attribute when true and removes it when false.
+	Secure *bool `json:"secure"`
+}
+
+// sameSiteModes are the SameSite values in their canonical spelling, by lower case.
+var sameSiteModes = map[string]string{
+	"strict": "Strict",
+	"lax":    "Lax",
+	"none":   "None",
+}
+
+// compileCookieAttributes validates the configured attribute rewrites.
+func compileCookieAttributes(rewrites []CookieAttributes) ([]CookieAttributes, error) {
+	compiled := make([]CookieAttributes, 0, len(rewrites))
+	for i, rewrite := range rewrites {
+		if rewrite.CookieName != "" && !isToken(rewrite.CookieName) {
+			return nil, fmt.Errorf("cookie attributes %d: invalid cookie name %q", i, rewrite.CookieName)
+		}
+		if strings.ContainsAny(rewrite.Domain+rewrite.Path, ";\r\n") {
+			return nil, fmt.Errorf("cookie attributes %d: domain and path cannot contain \";\" or line breaks", i)
+		}
+		if rewrite.SameSite != "" {
+			mode, ok := sameSiteModes[strings.ToLower(rewrite.SameSite)]
+			if !ok {
+				return nil, fmt.Errorf("cookie attributes %d: invalid sameSite %q", i, rewrite.SameSite)
+			}
+			rewrite.SameSite = mode
+		}
+		if rewrite.Secure != nil {
+			// Copy so that the caller cannot change the compiled rewrite.
+			secure := *rewrite.Secure
+			rewrite.Secure = &secure
+		}
+		compiled = append(compiled, rewrite)
+	}
+	return compiled, nil
+}
+
+// rewriteCookieAttributes applies the rewrites to the Set-Cookie headers.
+func rewriteCookieAttributes(header http.Header, rewrites []CookieAttributes) {
+	if len(rewrites) == 0 {
+		return
+	}
+	for i, value := range header["Set-Cookie"] {
+		parts := strings.Split(value, ";")
+		for j := range parts {
+			parts[j] = strings.TrimSpace(parts[j])
+		}
+
+		name := parts[0]
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			name = strings.TrimSpace(name[:eq])
+		}
+
+		changed := false
+		for _, rewrite := range rewrites {
+			if rewrite.CookieName != "" && rewrite.CookieName != name {
+				continue
+			}
+			changed = true
+			if rewrite.Domain != "" {
+				parts = setCookieAttribute(parts, "Domain", rewrite.Domain)
+			}
+			if rewrite.Path != "" {
+				parts = setCookieAttribute(parts, "Path", rewrite.Path)
+			}
+			if rewrite.SameSite != "" {
+				parts = setCookieAttribute(parts, "SameSite", rewrite.SameSite)
+			}
+			if rewrite.Secure != nil {
+				parts = removeCookieAttribute(parts, "Secure")
+				if *rewrite.Secure {
+					parts = append(parts, "Secure")
+				}
+			}
+		}
+		if changed {
+			header["Set-Cookie"][i] = strings.Join(parts, "; ")
+		}
+	}
+}
+
+// setCookieAttribute replaces the named attribute, or appends it when missing.
+// parts[0] is the cookie itself and never treated as an attribute.
+func setCookieAttribute(parts []string, name, value string) []string {
+	for i := 1; i < len(parts); i++ {
+		if cookieAttributeName(parts[i]) == strings.ToLower(name) {
+			parts[i] = name + "=" + value
+			return parts
+		}
+	}
+	return append(parts, name+"="+value)
+}
+
+// removeCookieAttribute drops every occurrence of the named attribute.
+func removeCookieAttribute(parts []string, name string) []string {
+	kept := parts[:1]
+	for _, part := range parts[1:] {
+		if cookieAttributeName(part) != strings.ToLower(name) {
+			kept = append(kept, part)
+		}
+	}
+	return kept
+}
+
+// cookieAttributeName returns the lower case name of a Set-Cookie attribute.
+func cookieAttributeName(attribute string) string {
+	if eq := strings.IndexByte(attribute, '='); eq >= 0 {
+		attribute = attribute[:eq]
+	}
+	return strings.ToLower(strings.TrimSpace(attribute))
+}
@@ -0,0 +1,123 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenameCookies(t *testing.T) {
+	config := &Config{
+		RenameCookies: []CookieRename{
+			{ExistingCookieName: "legacy_session", NewCookieName: "session"},
+			{ExistingCookieName: "session", NewCookieName: "legacy_session"},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Add("Set-Cookie", "session=abc; Path=/; HttpOnly; Secure")
+		rw.Header().Add("Set-Cookie", "theme=dark; Max-Age=3600")
+		rw.Header().Add("Set-Cookie", "Session=case")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("Cookie", "theme=dark; legacy_session=abc; other=legacy_session")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expected := []string{"theme=dark; session=abc; other=legacy_session"}
+	if values := forwarded["Cookie"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+
+	expected = []string{"legacy_session=abc; Path=/; HttpOnly; Secure", "theme=dark; Max-Age=3600", "Session=case"}
+	if values := recorder.Result().Header["Set-Cookie"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+}
+
+func TestCompileCookieRenamesErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		renames []CookieRename
+	}{
+		{desc: "Should reject an empty name", renames: []CookieRename{{ExistingCookieName: "a"}}},
+		{desc: "Should reject separators in names", renames: []CookieRename{{ExistingCookieName: "a", NewCookieName: "b;c"}}},
+		{desc: "Should reject renaming a cookie twice", renames: []CookieRename{
+			{ExistingCookieName: "a", NewCookieName: "b"},
+			{ExistingCookieName: "a", NewCookieName: "c"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileCookieRenames(test.renames); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestCookieAttributes(t *testing.T) {
+	secure, insecure := true, false
+	tests := []struct {
+		desc      string
+		rewrites  []CookieAttributes
+		setCookie []string
+		expected  []string
+	}{
+		{
+			desc:      "Should replace the domain of every cookie",
+			rewrites:  []CookieAttributes{{Domain: "example.com"}},
+			setCookie: []string{"a=1; Domain=.internal.local; Path=/", "b=2"},
+			expected:  []string{"a=1; Domain=example.com; Path=/", "b=2; Domain=example.com"},
+		},
+		{
+			desc:      "Should only rewrite the named cookie",
+			rewrites:  []CookieAttributes{{CookieName: "session", Path: "/app", SameSite: "lax", Secure: &secure}},
+			setCookie: []string{"session=abc; path=/; SameSite=None", "theme=dark; Path=/"},
+			expected:  []string{"session=abc; Path=/app; SameSite=Lax; Secure", "theme=dark; Path=/"},
+		},
+		{
+			desc:      "Should remove the secure attribute",
+			rewrites:  []CookieAttributes{{Secure: &insecure}},
+			setCookie: []string{"session=abc; secure; HttpOnly"},
+			expected:  []string{"session=abc; HttpOnly"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				CookieAttributes: test.rewrites,
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["Set-Cookie"] = append([]string(nil), test.setCookie...)
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if values := recorder.Result().Header["Set-Cookie"]; !testEq(values, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CORS keeps the CORS headers of responses consistent with renames.
+type CORS struct {
+	// AllowedOrigins lists the origins reflected in
+	// Access-Control-Allow-Origin. Origins may contain wildcards, such as
+	// "https://*.example.com". When set, the header is set to the Origin of
+	// requests from allowed origins and removed for other origins.
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// ExposeHeaders are added to Access-Control-Expose-Headers.
+	ExposeHeaders []string `json:"exposeHeaders"`
+}
+
+// corsRules is a CORS configuration prepared for use.
+type corsRules struct {
+	origins []*globPattern
+	expose  []string
+}
+
+// compileCORS validates the CORS configuration; it returns nil when there is
+// none.
+func compileCORS(cors *CORS) (*corsRules, error) {
+	if cors == nil {
+		return nil, nil
+	}
+
+	compiled := &corsRules{}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "" {
+			return nil, fmt.Errorf("cors: allowed origins cannot be empty")
+		}
+		glob, err := compileGlob(origin)
+		if err != nil {
+			return nil, fmt.Errorf("cors: %w", err)
+		}
+		compiled.origins = append(compiled.origins, glob)
+	}
+	for _, name := range cors.ExposeHeaders {
+		if !isToken(name) {
+			return nil, fmt.Errorf("cors: %q is not a valid header name", name)
+		}
+		compiled.expose = append(compiled.expose, name)
+	}
+	return compiled, nil
+}
+
+// apply rewrites the CORS headers of a response to req. Headers renamed by
+// rules are renamed in Access-Control-Expose-Headers as well, so that
+// browsers keep exposing them.
+func (c *corsRules) apply(header http.Header, req *http.Request, renamed []RenamedHeader) {
+	if c == nil {
+		return
+	}
+
+	if len(c.origins) > 0 {
+		if origin := req.Header.Get("Origin"); origin != "" {
+			if c.allows(origin) {
+				header.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				header.Del("Access-Control-Allow-Origin")
+			}
+		}
+		// The response depends on the origin whether it is allowed or not,
+		// so that shared caches keep the responses apart.
+		if !hasToken(header, "Vary", "Origin") {
+			header.Add("Vary", "Origin")
+		}
+	}
+
+	exposed := header.Values("Access-Control-Expose-Headers")
+	if len(exposed) == 0 && len(c.expose) == 0 {
+		return
+	}
+	var names []string
+	for _, value := range exposed {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, exposedName(name, renamed))
+			}
+		}
+	}
+	for _, name := range c.expose {
+		if !containsFold(names, name) {
+			names = append(names, name)
+		}
+	}
+	header.Set("Access-Control-Expose-Headers", strings.Join(names, ", "))
+}
+
+// allows reports whether origin matches one of the allowed origins.
+func (c *corsRules) allows(origin string) bool {
+	for _, glob := range c.origins {
+		if _, ok := glob.match(origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// exposedName returns the name a header was renamed to, following chained
+// renames, or name when it was not renamed.
+func exposedName(name string, renamed []RenamedHeader) string {
+	for _, rename := range renamed {
+		if strings.EqualFold(rename.From, name) {
+			name = rename.To
+		}
+	}
+	return name
+}
@@ -0,0 +1,107 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Upstream-Total", NewHeaderName: "X-Total"},
+			{ExistingHeaderName: "X-Total", NewHeaderName: "X-Total-Count"},
+		},
+		CORS: &CORS{
+			AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"},
+			ExposeHeaders:  []string{"X-Request-Id", "x-total-count"},
+		},
+	}
+
+	tests := []struct {
+		desc      string
+		origin    string
+		expHeader http.Header
+	}{
+		{
+			desc:   "Should reflect allowed origins",
+			origin: "https://app.example.com",
+			expHeader: http.Header{
+				"Access-Control-Allow-Origin":   {"https://app.example.com"},
+				"Vary":                          {"Accept-Encoding", "Origin"},
+				"Access-Control-Expose-Headers": {"X-Total-Count, ETag, X-Request-Id"},
+			},
+		},
+		{
+			desc:   "Should reflect origins matching a pattern",
+			origin: "https://pr-42.preview.example.com",
+			expHeader: http.Header{
+				"Access-Control-Allow-Origin": {"https://pr-42.preview.example.com"},
+			},
+		},
+		{
+			desc:   "Should remove the allowed origin for other origins",
+			origin: "https://evil.example",
+			expHeader: http.Header{
+				"Access-Control-Allow-Origin": nil,
+				"Vary":                        {"Accept-Encoding", "Origin"},
+			},
+		},
+		{
+			desc: "Should leave requests without origin alone",
+			expHeader: http.Header{
+				"Access-Control-Allow-Origin": {"*"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Access-Control-Allow-Origin", "*")
+				rw.Header().Set("Access-Control-Expose-Headers", "X-Upstream-Total, ETag")
+				rw.Header().Set("Vary", "Accept-Encoding")
+				rw.Header().Set("X-Upstream-Total", "3")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			for k, expected := range test.expHeader {
+				if values := recorder.Header()[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileCORSErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		cors *CORS
+	}{
+		{desc: "Should reject empty origins", cors: &CORS{AllowedOrigins: []string{""}}},
+		{desc: "Should reject invalid origin patterns", cors: &CORS{AllowedOrigins: []string{"https://**"}}},
+		{desc: "Should reject invalid exposed names", cors: &CORS{ExposeHeaders: []string{"X Total"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileCORS(test.cors); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
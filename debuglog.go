@@ -0,0 +1,126 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Log levels.
+const (
+	// LogLevelOff logs nothing, the default.
+	LogLevelOff = "off"
+	// LogLevelDebug logs the decision taken by each rule for each request
+	// and response.
+	LogLevelDebug = "debug"
+)
+
+// decisionLog receives the decision taken by a rule: the headers it renamed,
+// or the reason it renamed none.
+type decisionLog func(rule *compiledRule, renamed []RenamedHeader, reason string)
+
+// compileLogLevel validates the log level and reports whether decisions are logged.
+func compileLogLevel(level string) (bool, error) {
+	switch strings.ToLower(level) {
+	case "", LogLevelOff:
+		return false, nil
+	case LogLevelDebug:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown logLevel %q", level)
+	}
+}
+
+// decisionLog returns the log of the rule decisions for a request, status 0,
+// or a response, nil when they are neither logged nor counted.
+func (r *renameHeaders) decisionLog(status int) decisionLog {
+	debug := r.debugLog(status)
+	if r.metrics == nil {
+		return debug
+	}
+	direction := directionOf(status)
+
+	return func(rule *compiledRule, renamed []RenamedHeader, reason string) {
+		r.metrics.record(direction, rule, renamed, reason)
+		if debug != nil {
+			debug(rule, renamed, reason)
+		}
+	}
+}
+
+// directionOf names the direction of a request, status 0, or a response.
+func directionOf(status int) string {
+	if status == 0 {
+		return "request"
+	}
+	return "response"
+}
+
+// debugLog returns the debug log of the rule decisions for a request, status
+// 0, or a response, nil when they are not logged.
+func (r *renameHeaders) debugLog(status int) decisionLog {
+	if r.logOutput == nil {
+		return nil
+	}
+	direction := directionOf(status)
+
+	return func(rule *compiledRule, renamed []RenamedHeader, reason string) {
+		prefix := "level=debug plugin=renameHeaders middleware=" + strconv.Quote(r.name) +
+			" direction=" + direction + " rule=" + strconv.Itoa(rule.index) + " name=" + strconv.Quote(rule.label())
+
+		var b strings.Builder
+		if len(renamed) == 0 {
+			b.WriteString(prefix + " decision=skipped reason=" + strconv.Quote(reason) + "\n")
+		}
+		for _, rename := range renamed {
+			b.WriteString(prefix + " decision=renamed from=" + strconv.Quote(rename.From) + " to=" + strconv.Quote(rename.To) + "\n")
+		}
+		// One write per decision, so that lines of concurrent requests do
+		// not interleave.
+		_, _ = io.WriteString(r.logOutput, b.String())
+	}
+}
+
+// logError logs an error that does not fail the request, described by
+// fields.
+func (r *renameHeaders) logError(fields string, err error) {
+	_, _ = io.WriteString(r.errorOutput, "level=error plugin=renameHeaders middleware="+strconv.Quote(r.name)+
+		" "+fields+" error="+strconv.Quote(err.Error())+"\n")
+}
+
+// Reasons for a rule to rename no header.
+const (
+	reasonRequestConditions = "request-conditions"
+	reasonConditions        = "conditions"
+	reasonNoMatch           = "no-match"
+	reasonNoValue           = "no-value"
+	reasonDefaultValue      = "default-value"
+	reasonValueMismatch     = "value-mismatch"
+	reasonConflict          = "conflict"
+)
+
+// skipReason explains why the rule renamed no header in header, with one of
+// the reason* constants. It is only meaningful right after the rule applied
+// without renaming.
+func (c *compiledRule) skipReason(header http.Header, status int) string {
+	if !c.matches(header, status) {
+		return reasonConditions
+	}
+	if c.isPattern() {
+		return reasonNoMatch
+	}
+
+	values := header[c.sourceKey()]
+	switch {
+	case len(values) == 0 && c.DefaultValue != "":
+		return reasonDefaultValue
+	case len(values) == 0:
+		return reasonNoValue
+	case !c.valueMatches(values):
+		return reasonValueMismatch
+	default:
+		return reasonConflict
+	}
+}
@@ -0,0 +1,68 @@
+package traefik_header_rename_plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugLog(t *testing.T) {
+	config := &Config{
+		LogLevel: "debug",
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+			{ExistingHeaderName: "X-Missing", NewHeaderName: "X-Found"},
+			{ExistingHeaderName: "X-Value", NewHeaderName: "X-Matched", ValueRegexp: "^yes$"},
+			{ExistingHeaderName: "X-Old", NewHeaderName: "X-Admin", PathPrefix: "/admin", Name: "admin"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Old", "1")
+		rw.Header().Set("X-Value", "no")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	handler.(*renameHeaders).logOutput = &output
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	prefix := `level=debug plugin=renameHeaders middleware="rewriteHeader" direction=response `
+	expected := []string{
+		prefix + `rule=0 name="X-Old->X-New" decision=renamed from="X-Old" to="X-New"`,
+		prefix + `rule=2 name="X-Value->X-Matched" decision=skipped reason="value-mismatch"`,
+		prefix + `rule=3 name="admin" decision=skipped reason="request-conditions"`,
+		prefix + `rule=1 name="X-Missing->X-Found" decision=skipped reason="no-value"`,
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if !testEq(lines, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, lines)
+	}
+}
+
+func TestDebugLogOff(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}},
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handler.(*renameHeaders).logOutput != nil {
+		t.Error("expected decisions not to be logged by default")
+	}
+
+	config.LogLevel = "verbose"
+	if _, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader"); err == nil {
+		t.Error("expected an error for an unknown log level")
+	}
+}
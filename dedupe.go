@@ -0,0 +1,51 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// dedupeValues returns values without repeated identical values, keeping the
+// first occurrence of each. Surrounding whitespace is ignored when comparing.
+// values is returned as is when there is nothing to drop.
+func dedupeValues(values []string) []string {
+	if len(values) < 2 {
+		return values
+	}
+
+	seen := make(map[string]bool, len(values))
+	var deduped []string
+	for i, value := range values {
+		key := strings.TrimSpace(value)
+		if !seen[key] {
+			seen[key] = true
+			if deduped != nil {
+				deduped = append(deduped, value)
+			}
+			continue
+		}
+		if deduped == nil {
+			// Only copy once a value has to be dropped.
+			deduped = append(make([]string, 0, len(values)-1), values[:i]...)
+		}
+	}
+	if deduped == nil {
+		return values
+	}
+	return deduped
+}
+
+// dedupeHeaders collapses repeated values of the headers matched by any of the matchers.
+func dedupeHeaders(header http.Header, matchers []headerMatcher) {
+	if len(matchers) == 0 {
+		return
+	}
+	for name, values := range header {
+		for _, m := range matchers {
+			if m.matches(name) {
+				header[name] = dedupeValues(values)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDedupeValues(t *testing.T) {
+	tests := []struct {
+		desc     string
+		values   []string
+		expected []string
+	}{
+		{desc: "Should keep distinct values", values: []string{"a", "b"}, expected: []string{"a", "b"}},
+		{desc: "Should keep the first of identical values", values: []string{"a", "b", " a", "b", "c"}, expected: []string{"a", "b", "c"}},
+		{desc: "Should keep a single value", values: []string{"a"}, expected: []string{"a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if values := dedupeValues(test.values); !testEq(values, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+			}
+		})
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	tests := []struct {
+		desc          string
+		config        *Config
+		expRespHeader http.Header
+	}{
+		{
+			desc: "Should dedupe values merged by a rule",
+			config: &Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "X-Upstream-Origin", NewHeaderName: "Access-Control-Allow-Origin", OnConflict: ConflictAppend, Dedupe: true},
+				},
+			},
+			expRespHeader: http.Header{
+				"Access-Control-Allow-Origin": {"https://example.com"},
+				"Vary":                        {"Origin", "Origin"},
+			},
+		},
+		{
+			desc: "Should dedupe the listed headers",
+			config: &Config{
+				DedupeHeaders: []string{"access-control-*"},
+			},
+			expRespHeader: http.Header{
+				"Access-Control-Allow-Origin": {"https://example.com"},
+				"Vary":                        {"Origin", "Origin"},
+				"X-Upstream-Origin":           {"https://example.com"},
+			},
+		},
+		{
+			desc: "Should dedupe every header",
+			config: &Config{
+				DedupeHeaders: []string{"*"},
+			},
+			expRespHeader: http.Header{
+				"Access-Control-Allow-Origin": {"https://example.com"},
+				"Vary":                        {"Origin"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Add("Access-Control-Allow-Origin", "https://example.com")
+				rw.Header().Add("Access-Control-Allow-Origin", "https://example.com")
+				rw.Header().Add("X-Upstream-Origin", "https://example.com")
+				rw.Header().Add("Vary", "Origin")
+				rw.Header().Add("Vary", "Origin")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), test.config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for k, expected := range test.expRespHeader {
+				if values := recorder.Result().Header[k]; !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultDryRunHeader receives the plan of the rules in dry-run mode.
+const DefaultDryRunHeader = "X-Header-Rename-Plan"
+
+// plan describes what the rules would do to header, applying them to a copy:
+// "From->To" for each rename and "rule:skipped(reason)" for each rule that
+// renames nothing, separated by ";". Decisions are logged as well, if
+// enabled, but not counted in the metrics, as nothing is renamed. status is
+// the response status code, 0 for requests.
+func (r *renameHeaders) plan(rules *ruleIndex, header http.Header, req *http.Request, status int) string {
+	log := r.debugLog(status)
+
+	var entries []string
+	_, _ = rules.applyLogged(header.Clone(), req, status, func(rule *compiledRule, renamed []RenamedHeader, reason string) {
+		if log != nil {
+			log(rule, renamed, reason)
+		}
+		if len(renamed) == 0 {
+			entries = append(entries, rule.label()+":skipped("+reason+")")
+		}
+		for _, rename := range renamed {
+			entries = append(entries, rename.From+"->"+rename.To)
+		}
+	})
+	return strings.Join(entries, ";")
+}
+
+// setPlan sets the plan of the rules for header under the dry-run header.
+// Rules that fail are planned with their error, which may contain line
+// breaks from header values, so they are stripped.
+func (r *renameHeaders) setPlan(rules *ruleIndex, header http.Header, req *http.Request, status int) {
+	if rules.empty() {
+		return
+	}
+	header.Set(r.dryRunHeader, stripControls(r.plan(rules, header, req, status)))
+}
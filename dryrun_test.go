@@ -0,0 +1,100 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	config := &Config{
+		DryRun: true,
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+			{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar"},
+			{ExistingHeaderName: "X-Legacy-*", NewHeaderName: "X-*"},
+		},
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Client", NewHeaderName: "X-Client-Id"},
+		},
+		SetHeaders: map[string]string{"X-Set": "yes"},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-Old", "1")
+		rw.Header().Set("X-Legacy-A", "a")
+		rw.Header().Set("X-Legacy-B", "b")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Client", "c")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expReqHeader := map[string][]string{
+		"X-Client":             {"c"},
+		"X-Client-Id":          nil,
+		"X-Header-Rename-Plan": {"X-Client->X-Client-Id"},
+	}
+	for k, expected := range expReqHeader {
+		if values := forwarded[k]; !testEq(values, expected) {
+			t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+
+	expRespHeader := map[string][]string{
+		"X-Old":      {"1"},
+		"X-New":      nil,
+		"X-Legacy-A": {"a"},
+		"X-Set":      {"yes"},
+		"X-Header-Rename-Plan": {
+			"X-Old->X-New;X-Legacy-A->X-A;X-Legacy-B->X-B;X-Foo->X-Bar:skipped(no-value)",
+		},
+	}
+	for k, expected := range expRespHeader {
+		if values := recorder.Result().Header[k]; !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
+
+func TestDryRunHeader(t *testing.T) {
+	config := &Config{
+		DryRun:       true,
+		DryRunHeader: "X-Plan",
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expected := []string{"X-Old->X-New:skipped(no-value)"}
+	if values := recorder.Result().Header["X-Plan"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+
+	config.DryRunHeader = "X Plan"
+	if _, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader"); err == nil {
+		t.Error("expected an error for an invalid dry-run header")
+	}
+}
@@ -0,0 +1,123 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerEdits are the static header changes applied once renames are done:
+// removals first, then set and added headers, then signing, masking and
+// de-duplication.
+type headerEdits struct {
+	remove []headerMatcher
+	set    http.Header
+	add    http.Header
+	sign   []headerSignature
+	mask   []headerMask
+	dedupe []headerMatcher
+}
+
+// compileHeaderEdits validates and prepares the configured header edits.
+func compileHeaderEdits(remove []string, set, add map[string]string) (headerEdits, error) {
+	var edits headerEdits
+	var err error
+
+	edits.remove, err = compileHeaderMatchers(remove)
+	if err != nil {
+		return headerEdits{}, fmt.Errorf("removeHeaders: %w", err)
+	}
+	edits.set, err = compileStaticHeaders(set)
+	if err != nil {
+		return headerEdits{}, fmt.Errorf("setHeaders: %w", err)
+	}
+	edits.add, err = compileStaticHeaders(add)
+	if err != nil {
+		return headerEdits{}, fmt.Errorf("addHeaders: %w", err)
+	}
+	return edits, nil
+}
+
+// compileStaticHeaders canonicalizes and validates a name to value map.
+func compileStaticHeaders(values map[string]string) (http.Header, error) {
+	header := make(http.Header, len(values))
+	for name, value := range values {
+		if name == "" {
+			return nil, fmt.Errorf("header name cannot be empty")
+		}
+		if strings.HasPrefix(name, ":") {
+			return nil, fmt.Errorf("header name %q is a pseudo-header", name)
+		}
+		header.Add(name, value)
+	}
+	return header, nil
+}
+
+// apply edits header and returns the names of the removed headers.
+func (e *headerEdits) apply(header http.Header) []string {
+	removed := removeHeaders(header, e.remove)
+	for name, values := range e.set {
+		header[name] = append([]string(nil), values...)
+	}
+	for name, values := range e.add {
+		header[name] = append(header[name], values...)
+	}
+	signHeaders(header, e.sign)
+	maskHeaders(header, e.mask)
+	dedupeHeaders(header, e.dedupe)
+	return removed
+}
+
+// headerMatcher matches header names exactly or, for names containing
+// wildcards, by glob pattern.
+type headerMatcher struct {
+	name string
+	glob *globPattern
+}
+
+// compileHeaderMatchers prepares a list of header names for matching.
+func compileHeaderMatchers(names []string) ([]headerMatcher, error) {
+	matchers := make([]headerMatcher, 0, len(names))
+	for i, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("header %d: name cannot be empty", i)
+		}
+		if !isGlob(name) {
+			matchers = append(matchers, headerMatcher{name: http.CanonicalHeaderKey(name)})
+			continue
+		}
+
+		glob, err := compileGlob(name)
+		if err != nil {
+			return nil, fmt.Errorf("header %d: %w", i, err)
+		}
+		matchers = append(matchers, headerMatcher{name: name, glob: glob})
+	}
+	return matchers, nil
+}
+
+// matches reports whether the header name matches.
+func (m headerMatcher) matches(name string) bool {
+	if m.glob != nil {
+		_, ok := m.glob.match(name)
+		return ok
+	}
+	return http.CanonicalHeaderKey(name) == m.name
+}
+
+// removeHeaders deletes the headers matched by any of the matchers and
+// returns their names.
+func removeHeaders(header http.Header, matchers []headerMatcher) []string {
+	var removed []string
+	for _, m := range matchers {
+		// Renamed headers may have been stored under non-canonical keys, so
+		// exact names are matched against every key as well.
+		for name := range header {
+			if m.matches(name) {
+				delete(header, name)
+				removed = append(removed, name)
+			}
+		}
+	}
+	return removed
+}
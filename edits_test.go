@@ -0,0 +1,144 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoveHeaders(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Trace", NewHeaderName: "x-internal-trace"},
+		},
+		RemoveHeaders:        []string{"X-Internal-Trace", "X-Debug-*"},
+		RequestRemoveHeaders: []string{"x-client-secret"},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-Trace", "abc")
+		rw.Header().Set("X-Debug-Timing", "12ms")
+		rw.Header().Set("X-Debug-Node", "node-1")
+		rw.Header().Set("X-Public", "yes")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Client-Secret", "secret")
+	req.Header.Set("X-Client-Id", "id")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if _, ok := forwarded["X-Client-Secret"]; ok {
+		t.Errorf("expected the request header to be removed, got %+v", forwarded)
+	}
+	if forwarded.Get("X-Client-Id") != "id" {
+		t.Errorf("expected other request headers to be kept, got %+v", forwarded)
+	}
+
+	header := recorder.Result().Header
+	for _, name := range []string{"X-Trace", "x-internal-trace", "X-Debug-Timing", "X-Debug-Node"} {
+		if _, ok := header[name]; ok {
+			t.Errorf("expected %s to be removed, got %+v", name, header)
+		}
+	}
+	if header.Get("X-Public") != "yes" {
+		t.Errorf("expected other response headers to be kept, got %+v", header)
+	}
+}
+
+func TestRemoveHeadersWithoutWrite(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Trace", NewHeaderName: "X-Request-Trace"},
+		},
+		RemoveHeaders: []string{"X-Internal"},
+	}
+
+	// The handler returns without writing the head or a body.
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Internal", "secret")
+		rw.Header().Set("X-Trace", "abc")
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	header := recorder.Result().Header
+	if _, ok := header["X-Internal"]; ok {
+		t.Errorf("expected X-Internal to be removed, got %+v", header)
+	}
+	if values := header["X-Request-Trace"]; !testEq(values, []string{"abc"}) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", []string{"abc"}, values)
+	}
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestSetAndAddHeaders(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Upstream-Gateway", NewHeaderName: "X-Gateway"},
+		},
+		SetHeaders:        map[string]string{"x-gateway": "edge-1"},
+		AddHeaders:        map[string]string{"Via": "1.1 edge-1"},
+		RequestSetHeaders: map[string]string{"X-Forwarded-By": "edge-1"},
+		RequestAddHeaders: map[string]string{"X-Hop": "edge-1"},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-Upstream-Gateway", "upstream")
+		rw.Header().Set("Via", "1.1 backend")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-By", "client")
+	req.Header.Set("X-Hop", "client")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expReqHeader := map[string][]string{
+		"X-Forwarded-By": {"edge-1"},
+		"X-Hop":          {"client", "edge-1"},
+	}
+	for k, expected := range expReqHeader {
+		if values := forwarded[k]; !testEq(values, expected) {
+			t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+
+	expRespHeader := map[string][]string{
+		"X-Gateway":          {"edge-1"},
+		"X-Upstream-Gateway": nil,
+		"Via":                {"1.1 backend", "1.1 edge-1"},
+	}
+	for k, expected := range expRespHeader {
+		if values := recorder.Result().Header[k]; !testEq(values, expected) {
+			t.Errorf("response %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package traefik_header_rename_plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// valueEncoding converts header values to and from an encoding.
+type valueEncoding struct {
+	encode func(string) string
+	decode func(string) (string, error)
+}
+
+// valueEncodings are the encodings available to the encode and decode
+// operations of a rule, by name.
+var valueEncodings = map[string]valueEncoding{
+	"base64": {
+		encode: encodeBase64(base64.StdEncoding),
+		decode: decodeBase64(base64.StdEncoding),
+	},
+	"base64url": {
+		encode: encodeBase64(base64.URLEncoding),
+		decode: decodeBase64(base64.URLEncoding),
+	},
+	// rfc2047 handles MIME encoded-words such as "=?UTF-8?B?...?=". Decoding
+	// supports the UTF-8, ISO-8859-1 and US-ASCII charsets and leaves plain
+	// text alone; encoding only encodes values that are not printable ASCII.
+	"rfc2047": {
+		encode: encodeWord,
+		decode: new(mime.WordDecoder).DecodeHeader,
+	},
+}
+
+// lookupEncoding returns the encoding with the given name.
+func lookupEncoding(name string) (valueEncoding, error) {
+	encoding, ok := valueEncodings[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(valueEncodings))
+		for name := range valueEncodings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return valueEncoding{}, fmt.Errorf("unknown encoding %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return encoding, nil
+}
+
+// encodeBase64 encodes values with padding.
+func encodeBase64(encoding *base64.Encoding) func(string) string {
+	return func(value string) string {
+		return encoding.EncodeToString([]byte(value))
+	}
+}
+
+// decodeBase64 decodes values with or without padding.
+func decodeBase64(encoding *base64.Encoding) func(string) (string, error) {
+	return func(value string) (string, error) {
+		value = strings.TrimSpace(value)
+		decoded, err := encoding.WithPadding(base64.NoPadding).DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return "", err
+		}
+		return string(decoded), nil
+	}
+}
+
+// encodeWord encodes a value as UTF-8 Q encoded-words.
+func encodeWord(value string) string {
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// encodeValues encodes each value.
+func encodeValues(encoding valueEncoding) valueTransform {
+	return func(values []string, _ *http.Request) ([]string, error) {
+		encoded := make([]string, len(values))
+		for i, value := range values {
+			encoded[i] = encoding.encode(value)
+		}
+		return encoded, nil
+	}
+}
+
+// decodeValues decodes each value, failing on the first invalid one.
+func decodeValues(encoding valueEncoding) valueTransform {
+	return func(values []string, _ *http.Request) ([]string, error) {
+		decoded := make([]string, len(values))
+		for i, value := range values {
+			var err error
+			if decoded[i], err = encoding.decode(value); err != nil {
+				return nil, &decodeError{Value: value, Err: err}
+			}
+		}
+		return decoded, nil
+	}
+}
+
+// decodeError reports a header value that cannot be decoded. Request values
+// are sent by clients, so rules leave them as they are instead of failing
+// the request, see ruleIndex.applyLogged.
+type decodeError struct {
+	Value string
+	Err   error
+}
+
+func (e *decodeError) Error() string {
+	return fmt.Sprintf("cannot decode value %q: %v", e.Value, e.Err)
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,168 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		desc      string
+		rule      RenameRule
+		values    []string
+		expected  []string
+		expStatus int
+	}{
+		{
+			desc:     "Should decode base64 values",
+			rule:     RenameRule{ExistingHeaderName: "X-Meta-B64", NewHeaderName: "X-Meta", Decode: "base64"},
+			values:   []string{"eyJpZCI6NDJ9", "YWJj"},
+			expected: []string{`{"id":42}`, "abc"},
+		},
+		{
+			desc:     "Should decode unpadded base64url values",
+			rule:     RenameRule{ExistingHeaderName: "X-Meta-B64", NewHeaderName: "X-Meta", Decode: "base64url"},
+			values:   []string{"Pz8_"},
+			expected: []string{"???"},
+		},
+		{
+			desc:      "Should fail on encoded-words in unknown charsets",
+			rule:      RenameRule{ExistingHeaderName: "X-Subject", NewHeaderName: "X-Title", Decode: "rfc2047"},
+			values:    []string{"=?koi8-r?B?8NLJ18XU?="},
+			expStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:     "Should encode values after the other transformations",
+			rule:     RenameRule{ExistingHeaderName: "X-Meta", NewHeaderName: "X-Meta-B64", Join: true, Encode: "BASE64"},
+			values:   []string{"a", "b"},
+			expected: []string{"YSwgYg=="},
+		},
+		{
+			desc:     "Should decode MIME encoded-words",
+			rule:     RenameRule{ExistingHeaderName: "X-Subject", NewHeaderName: "X-Title", Decode: "rfc2047"},
+			values:   []string{"=?UTF-8?B?R3LDvMOfZQ==?=", "=?iso-8859-1?q?caf=E9?= au lait", "plain"},
+			expected: []string{"Grüße", "café au lait", "plain"},
+		},
+		{
+			desc:     "Should encode values as MIME encoded-words",
+			rule:     RenameRule{ExistingHeaderName: "X-Title", NewHeaderName: "X-Subject", Encode: "rfc2047"},
+			values:   []string{"Grüße", "plain"},
+			expected: []string{"=?utf-8?q?Gr=C3=BC=C3=9Fe?=", "plain"},
+		},
+		{
+			desc:      "Should fail on values that do not decode",
+			rule:      RenameRule{ExistingHeaderName: "X-Meta-B64", NewHeaderName: "X-Meta", Decode: "base64"},
+			values:    []string{"not base64!"},
+			expStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()[test.rule.ExistingHeaderName] = test.values
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			config := &Config{RenameData: []RenameRule{test.rule}}
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if test.expStatus != 0 {
+				if recorder.Code != test.expStatus {
+					t.Fatalf("expected status %d, got %d", test.expStatus, recorder.Code)
+				}
+				return
+			}
+			if values := recorder.Header()[test.rule.NewHeaderName]; !testEq(values, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+			}
+		})
+	}
+}
+
+func TestUnknownEncoding(t *testing.T) {
+	config := &Config{RenameData: []RenameRule{
+		{ExistingHeaderName: "X-Meta", NewHeaderName: "X-Meta-B64", Encode: "base32"},
+	}}
+	if _, err := New(context.Background(), http.NotFoundHandler(), config, "rewriteHeader"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestDecodeInvalidRequestValue(t *testing.T) {
+	var received http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		received = req.Header.Clone()
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	config := &Config{RequestRenameData: []RenameRule{
+		{ExistingHeaderName: "X-Meta-B64", NewHeaderName: "X-Meta", Decode: "base64"},
+		{ExistingHeaderName: "X-Legacy", NewHeaderName: "X-Current"},
+	}}
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Meta-B64", "not base64!")
+	req.Header.Set("X-Legacy", "1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if values := received["X-Meta-B64"]; !testEq(values, []string{"not base64!"}) {
+		t.Errorf("expected the invalid value to be left, got %+v", received)
+	}
+	if values := received["X-Meta"]; values != nil {
+		t.Errorf("expected no decoded header, got %+v", values)
+	}
+	if values := received["X-Current"]; !testEq(values, []string{"1"}) {
+		t.Errorf("expected the later rules to apply, got %+v", received)
+	}
+}
+
+func TestDecodeInvalidRequestValuePattern(t *testing.T) {
+	var received http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		received = req.Header.Clone()
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	config := &Config{RequestRenameData: []RenameRule{
+		{ExistingHeaderName: "X-B64-*", NewHeaderName: "X-$1", Decode: "base64"},
+	}}
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-B64-A", "not base64!")
+	req.Header.Set("X-B64-B", "YWJj")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expected := map[string][]string{
+		"X-B64-A": {"not base64!"},
+		"X-A":     nil,
+		"X-B":     {"abc"},
+		"X-B64-B": nil,
+	}
+	for k, values := range expected {
+		if result := received[k]; !testEq(result, values) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, values, result)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package traefik_header_rename_plugin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// HeaderEncryption encrypts a response header with AES-GCM and decrypts it
+// when the client sends it back, so that internal values transit through the
+// client opaquely.
+type HeaderEncryption struct {
+	Header string `json:"header"`
+	// Keys are base64 encoded AES keys of 16, 24 or 32 bytes. The first
+	// encrypts, all of them are tried to decrypt, so that keys can be rotated.
+	Keys []string `json:"keys"`
+}
+
+// headerEncryption is a HeaderEncryption prepared for use.
+type headerEncryption struct {
+	header string
+	aeads  []cipher.AEAD
+}
+
+// compileHeaderEncryptions validates the configured encryptions.
+func compileHeaderEncryptions(encryptions []HeaderEncryption) ([]headerEncryption, error) {
+	compiled := make([]headerEncryption, 0, len(encryptions))
+	for i, encryption := range encryptions {
+		if encryption.Header == "" {
+			return nil, fmt.Errorf("encryption %d: header cannot be empty", i)
+		}
+		if len(encryption.Keys) == 0 {
+			return nil, fmt.Errorf("encryption %d: at least one key is required", i)
+		}
+
+		e := headerEncryption{header: http.CanonicalHeaderKey(encryption.Header)}
+		for j, key := range encryption.Keys {
+			raw, err := base64.StdEncoding.DecodeString(key)
+			if err != nil {
+				return nil, fmt.Errorf("encryption %d: key %d: %w", i, j, err)
+			}
+			block, err := aes.NewCipher(raw)
+			if err != nil {
+				return nil, fmt.Errorf("encryption %d: key %d: %w", i, j, err)
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				return nil, fmt.Errorf("encryption %d: key %d: %w", i, j, err)
+			}
+			e.aeads = append(e.aeads, aead)
+		}
+		compiled = append(compiled, e)
+	}
+	return compiled, nil
+}
+
+// encryptHeaders encrypts the values of the configured headers. Values that
+// cannot be encrypted are removed rather than sent in the clear, and the
+// header with them when none is left.
+func encryptHeaders(header http.Header, encryptions []headerEncryption) {
+	for _, e := range encryptions {
+		values := header[e.header]
+		if len(values) == 0 {
+			continue
+		}
+		encrypted := make([]string, 0, len(values))
+		for _, value := range values {
+			if sealed, err := e.encrypt(value); err == nil {
+				encrypted = append(encrypted, sealed)
+			}
+		}
+		if len(encrypted) == 0 {
+			delete(header, e.header)
+			continue
+		}
+		header[e.header] = encrypted
+	}
+}
+
+// decryptHeaders decrypts the values of the configured headers. Values that
+// no key decrypts are removed, so that clients cannot forge them.
+func decryptHeaders(header http.Header, encryptions []headerEncryption) {
+	for _, e := range encryptions {
+		values := header[e.header]
+		if len(values) == 0 {
+			continue
+		}
+		decrypted := make([]string, 0, len(values))
+		for _, value := range values {
+			if opened, ok := e.decrypt(value); ok {
+				decrypted = append(decrypted, opened)
+			}
+		}
+		if len(decrypted) == 0 {
+			delete(header, e.header)
+			continue
+		}
+		header[e.header] = decrypted
+	}
+}
+
+// encrypt seals value with the first key, binding it to the header name. The
+// result is the nonce followed by the ciphertext, base64url encoded.
+func (e *headerEncryption) encrypt(value string) (string, error) {
+	aead := e.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(e.header))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value sealed by encrypt with any of the keys.
+func (e *headerEncryption) decrypt(value string) (string, bool) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", false
+	}
+	for _, aead := range e.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if opened, err := aead.Open(nil, nonce, ciphertext, []byte(e.header)); err == nil {
+			return string(opened), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,113 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	oldEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	newEncryptionKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func TestEncryptHeaders(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Internal-Id", NewHeaderName: "X-Session-Ref"},
+		},
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Session-Ref", NewHeaderName: "X-Internal-Id"},
+		},
+		EncryptHeaders: []HeaderEncryption{
+			{Header: "x-session-ref", Keys: []string{oldEncryptionKey}},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-Internal-Id", "user-42")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	sealed := recorder.Header().Get("X-Session-Ref")
+	if sealed == "" || sealed == "user-42" {
+		t.Fatalf("expected an encrypted value, got %q", sealed)
+	}
+
+	// Rotate keys: values encrypted with the old key are still accepted.
+	config.EncryptHeaders[0].Keys = []string{newEncryptionKey, oldEncryptionKey}
+	handler, err = New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc     string
+		value    string
+		expected []string
+	}{
+		{desc: "Should decrypt values encrypted with an older key", value: sealed, expected: []string{"user-42"}},
+		{desc: "Should drop values that do not decrypt", value: "user-43", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Session-Ref", test.value)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if values := forwarded["X-Internal-Id"]; !testEq(values, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+			}
+		})
+	}
+}
+
+func TestEncryptionIsBoundToHeader(t *testing.T) {
+	encryptions, err := compileHeaderEncryptions([]HeaderEncryption{
+		{Header: "X-Session-Ref", Keys: []string{oldEncryptionKey}},
+		{Header: "X-Tenant-Ref", Keys: []string{oldEncryptionKey}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{"X-Session-Ref": {"user-42"}}
+	encryptHeaders(header, encryptions)
+
+	header = http.Header{"X-Tenant-Ref": header["X-Session-Ref"]}
+	decryptHeaders(header, encryptions)
+	if _, ok := header["X-Tenant-Ref"]; ok {
+		t.Errorf("expected a value moved to another header to be rejected, got %+v", header)
+	}
+}
+
+func TestCompileHeaderEncryptionsErrors(t *testing.T) {
+	tests := []struct {
+		desc        string
+		encryptions []HeaderEncryption
+	}{
+		{desc: "Should reject a missing header", encryptions: []HeaderEncryption{{Keys: []string{oldEncryptionKey}}}},
+		{desc: "Should reject a missing key", encryptions: []HeaderEncryption{{Header: "X-Session-Ref"}}},
+		{desc: "Should reject keys that are not base64", encryptions: []HeaderEncryption{{Header: "X-Session-Ref", Keys: []string{"not base64!"}}}},
+		{desc: "Should reject keys of the wrong size", encryptions: []HeaderEncryption{{Header: "X-Session-Ref", Keys: []string{"c2hvcnQ="}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileHeaderEncryptions(test.encryptions); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
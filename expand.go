@@ -0,0 +1,190 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expand returns a copy of the configuration with ${VAR} references and
+// file:// values expanded, see expandValue, in header values, secrets, the
+// audit webhook URL and new header names. The new names of regex rules are
+// left as is, as they reference capture groups with the same syntax.
+func (c *Config) expand() (*Config, error) {
+	expanded := *c
+	var err error
+
+	if expanded.RenameData, err = expandRules(c.RenameData); err != nil {
+		return nil, fmt.Errorf("renameData: %w", err)
+	}
+	if expanded.RequestRenameData, err = expandRules(c.RequestRenameData); err != nil {
+		return nil, fmt.Errorf("requestRenameData: %w", err)
+	}
+	if c.Variants != nil {
+		expanded.Variants = make(map[string]RuleVariant, len(c.Variants))
+		for name, variant := range c.Variants {
+			if variant.RenameData, err = expandRules(variant.RenameData); err != nil {
+				return nil, fmt.Errorf("variant %q: %w", name, err)
+			}
+			if variant.RequestRenameData, err = expandRules(variant.RequestRenameData); err != nil {
+				return nil, fmt.Errorf("variant %q: request %w", name, err)
+			}
+			expanded.Variants[name] = variant
+		}
+	}
+	if c.HostRules != nil {
+		expanded.HostRules = make(map[string][]RenameRule, len(c.HostRules))
+		for host, rules := range c.HostRules {
+			if expanded.HostRules[host], err = expandRules(rules); err != nil {
+				return nil, fmt.Errorf("host rules %q: %w", host, err)
+			}
+		}
+	}
+
+	for _, values := range []*map[string]string{
+		&expanded.SetHeaders, &expanded.AddHeaders,
+		&expanded.RequestSetHeaders, &expanded.RequestAddHeaders,
+	} {
+		if *values, err = expandMap(*values); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, signatures := range []*[]HeaderSignature{&expanded.SignHeaders, &expanded.RequestSignHeaders} {
+		*signatures = append([]HeaderSignature(nil), *signatures...)
+		for i := range *signatures {
+			if (*signatures)[i].Secret, err = expandValue((*signatures)[i].Secret); err != nil {
+				return nil, fmt.Errorf("signature %d: %w", i, err)
+			}
+		}
+	}
+	expanded.EncryptHeaders = append([]HeaderEncryption(nil), c.EncryptHeaders...)
+	for i := range expanded.EncryptHeaders {
+		keys := make([]string, len(expanded.EncryptHeaders[i].Keys))
+		for j, key := range expanded.EncryptHeaders[i].Keys {
+			if keys[j], err = expandValue(key); err != nil {
+				return nil, fmt.Errorf("encryption %d: key %d: %w", i, j, err)
+			}
+		}
+		expanded.EncryptHeaders[i].Keys = keys
+	}
+	if c.JWTClaims != nil {
+		jwt := *c.JWTClaims
+		if jwt.Secret, err = expandValue(jwt.Secret); err != nil {
+			return nil, fmt.Errorf("jwtClaims: secret: %w", err)
+		}
+		if jwt.PublicKey, err = expandValue(jwt.PublicKey); err != nil {
+			return nil, fmt.Errorf("jwtClaims: publicKey: %w", err)
+		}
+		expanded.JWTClaims = &jwt
+	}
+	if c.AuditWebhook != nil {
+		webhook := *c.AuditWebhook
+		if webhook.URL, err = expandValue(webhook.URL); err != nil {
+			return nil, fmt.Errorf("auditWebhook: url: %w", err)
+		}
+		expanded.AuditWebhook = &webhook
+	}
+	return &expanded, nil
+}
+
+// expandRules returns a copy of rules with their new names and default
+// values expanded.
+func expandRules(rules []RenameRule) ([]RenameRule, error) {
+	if rules == nil {
+		return nil, nil
+	}
+
+	expanded := make([]RenameRule, len(rules))
+	for i, rule := range rules {
+		var err error
+		if !rule.Regex {
+			if rule.NewHeaderName, err = expandValue(rule.NewHeaderName); err != nil {
+				return nil, fmt.Errorf("rename rule %d: %w", i, err)
+			}
+		}
+		if rule.NewHeaderNames != nil {
+			names := make([]string, len(rule.NewHeaderNames))
+			for j, name := range rule.NewHeaderNames {
+				if names[j], err = expandValue(name); err != nil {
+					return nil, fmt.Errorf("rename rule %d: %w", i, err)
+				}
+			}
+			rule.NewHeaderNames = names
+		}
+		if rule.DefaultValue, err = expandValue(rule.DefaultValue); err != nil {
+			return nil, fmt.Errorf("rename rule %d: %w", i, err)
+		}
+		expanded[i] = rule
+	}
+	return expanded, nil
+}
+
+// expandMap returns a copy of values with each value expanded.
+func expandMap(values map[string]string) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	expanded := make(map[string]string, len(values))
+	for name, value := range values {
+		var err error
+		if expanded[name], err = expandValue(value); err != nil {
+			return nil, fmt.Errorf("header %q: %w", name, err)
+		}
+	}
+	return expanded, nil
+}
+
+// expandValue returns the content of the file named by a file:// value,
+// without trailing line breaks, or s with each ${VAR} replaced by the value
+// of the environment variable. Unset variables are an error; other uses of
+// "$" are kept.
+func expandValue(s string) (string, error) {
+	if path := strings.TrimPrefix(s, "file://"); path != s {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 || !isEnvName(s[start+2:start+end]) {
+			b.WriteString(s[:start+2])
+			s = s[start+2:]
+			continue
+		}
+
+		name := s[start+2 : start+end]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(s[:start])
+		b.WriteString(value)
+		s = s[start+end+1:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+// isEnvName reports whether name is a valid environment variable name.
+func isEnvName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
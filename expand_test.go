@@ -0,0 +1,92 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandValue(t *testing.T) {
+	t.Setenv("RENAME_CLUSTER", "eu-1")
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc     string
+		value    string
+		expected string
+		err      bool
+	}{
+		{desc: "Should keep plain values", value: "plain", expected: "plain"},
+		{desc: "Should expand variables", value: "X-${RENAME_CLUSTER}-Id", expected: "X-eu-1-Id"},
+		{desc: "Should keep other uses of $", value: "$1 ${1} ${not a name} $RENAME_CLUSTER ${", expected: "$1 ${1} ${not a name} $RENAME_CLUSTER ${"},
+		{desc: "Should read files", value: "file://" + secret, expected: "s3cret"},
+		{desc: "Should fail on unset variables", value: "${RENAME_UNSET_VARIABLE}", err: true},
+		{desc: "Should fail on missing files", value: "file://" + secret + ".missing", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			value, err := expandValue(test.value)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected an error, got %q", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestExpandConfig(t *testing.T) {
+	t.Setenv("RENAME_CLUSTER", "eu-1")
+
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Id", NewHeaderName: "X-${RENAME_CLUSTER}-Id"},
+			{ExistingHeaderName: "X-(?P<name>.+)-Old", NewHeaderName: "X-${name}-New", Regex: true},
+		},
+		SetHeaders: map[string]string{"X-Cluster": "${RENAME_CLUSTER}"},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Id", "42")
+		rw.Header().Set("X-Team-Old", "blue")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expRespHeader := map[string][]string{
+		"X-eu-1-Id":  {"42"},
+		"X-Team-New": {"blue"},
+		"X-Cluster":  {"eu-1"},
+	}
+	for k, expected := range expRespHeader {
+		values := recorder.Result().Header[k]
+
+		if !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+	if config.SetHeaders["X-Cluster"] != "${RENAME_CLUSTER}" {
+		t.Error("expected the configuration to be left unchanged")
+	}
+}
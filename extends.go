@@ -0,0 +1,101 @@
+package traefik_header_rename_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// resolve returns the configuration merged onto its base file, if any, with
+// the Renames shorthand expanded into RenameData and values expanded.
+func (c *Config) resolve() (*Config, error) {
+	if c.ExtendsFile == "" && len(c.Renames) == 0 {
+		return c.expand()
+	}
+
+	renames, err := c.renameRules()
+	if err != nil {
+		return nil, err
+	}
+	merged := *c
+	merged.Renames = nil
+	merged.RenameData = renames
+	if c.ExtendsFile == "" {
+		return merged.expand()
+	}
+
+	base, err := loadBaseConfig(c.ExtendsFile)
+	if err != nil {
+		return nil, err
+	}
+	baseRenames, err := base.renameRules()
+	if err != nil {
+		return nil, fmt.Errorf("extends file %s: %w", c.ExtendsFile, err)
+	}
+
+	merged.ExtendsFile = ""
+	merged.RenameData = mergeRenames(baseRenames, renames)
+	merged.RequestRenameData = mergeRenames(base.RequestRenameData, c.RequestRenameData)
+	return merged.expand()
+}
+
+// renameRules returns the rules of Renames, sorted by existing header name,
+// merged with those of RenameData, which replace them for the same existing
+// header name.
+func (c *Config) renameRules() ([]RenameRule, error) {
+	if len(c.Renames) == 0 {
+		return c.RenameData, nil
+	}
+
+	names := make([]string, 0, len(c.Renames))
+	for existing, renamed := range c.Renames {
+		if existing == "" || renamed == "" {
+			return nil, fmt.Errorf("renames: header names cannot be empty: %q -> %q", existing, renamed)
+		}
+		names = append(names, existing)
+	}
+	sort.Strings(names)
+
+	shorthand := make([]RenameRule, 0, len(names))
+	for _, existing := range names {
+		shorthand = append(shorthand, RenameRule{ExistingHeaderName: existing, NewHeaderName: c.Renames[existing]})
+	}
+	return mergeRenames(shorthand, c.RenameData), nil
+}
+
+// loadBaseConfig reads the configuration a config extends.
+func loadBaseConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("extends file: %w", err)
+	}
+
+	base := CreateConfig()
+	if err := json.Unmarshal(data, base); err != nil {
+		return nil, fmt.Errorf("extends file %s: %w", path, err)
+	}
+	if base.ExtendsFile != "" {
+		return nil, fmt.Errorf("extends file %s: nested extendsFile is not supported", path)
+	}
+	return base, nil
+}
+
+// mergeRenames applies local rules onto base rules. The base rules for an
+// existing header name local rules use are dropped, and all local rules are
+// appended after the remaining base rules, in order.
+func mergeRenames(base, local []RenameRule) []RenameRule {
+	overridden := make(map[string]bool, len(local))
+	for _, rename := range local {
+		overridden[http.CanonicalHeaderKey(rename.ExistingHeaderName)] = true
+	}
+
+	merged := make([]RenameRule, 0, len(base)+len(local))
+	for _, rename := range base {
+		if !overridden[http.CanonicalHeaderKey(rename.ExistingHeaderName)] {
+			merged = append(merged, rename)
+		}
+	}
+	return append(merged, local...)
+}
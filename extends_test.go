@@ -0,0 +1,167 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtendsFile(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.json")
+	err := os.WriteFile(base, []byte(`{
+		"renameData": [
+			{"existingHeaderName": "X-Team", "newHeaderName": "X-Org-Team"},
+			{"existingHeaderName": "X-Region", "newHeaderName": "X-Org-Region"}
+		]
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		ExtendsFile: base,
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "x-team", NewHeaderName: "X-Squad"},
+			{ExistingHeaderName: "X-Local", NewHeaderName: "X-Team-Local"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Team", "blue")
+		rw.Header().Set("X-Region", "eu")
+		rw.Header().Set("X-Local", "yes")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expRespHeader := map[string][]string{
+		"X-Squad":      {"blue"},
+		"X-Org-Team":   nil,
+		"X-Org-Region": {"eu"},
+		"X-Team-Local": {"yes"},
+	}
+	for k, expected := range expRespHeader {
+		values := recorder.Result().Header[k]
+
+		if !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
+
+func TestExtendsFileSeveralLocalRules(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.json")
+	err := os.WriteFile(base, []byte(`{
+		"renameData": [
+			{"existingHeaderName": "X-Team", "newHeaderName": "X-Org-Team"},
+			{"existingHeaderName": "X-Team", "newHeaderName": "X-Org-Group", "keepOriginal": true}
+		]
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		ExtendsFile: base,
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Team", NewHeaderName: "X-Squad", KeepOriginal: true},
+			{ExistingHeaderName: "x-team", NewHeaderName: "X-Group"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Team", "blue")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expRespHeader := map[string][]string{
+		"X-Squad":     {"blue"},
+		"X-Group":     {"blue"},
+		"X-Team":      nil,
+		"X-Org-Team":  nil,
+		"X-Org-Group": nil,
+	}
+	for k, expected := range expRespHeader {
+		values := recorder.Result().Header[k]
+
+		if !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
+
+func TestExtendsFileErrors(t *testing.T) {
+	config := &Config{
+		ExtendsFile: filepath.Join(t.TempDir(), "missing.json"),
+	}
+
+	_, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestRenamesShorthand(t *testing.T) {
+	config := &Config{
+		Renames: map[string]string{
+			"X-B":    "X-C",
+			"X-A":    "X-B",
+			"X-Team": "X-Squad",
+		},
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "x-team", NewHeaderName: "X-Org-Team"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-A", "a")
+		rw.Header().Set("X-Team", "blue")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// X-A is renamed before X-B, so the renames chain.
+	expRespHeader := map[string][]string{
+		"X-A":        nil,
+		"X-C":        {"a"},
+		"X-Squad":    nil,
+		"X-Org-Team": {"blue"},
+	}
+	for k, expected := range expRespHeader {
+		values := recorder.Result().Header[k]
+
+		if !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+
+	config = &Config{Renames: map[string]string{"X-Old": ""}}
+	if _, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader"); err == nil {
+		t.Error("expected an error for an empty new header name")
+	}
+}
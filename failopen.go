@@ -0,0 +1,62 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// guard runs transform. With fail-open, restore is not nil: when transform
+// fails with an error other than a conflict, or panics, the failure is
+// logged, restore undoes the changes made so far and guard reports no error.
+// Conflicts fail as configured, as does a panic aborting the handler.
+func (r *renameHeaders) guard(direction string, restore func(), transform func() error) (err error) {
+	if restore == nil {
+		return transform()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			err = fmt.Errorf("panic: %v", p)
+		}
+		var conflict *ConflictError
+		if err == nil || errors.As(err, &conflict) {
+			return
+		}
+		r.logFailure(direction, err)
+		restore()
+		err = nil
+	}()
+	return transform()
+}
+
+// logFailure logs a failure the headers are passed through unmodified for.
+func (r *renameHeaders) logFailure(direction string, err error) {
+	r.logError("direction="+direction+" action=fail-open", err)
+}
+
+// snapshotRequest returns a function restoring the headers and query of req.
+func snapshotRequest(req *http.Request) func() {
+	restoreHeader := snapshotHeader(req.Header)
+	rawQuery, requestURI := req.URL.RawQuery, req.RequestURI
+	return func() {
+		restoreHeader()
+		req.URL.RawQuery, req.RequestURI = rawQuery, requestURI
+	}
+}
+
+// snapshotHeader returns a function restoring header to its current content.
+func snapshotHeader(header http.Header) func() {
+	original := header.Clone()
+	return func() {
+		for name := range header {
+			delete(header, name)
+		}
+		for name, values := range original {
+			header[name] = values
+		}
+	}
+}
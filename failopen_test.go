@@ -0,0 +1,219 @@
+package traefik_header_rename_plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFailOpen(t *testing.T) {
+	tests := []struct {
+		desc      string
+		failOpen  bool
+		rule      RenameRule
+		expStatus int
+		expHeader map[string][]string
+		expLog    string
+	}{
+		{
+			desc:      "Should fail on a runtime error by default",
+			rule:      RenameRule{ExistingHeaderName: "X-Broken", NewHeaderName: "X-Fixed", ValueTemplate: `{{ index .Value 10 }}`},
+			expStatus: http.StatusInternalServerError,
+			expHeader: map[string][]string{"X-Old": nil, "X-New": nil},
+		},
+		{
+			desc:      "Should pass the response through without renames with failOpen",
+			failOpen:  true,
+			rule:      RenameRule{ExistingHeaderName: "X-Broken", NewHeaderName: "X-Fixed", ValueTemplate: `{{ index .Value 10 }}`},
+			expStatus: http.StatusOK,
+			expHeader: map[string][]string{"X-Old": {"1"}, "X-New": nil, "X-Broken": {"b"}, "X-Fixed": nil, "X-Added": {"1"}},
+			expLog:    `level=error plugin=renameHeaders middleware="rewriteHeader" direction=response action=fail-open error=`,
+		},
+		{
+			desc:      "Should still fail on conflicts with failOpen",
+			failOpen:  true,
+			rule:      RenameRule{ExistingHeaderName: "X-Broken", NewHeaderName: "X-Taken", OnConflict: ConflictFail},
+			expStatus: http.StatusInternalServerError,
+			expHeader: map[string][]string{"X-Old": nil, "X-New": nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				FailOpen: test.failOpen,
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+					test.rule,
+				},
+				AddHeaders: map[string]string{"X-Added": "1"},
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Old", "1")
+				rw.Header().Set("X-Broken", "b")
+				rw.Header().Set("X-Taken", "t")
+				rw.WriteHeader(http.StatusOK)
+				_, _ = rw.Write([]byte("body"))
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var log bytes.Buffer
+			handler.(*renameHeaders).errorOutput = &log
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != test.expStatus {
+				t.Errorf("expected status %d, got %d", test.expStatus, recorder.Code)
+			}
+			for k, expected := range test.expHeader {
+				if values := recorder.Result().Header[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+			if !strings.HasPrefix(log.String(), test.expLog) || (test.expLog == "") != (log.Len() == 0) {
+				t.Errorf("unexpected log %q", log.String())
+			}
+			if test.expStatus == http.StatusOK && recorder.Body.String() != "body" {
+				t.Errorf("unexpected body %q", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestFailOpenKeepsRemovals(t *testing.T) {
+	config := &Config{
+		FailOpen: true,
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Client", NewHeaderName: "X-Client-Id", ValueTemplate: `{{ index .Value 10 }}`},
+		},
+		RequestRemoveHeaders: []string{"X-Internal-*"},
+		JWTClaims: &JWTClaims{
+			Claims: map[string]string{"sub": "X-User-Id"},
+			Secret: "secret",
+		},
+		RemoveHopByHop: true,
+		RenameData:     []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	handler.(*renameHeaders).errorOutput = &log
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Client", "c")
+	req.Header.Set("X-Internal-Secret", "leak")
+	req.Header.Set("X-User-Id", "admin")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK || forwarded == nil {
+		t.Fatalf("request not forwarded: status %d", recorder.Code)
+	}
+	expRequest := map[string][]string{
+		"X-Client":          {"c"},
+		"X-Client-Id":       nil,
+		"X-Internal-Secret": nil,
+		"X-User-Id":         nil,
+		"Keep-Alive":        nil,
+	}
+	for k, expected := range expRequest {
+		if values := forwarded[k]; !testEq(values, expected) {
+			t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+	if !strings.Contains(log.String(), "direction=request action=fail-open") {
+		t.Errorf("unexpected log %q", log.String())
+	}
+}
+
+func TestFailOpenRequestPanic(t *testing.T) {
+	config := &Config{
+		FailOpen: true,
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Client", NewHeaderName: "X-Client-Id"},
+		},
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+		},
+	}
+
+	var forwarded *http.Request
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req
+		rw.Header().Set("X-Old", "1")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	rename := handler.(*renameHeaders)
+	rename.errorOutput = &log
+	rename.preset = func(header http.Header) {
+		header.Set("X-Preset", "1")
+		panic("boom")
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Client", "c")
+	handler.ServeHTTP(recorder, req)
+
+	if forwarded == nil {
+		t.Fatal("request not forwarded")
+	}
+	expRequest := map[string][]string{"X-Client": {"c"}, "X-Client-Id": nil, "X-Preset": nil}
+	for k, expected := range expRequest {
+		if values := forwarded.Header[k]; !testEq(values, expected) {
+			t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+	// The response rules still apply.
+	if values := recorder.Result().Header["X-New"]; !testEq(values, []string{"1"}) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", []string{"1"}, values)
+	}
+	expected := `level=error plugin=renameHeaders middleware="rewriteHeader" direction=request action=fail-open error="panic: boom"` + "\n"
+	if log.String() != expected {
+		t.Errorf("expect: %q, result: %q", expected, log.String())
+	}
+}
+
+func TestSnapshotRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?token=secret&page=2", nil)
+	req.Header.Set("X-Client", "c")
+
+	restore := snapshotRequest(req)
+	queryToHeaders(req, []QueryMapping{{QueryParam: "token", HeaderName: "X-Token"}})
+	req.Header.Del("X-Client")
+	restore()
+
+	if values := req.Header["X-Client"]; !testEq(values, []string{"c"}) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", []string{"c"}, values)
+	}
+	if values := req.Header["X-Token"]; values != nil {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", nil, values)
+	}
+	if req.URL.RawQuery != "token=secret&page=2" || req.RequestURI != "/?token=secret&page=2" {
+		t.Errorf("unexpected query %q, request URI %q", req.URL.RawQuery, req.RequestURI)
+	}
+}
@@ -0,0 +1,99 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Modes of HeaderFilter.
+const (
+	// FilterAllow drops every header but the listed ones.
+	FilterAllow = "allow"
+	// FilterDeny drops the listed headers.
+	FilterDeny = "deny"
+)
+
+// essentialHeaders are the response headers a filter never drops, as
+// clients cannot read responses, redirects or gRPC statuses without them.
+var essentialHeaders = []string{
+	"Connection",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"Date",
+	"Grpc-Message",
+	"Grpc-Status",
+	"Location",
+	"Trailer",
+	"Transfer-Encoding",
+}
+
+// HeaderFilter curates the headers of responses once renames and all other
+// changes are applied.
+type HeaderFilter struct {
+	// Mode is FilterAllow or FilterDeny.
+	Mode string `json:"mode"`
+	// Headers lists the allowed or denied header names, which may contain
+	// wildcards.
+	Headers []string `json:"headers"`
+}
+
+// headerFilter is a HeaderFilter prepared for use.
+type headerFilter struct {
+	allow   bool
+	headers []headerMatcher
+}
+
+// compileHeaderFilter validates the filter options.
+func compileHeaderFilter(f *HeaderFilter) (*headerFilter, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	compiled := &headerFilter{}
+	switch f.Mode {
+	case FilterAllow:
+		compiled.allow = true
+	case FilterDeny:
+	default:
+		return nil, fmt.Errorf("responseFilter: unknown mode %q, expected %s or %s", f.Mode, FilterAllow, FilterDeny)
+	}
+	if f.Mode == FilterDeny && len(f.Headers) == 0 {
+		return nil, errors.New("responseFilter: deny mode requires headers")
+	}
+
+	var err error
+	compiled.headers, err = compileHeaderMatchers(f.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("responseFilter: %w", err)
+	}
+	return compiled, nil
+}
+
+// apply drops the filtered headers of a response with status. Responses
+// switching protocols are left untouched, as the upgrade needs their headers.
+func (f *headerFilter) apply(header http.Header, status int) {
+	if f == nil || status == http.StatusSwitchingProtocols {
+		return
+	}
+	for name := range header {
+		if containsFold(essentialHeaders, name) {
+			continue
+		}
+		if f.listed(name) != f.allow {
+			delete(header, name)
+		}
+	}
+}
+
+// listed reports whether name is one of the filter headers.
+func (f *headerFilter) listed(name string) bool {
+	for _, m := range f.headers {
+		if m.matches(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseFilter(t *testing.T) {
+	tests := []struct {
+		desc      string
+		filter    *HeaderFilter
+		expHeader map[string][]string
+	}{
+		{
+			desc:   "Should only keep allowed and essential headers",
+			filter: &HeaderFilter{Mode: FilterAllow, Headers: []string{"X-New", "X-Public-*"}},
+			expHeader: map[string][]string{
+				"X-Old":          nil,
+				"X-New":          {"1"},
+				"X-Public-Build": {"42"},
+				"X-Internal":     nil,
+				"Server":         nil,
+				"X-Added":        nil,
+				"Content-Type":   {"text/plain"},
+				"Content-Length": {"4"},
+			},
+		},
+		{
+			desc:   "Should drop denied headers but essential ones",
+			filter: &HeaderFilter{Mode: FilterDeny, Headers: []string{"X-Internal", "Server", "Content-*"}},
+			expHeader: map[string][]string{
+				"X-New":          {"1"},
+				"X-Public-Build": {"42"},
+				"X-Internal":     nil,
+				"Server":         nil,
+				"X-Added":        {"a"},
+				"Content-Type":   {"text/plain"},
+				"Content-Length": {"4"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+				},
+				AddHeaders:     map[string]string{"X-Added": "a"},
+				ResponseFilter: test.filter,
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Old", "1")
+				rw.Header().Set("X-Public-Build", "42")
+				rw.Header().Set("X-Internal", "secret")
+				rw.Header().Set("Server", "backend/1.0")
+				rw.Header().Set("Content-Type", "text/plain")
+				rw.Header().Set("Content-Length", "4")
+				rw.WriteHeader(http.StatusOK)
+				_, _ = rw.Write([]byte("body"))
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for k, expected := range test.expHeader {
+				if values := recorder.Result().Header[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseFilterSwitchingProtocols(t *testing.T) {
+	filter, err := compileHeaderFilter(&HeaderFilter{Mode: FilterAllow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{"Upgrade": {"websocket"}, "Sec-Websocket-Accept": {"abc"}}
+	filter.apply(header, http.StatusSwitchingProtocols)
+	if len(header) != 2 {
+		t.Errorf("upgrade headers were filtered: %+v", header)
+	}
+	filter.apply(header, http.StatusOK)
+	if len(header) != 0 {
+		t.Errorf("headers were not filtered: %+v", header)
+	}
+}
+
+func TestResponseFilterErrors(t *testing.T) {
+	for _, filter := range []*HeaderFilter{
+		{Mode: "allowOnly", Headers: []string{"X-New"}},
+		{Mode: FilterDeny},
+		{Mode: FilterAllow, Headers: []string{""}},
+	} {
+		config := &Config{
+			RenameData:     []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}},
+			ResponseFilter: filter,
+		}
+		if _, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader"); err == nil {
+			t.Errorf("%+v: expected an error", filter)
+		}
+	}
+}
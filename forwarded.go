@@ -0,0 +1,169 @@
+package traefik_header_rename_plugin
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Presets converting between RFC 7239 Forwarded and the X-Forwarded-* headers.
+const (
+	// PresetForwarded adds a Forwarded header built from X-Forwarded-For,
+	// X-Forwarded-Proto and X-Forwarded-Host.
+	PresetForwarded = "x-forwarded-to-forwarded"
+	// PresetXForwarded adds the X-Forwarded-* headers from Forwarded.
+	PresetXForwarded = "forwarded-to-x-forwarded"
+)
+
+// xForwardedToForwarded sets Forwarded from the X-Forwarded-* headers, unless
+// the request carries a Forwarded header already. Each address in
+// X-Forwarded-For becomes one element; proto and host describe the request
+// received from the client and go into the first.
+func xForwardedToForwarded(header http.Header) {
+	if header.Get("Forwarded") != "" {
+		return
+	}
+
+	var addrs []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	proto := strings.TrimSpace(header.Get("X-Forwarded-Proto"))
+	host := strings.TrimSpace(header.Get("X-Forwarded-Host"))
+	if len(addrs) == 0 && proto == "" && host == "" {
+		return
+	}
+	if len(addrs) == 0 {
+		addrs = []string{""}
+	}
+
+	elements := make([]string, len(addrs))
+	for i, addr := range addrs {
+		var pairs []string
+		if addr != "" {
+			pairs = append(pairs, "for="+forwardedValue(forwardedNode(addr)))
+		}
+		if i == 0 && proto != "" {
+			pairs = append(pairs, "proto="+forwardedValue(proto))
+		}
+		if i == 0 && host != "" {
+			pairs = append(pairs, "host="+forwardedValue(host))
+		}
+		elements[i] = strings.Join(pairs, ";")
+	}
+	header.Set("Forwarded", strings.Join(elements, ", "))
+}
+
+// forwardedNode brackets IPv6 addresses, as RFC 7239 requires.
+func forwardedNode(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && strings.Contains(addr, ":") {
+		return "[" + addr + "]"
+	}
+	return addr
+}
+
+// forwardedValue quotes value unless it is a token.
+func forwardedValue(value string) string {
+	if isToken(value) {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// forwardedAddr returns the address of a for= node, without the brackets of
+// an IPv6 address and without port, as X-Forwarded-For lists them.
+func forwardedAddr(node string) string {
+	if net.ParseIP(node) != nil {
+		return node
+	}
+	if strings.HasPrefix(node, "[") {
+		if end := strings.IndexByte(node, ']'); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if addr, _, ok := strings.Cut(node, ":"); ok {
+		return addr
+	}
+	return node
+}
+
+// forwardedToXForwarded sets the X-Forwarded-* headers from Forwarded. Headers
+// the request carries already are kept.
+func forwardedToXForwarded(header http.Header) {
+	var addrs []string
+	var proto, host string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range splitQuoted(value, ',') {
+			for _, pair := range splitQuoted(element, ';') {
+				key, value, ok := strings.Cut(pair, "=")
+				if !ok {
+					continue
+				}
+				value = unquoteForwarded(strings.TrimSpace(value))
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "for":
+					addrs = append(addrs, forwardedAddr(value))
+				case "proto":
+					if proto == "" {
+						proto = value
+					}
+				case "host":
+					if host == "" {
+						host = value
+					}
+				}
+			}
+		}
+	}
+
+	if len(addrs) > 0 && header.Get("X-Forwarded-For") == "" {
+		header.Set("X-Forwarded-For", strings.Join(addrs, ", "))
+	}
+	if proto != "" && header.Get("X-Forwarded-Proto") == "" {
+		header.Set("X-Forwarded-Proto", proto)
+	}
+	if host != "" && header.Get("X-Forwarded-Host") == "" {
+		header.Set("X-Forwarded-Host", host)
+	}
+}
+
+// splitQuoted splits s at sep outside of quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquoteForwarded removes the quotes of a quoted-string value.
+func unquoteForwarded(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	var b strings.Builder
+	for i := 1; i < len(value)-1; i++ {
+		if value[i] == '\\' && i+1 < len(value)-1 {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestForwardedPresets(t *testing.T) {
+	tests := []struct {
+		desc      string
+		preset    string
+		reqHeader http.Header
+		expHeader http.Header
+	}{
+		{
+			desc:   "Should build Forwarded from X-Forwarded-*",
+			preset: PresetForwarded,
+			reqHeader: http.Header{
+				"X-Forwarded-For":   {"192.0.2.60, 2001:db8::1", "198.51.100.17:4711"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"example.com"},
+			},
+			expHeader: http.Header{
+				"Forwarded":       {`for=192.0.2.60;proto=https;host=example.com, for="[2001:db8::1]", for="198.51.100.17:4711"`},
+				"X-Forwarded-For": {"192.0.2.60, 2001:db8::1", "198.51.100.17:4711"},
+			},
+		},
+		{
+			desc:      "Should keep an existing Forwarded header",
+			preset:    PresetForwarded,
+			reqHeader: http.Header{"Forwarded": {"for=192.0.2.43"}, "X-Forwarded-For": {"192.0.2.60"}},
+			expHeader: http.Header{"Forwarded": {"for=192.0.2.43"}},
+		},
+		{
+			desc:      "Should build Forwarded without X-Forwarded-For",
+			preset:    PresetForwarded,
+			reqHeader: http.Header{"X-Forwarded-Proto": {"http"}},
+			expHeader: http.Header{"Forwarded": {"proto=http"}},
+		},
+		{
+			desc:   "Should build X-Forwarded-* from Forwarded",
+			preset: PresetXForwarded,
+			reqHeader: http.Header{
+				"Forwarded": {`for=192.0.2.60;proto=https;host="example.com:8443", for="[2001:db8::1]"`, `For="_hidden, \"x\""`},
+			},
+			expHeader: http.Header{
+				"X-Forwarded-For":   {`192.0.2.60, 2001:db8::1, _hidden, "x"`},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"example.com:8443"},
+			},
+		},
+		{
+			desc:   "Should drop the ports of Forwarded nodes",
+			preset: PresetXForwarded,
+			reqHeader: http.Header{
+				"Forwarded": {`for="[2001:db8::1]:4711", for="1.2.3.4:80", for="_proxy:8080"`},
+			},
+			expHeader: http.Header{
+				"X-Forwarded-For": {"2001:db8::1, 1.2.3.4, _proxy"},
+			},
+		},
+		{
+			desc:      "Should keep existing X-Forwarded-* headers",
+			preset:    PresetXForwarded,
+			reqHeader: http.Header{"Forwarded": {"for=192.0.2.60;proto=https"}, "X-Forwarded-Proto": {"http"}},
+			expHeader: http.Header{"X-Forwarded-For": {"192.0.2.60"}, "X-Forwarded-Proto": {"http"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			convert, err := compilePresets(test.preset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			convert(test.reqHeader)
+
+			for k, expected := range test.expHeader {
+				if values := test.reqHeader[k]; !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestCombinedPresets(t *testing.T) {
+	convert, err := compilePresets(PresetB3ToW3C + ", " + PresetForwarded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{
+		"B3":              {"64fe8b2a57d3eff7-e457b5a2e4d86bd1-1"},
+		"X-Forwarded-For": {"192.0.2.60"},
+	}
+	convert(header)
+
+	if header.Get("Traceparent") == "" || header.Get("Forwarded") != "for=192.0.2.60" {
+		t.Errorf("expected both presets to apply, got %+v", header)
+	}
+}
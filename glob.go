@@ -0,0 +1,146 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// globPattern matches header names against a pattern where each "*" stands for
+// one non-empty segment. Matching is case-insensitive, as header names are.
+type globPattern struct {
+	// parts holds the literal text between wildcards, so a pattern with n
+	// wildcards has n+1 parts.
+	parts []string
+}
+
+// isGlob reports whether a header name should be treated as a glob pattern.
+func isGlob(name string) bool {
+	return strings.Contains(name, "*")
+}
+
+// compileGlob parses a glob pattern such as "X-*-Old-*".
+func compileGlob(pattern string) (*globPattern, error) {
+	if strings.Contains(pattern, "**") {
+		return nil, fmt.Errorf("pattern %q contains adjacent wildcards", pattern)
+	}
+	return &globPattern{parts: strings.Split(pattern, "*")}, nil
+}
+
+// wildcards returns the number of wildcards in the pattern.
+func (g *globPattern) wildcards() int {
+	return len(g.parts) - 1
+}
+
+// match reports whether name matches the pattern and returns the text captured
+// by each wildcard, in order.
+func (g *globPattern) match(name string) ([]string, bool) {
+	if !hasPrefixFold(name, g.parts[0]) {
+		return nil, false
+	}
+	return g.matchFrom(name[len(g.parts[0]):], 1, make([]string, 0, g.wildcards()))
+}
+
+func (g *globPattern) matchFrom(s string, i int, captures []string) ([]string, bool) {
+	if i == len(g.parts) {
+		return captures, s == ""
+	}
+
+	literal := g.parts[i]
+	for n := 1; n <= len(s)-len(literal); n++ {
+		// The last wildcard has to reach the end of the name.
+		if i == len(g.parts)-1 && n != len(s)-len(literal) {
+			continue
+		}
+		if !hasPrefixFold(s[n:], literal) {
+			continue
+		}
+		if c, ok := g.matchFrom(s[n+len(literal):], i+1, append(captures, s[:n])); ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// hasPrefixFold is strings.HasPrefix under ASCII case folding.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// targetTemplate is a new header name that may reference captured segments
+// positionally with $1, $2, ... or, in order, with "*".
+type targetTemplate struct {
+	// literals and refs alternate: literals[0], refs[0], literals[1], ...
+	literals []string
+	refs     []int
+}
+
+// compileTarget parses a target name and checks that it references exactly the
+// segments captured by a pattern with the given number of wildcards.
+func compileTarget(target string, wildcards int) (*targetTemplate, error) {
+	t := &targetTemplate{}
+	seen := make(map[int]bool)
+
+	stars := 0
+	var literal strings.Builder
+	for i := 0; i < len(target); i++ {
+		if target[i] == '*' {
+			if len(seen) > stars {
+				return nil, fmt.Errorf("target %q mixes \"*\" and \"$\" segment references", target)
+			}
+			stars++
+			if stars > wildcards {
+				return nil, fmt.Errorf("target %q has more wildcards than the %d of the pattern", target, wildcards)
+			}
+			seen[stars] = true
+
+			t.literals = append(t.literals, literal.String())
+			t.refs = append(t.refs, stars)
+			literal.Reset()
+			continue
+		}
+		if target[i] != '$' {
+			literal.WriteByte(target[i])
+			continue
+		}
+		if stars > 0 {
+			return nil, fmt.Errorf("target %q mixes \"*\" and \"$\" segment references", target)
+		}
+
+		j := i + 1
+		for j < len(target) && target[j] >= '0' && target[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			return nil, fmt.Errorf("target %q: \"$\" must be followed by a segment number", target)
+		}
+
+		ref, _ := strconv.Atoi(target[i+1 : j])
+		if ref < 1 || ref > wildcards {
+			return nil, fmt.Errorf("target %q: $%d does not refer to one of the %d wildcard segments of the pattern", target, ref, wildcards)
+		}
+		seen[ref] = true
+
+		t.literals = append(t.literals, literal.String())
+		t.refs = append(t.refs, ref)
+		literal.Reset()
+		i = j - 1
+	}
+	t.literals = append(t.literals, literal.String())
+
+	if len(seen) != wildcards {
+		return nil, fmt.Errorf("target %q uses %d of the %d wildcard segments of the pattern", target, len(seen), wildcards)
+	}
+	return t, nil
+}
+
+// expand builds the new header name from the captured segments.
+func (t *targetTemplate) expand(captures []string) string {
+	var b strings.Builder
+	for i, ref := range t.refs {
+		b.WriteString(t.literals[i])
+		b.WriteString(captures[ref-1])
+	}
+	b.WriteString(t.literals[len(t.literals)-1])
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package traefik_header_rename_plugin
+
+import (
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		match    bool
+		captures []string
+	}{
+		{pattern: "X-*-Old-*", name: "X-Foo-Old-Bar", match: true, captures: []string{"Foo", "Bar"}},
+		{pattern: "X-*-Old-*", name: "x-foo-old-bar", match: true, captures: []string{"foo", "bar"}},
+		{pattern: "X-*-Old-*", name: "X-A-B-Old-C", match: true, captures: []string{"A-B", "C"}},
+		{pattern: "X-*-Old-*", name: "X--Old-Bar", match: false},
+		{pattern: "X-*-Old-*", name: "X-Foo-Old-", match: false},
+		{pattern: "X-*-Old", name: "X-Foo-Old-Bar", match: false},
+		{pattern: "*-Id", name: "X-Request-Id", match: true, captures: []string{"X-Request"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.name, func(t *testing.T) {
+			glob, err := compileGlob(test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			captures, ok := glob.match(test.name)
+			if ok != test.match {
+				t.Fatalf("expected match %v, got %v", test.match, ok)
+			}
+			if !testEq(captures, test.captures) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.captures, captures)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package traefik_header_rename_plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GRPC adapts renames to gRPC traffic, recognized by an application/grpc
+// request content type. It applies to request metadata, response metadata
+// and trailers, including trailers-only responses that carry Grpc-Status in
+// their headers.
+type GRPC struct {
+	// Metadata renames metadata keys. Values are base64 decoded when a
+	// binary key, ending with "-bin", is renamed to a text key, and encoded
+	// the other way round. Decoded values that are not printable text are
+	// dropped.
+	Metadata []GRPCMetadataRename `json:"metadata"`
+	// StatusMap rewrites Grpc-Status codes, such as "14" to "13".
+	StatusMap map[string]string `json:"statusMap"`
+	// Messages replaces the Grpc-Message of responses by the status they
+	// have once StatusMap applies, hiding internal error details.
+	Messages map[string]string `json:"messages"`
+}
+
+// GRPCMetadataRename renames one gRPC metadata key.
+type GRPCMetadataRename struct {
+	ExistingKey string `json:"existingKey"`
+	NewKey      string `json:"newKey"`
+}
+
+// grpcRules is a GRPC prepared for use, with canonical header names.
+type grpcRules struct {
+	metadata  []GRPCMetadataRename
+	statusMap map[string]string
+	messages  map[string]string
+}
+
+// compileGRPC validates the gRPC options.
+func compileGRPC(g *GRPC) (*grpcRules, error) {
+	if g == nil {
+		return nil, nil
+	}
+
+	compiled := &grpcRules{
+		statusMap: make(map[string]string, len(g.StatusMap)),
+		messages:  make(map[string]string, len(g.Messages)),
+	}
+	seen := make(map[string]bool, len(g.Metadata))
+	for i, rename := range g.Metadata {
+		for _, key := range []string{rename.ExistingKey, rename.NewKey} {
+			if !isToken(key) {
+				return nil, fmt.Errorf("grpc metadata %d: invalid key %q", i, key)
+			}
+			if hasPrefixFold(key, "grpc-") {
+				return nil, fmt.Errorf("grpc metadata %d: key %q is reserved by gRPC", i, key)
+			}
+		}
+		existing := http.CanonicalHeaderKey(rename.ExistingKey)
+		if seen[existing] {
+			return nil, fmt.Errorf("grpc metadata %d: key %q is renamed twice", i, rename.ExistingKey)
+		}
+		seen[existing] = true
+		compiled.metadata = append(compiled.metadata, GRPCMetadataRename{
+			ExistingKey: existing,
+			NewKey:      http.CanonicalHeaderKey(rename.NewKey),
+		})
+	}
+	for from, to := range g.StatusMap {
+		if !isGRPCStatus(from) || !isGRPCStatus(to) {
+			return nil, fmt.Errorf("grpc statusMap: invalid status %q -> %q", from, to)
+		}
+		compiled.statusMap[from] = to
+	}
+	for status, message := range g.Messages {
+		if !isGRPCStatus(status) {
+			return nil, fmt.Errorf("grpc messages: invalid status %q", status)
+		}
+		compiled.messages[status] = encodeGRPCMessage(message)
+	}
+	return compiled, nil
+}
+
+// isGRPCStatus reports whether s is a gRPC status code, from 0 to 16.
+func isGRPCStatus(s string) bool {
+	code, err := strconv.Atoi(s)
+	return err == nil && code >= 0 && code <= 16 && strconv.Itoa(code) == s
+}
+
+// isGRPC reports whether the request is a gRPC call. gRPC-Web, which sends
+// trailers in the body, is not.
+func isGRPC(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/grpc" || strings.HasPrefix(mediaType, "application/grpc+"))
+}
+
+// appliesTo reports whether the rules apply to the exchange of req.
+func (g *grpcRules) appliesTo(req *http.Request) bool {
+	return g != nil && isGRPC(req)
+}
+
+// applyMetadata renames the metadata keys of header.
+func (g *grpcRules) applyMetadata(header http.Header) {
+	for _, rename := range g.metadata {
+		values, ok := header[rename.ExistingKey]
+		if !ok {
+			continue
+		}
+		delete(header, rename.ExistingKey)
+		if values = convertMetadata(values, rename.ExistingKey, rename.NewKey); len(values) > 0 {
+			header[rename.NewKey] = values
+		}
+	}
+}
+
+// applyStatus rewrites the Grpc-Status and Grpc-Message of header, when it
+// carries a status.
+func (g *grpcRules) applyStatus(header http.Header) {
+	status := header.Get("Grpc-Status")
+	if status == "" {
+		return
+	}
+	if to, ok := g.statusMap[status]; ok {
+		status = to
+		header.Set("Grpc-Status", status)
+	}
+	if message, ok := g.messages[status]; ok {
+		header.Set("Grpc-Message", message)
+	}
+}
+
+// renameName returns the key a metadata key is renamed to.
+func (g *grpcRules) renameName(name string) (string, bool) {
+	for _, rename := range g.metadata {
+		if rename.ExistingKey == name {
+			return rename.NewKey, true
+		}
+	}
+	return "", false
+}
+
+// isBinaryKey reports whether a metadata key holds base64 encoded binary values.
+func isBinaryKey(key string) bool {
+	return len(key) > 4 && strings.EqualFold(key[len(key)-4:], "-bin")
+}
+
+// convertMetadata converts values between text and binary metadata.
+func convertMetadata(values []string, from, to string) []string {
+	switch {
+	case isBinaryKey(from) == isBinaryKey(to):
+		return values
+	case isBinaryKey(to):
+		encoded := make([]string, len(values))
+		for i, value := range values {
+			encoded[i] = base64.RawStdEncoding.EncodeToString([]byte(value))
+		}
+		return encoded
+	default:
+		decoded := make([]string, 0, len(values))
+		for _, value := range values {
+			// gRPC accepts padded and unpadded values.
+			raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err == nil && isPrintable(raw) {
+				decoded = append(decoded, string(raw))
+			}
+		}
+		return decoded
+	}
+}
+
+// isPrintable reports whether b only holds printable ASCII, as text metadata must.
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeGRPCMessage percent-encodes a Grpc-Message as the gRPC protocol requires.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGRPC(t *testing.T) {
+	config := &Config{
+		GRPC: &GRPC{
+			Metadata: []GRPCMetadataRename{
+				{ExistingKey: "x-debug-bin", NewKey: "x-debug"},
+				{ExistingKey: "x-tenant", NewKey: "x-tenant-bin"},
+			},
+			StatusMap: map[string]string{"14": "13"},
+			Messages:  map[string]string{"13": "internal error: 100%"},
+		},
+	}
+
+	tests := []struct {
+		desc         string
+		contentType  string
+		trailersOnly bool
+		expForwarded http.Header
+		expHeader    http.Header
+		expTrailer   http.Header
+	}{
+		{
+			desc:        "Should rename metadata and rewrite the status in trailers",
+			contentType: "application/grpc+proto",
+			expForwarded: http.Header{
+				"X-Tenant":     nil,
+				"X-Tenant-Bin": {base64.RawStdEncoding.EncodeToString([]byte("acme"))},
+			},
+			expTrailer: http.Header{
+				"Grpc-Status":  {"13"},
+				"Grpc-Message": {"internal error: 100%25"},
+				"X-Debug":      {"trace-2"},
+				"X-Debug-Bin":  nil,
+			},
+		},
+		{
+			desc:         "Should rewrite the status of trailers-only responses",
+			contentType:  "application/grpc",
+			trailersOnly: true,
+			expHeader: http.Header{
+				"Grpc-Status":  {"13"},
+				"Grpc-Message": {"internal error: 100%25"},
+				"X-Debug":      {"trace-1"},
+			},
+		},
+		{
+			desc:        "Should leave other requests alone",
+			contentType: "application/json",
+			expForwarded: http.Header{
+				"X-Tenant": {"acme"},
+			},
+			expTrailer: http.Header{
+				"Grpc-Status": {"14"},
+				"X-Debug-Bin": {base64.RawStdEncoding.EncodeToString([]byte("trace-2"))},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				if test.trailersOnly {
+					rw.Header().Set("X-Debug-Bin", base64.StdEncoding.EncodeToString([]byte("trace-1")))
+					rw.Header().Set("Grpc-Status", "14")
+					rw.Header().Set("Grpc-Message", "connection refused")
+					rw.WriteHeader(http.StatusOK)
+					return
+				}
+				rw.Header().Set("Trailer", "Grpc-Status, Grpc-Message, X-Debug-Bin")
+				rw.WriteHeader(http.StatusOK)
+				_, _ = rw.Write([]byte("message"))
+				rw.Header().Set("Grpc-Status", "14")
+				rw.Header().Set("Grpc-Message", "connection refused")
+				rw.Header().Set("X-Debug-Bin", base64.RawStdEncoding.EncodeToString([]byte("trace-2")))
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+			req.Header.Set("Content-Type", test.contentType)
+			req.Header.Set("X-Tenant", "acme")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			result := recorder.Result()
+
+			for k, expected := range test.expForwarded {
+				if values := forwarded[k]; !testEq(values, expected) {
+					t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+			for k, expected := range test.expHeader {
+				if values := result.Header[k]; !testEq(values, expected) {
+					t.Errorf("header %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+			for k, expected := range test.expTrailer {
+				if values := result.Trailer[k]; !testEq(values, expected) {
+					t.Errorf("trailer %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	for _, g := range []GRPC{
+		{Metadata: []GRPCMetadataRename{{ExistingKey: "grpc-status", NewKey: "x-status"}}},
+		{Metadata: []GRPCMetadataRename{{ExistingKey: "x-a", NewKey: "x b"}}},
+		{StatusMap: map[string]string{"17": "2"}},
+		{Messages: map[string]string{"01": "x"}},
+	} {
+		if _, err := compileGRPC(&g); err == nil {
+			t.Errorf("expected an error for %+v", g)
+		}
+	}
+}
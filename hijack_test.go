@@ -0,0 +1,212 @@
+package traefik_header_rename_plugin
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+)
+
+func TestSwitchingProtocolsBeforeHijack(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Protocol", NewHeaderName: "X-Protocol"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Connection", "Upgrade")
+		rw.Header().Set("Upgrade", "websocket")
+		rw.Header().Set("X-Legacy-Protocol", "chat")
+		rw.WriteHeader(http.StatusSwitchingProtocols)
+
+		conn, _, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if values := resp.Header["X-Protocol"]; !testEq(values, []string{"chat"}) {
+		t.Errorf("expected the header to be renamed before hijacking, got %+v", resp.Header)
+	}
+	if values := resp.Header["X-Legacy-Protocol"]; values != nil {
+		t.Errorf("expected the original header to be removed, got %+v", values)
+	}
+}
+
+func TestSwitchingProtocolsThroughReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conn, brw, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nX-Legacy-Protocol: chat\r\n\r\n")
+		_ = brw.Flush()
+
+		// Echo one line of the upgraded protocol.
+		line, err := brw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		_, _ = brw.WriteString(line)
+		_ = brw.Flush()
+	}))
+	defer backend.Close()
+
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Protocol", NewHeaderName: "X-Protocol"},
+		},
+	}
+	handler, err := New(context.Background(), httputil.NewSingleHostReverseProxy(target), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if values := resp.Header["X-Protocol"]; !testEq(values, []string{"chat"}) {
+		t.Errorf("expected the header to be renamed, got %+v", resp.Header)
+	}
+	if values := resp.Header["X-Legacy-Protocol"]; values != nil {
+		t.Errorf("expected the original header to be removed, got %+v", values)
+	}
+
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "ping\n" {
+		t.Errorf("expected the upgraded connection to pass data through, got %q", line)
+	}
+}
+
+func TestSwitchingProtocolsThroughReverseProxyEdits(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conn, brw, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nX-Internal: secret\r\n\r\n")
+		_ = brw.Flush()
+	}))
+	defer backend.Close()
+
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without rename rules, the head is still edited.
+	config := &Config{
+		RemoveHeaders:   []string{"X-Internal"},
+		SecurityHeaders: &SecurityHeaders{},
+	}
+	handler, err := New(context.Background(), httputil.NewSingleHostReverseProxy(target), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if values := resp.Header["X-Internal"]; values != nil {
+		t.Errorf("expected the header to be removed, got %+v", values)
+	}
+	if value := resp.Header.Get("X-Content-Type-Options"); value != "nosniff" {
+		t.Errorf("expected the security headers to be set, got %+v", resp.Header)
+	}
+	if value := resp.Header.Get("Upgrade"); value != "websocket" {
+		t.Errorf("expected the upgrade to be kept, got %+v", resp.Header)
+	}
+}
@@ -0,0 +1,70 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders are the headers of RFC 7230 that apply to a single
+// connection and must not be forwarded, besides those listed in Connection
+// and the Proxy-* headers.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHopHeaders removes the hop-by-hop headers from header. The
+// Connection and Upgrade headers of a protocol upgrade are kept, as are the
+// trailer declarations of responses, which net/http needs to send trailers,
+// and "TE: trailers" in requests, which gRPC backends require.
+func removeHopByHopHeaders(header http.Header, isResponse bool) {
+	upgrade := isUpgrade(header)
+	teTrailers := !isResponse && hasToken(header, "Te", "trailers")
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !(upgrade && name == "Upgrade") {
+				delete(header, name)
+			}
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		switch {
+		case teTrailers && name == "TE":
+			header.Set(name, "trailers")
+		case upgrade && (name == "Connection" || name == "Upgrade"):
+		case isResponse && name == "Trailer":
+		default:
+			delete(header, http.CanonicalHeaderKey(name))
+		}
+	}
+	for name := range header {
+		if strings.HasPrefix(name, "Proxy-") {
+			delete(header, name)
+		}
+	}
+}
+
+// isUpgrade reports whether header asks for or confirms a protocol upgrade.
+func isUpgrade(header http.Header) bool {
+	return header.Get("Upgrade") != "" && hasToken(header, "Connection", "upgrade")
+}
+
+// hasToken reports whether the comma-separated values of the named header
+// contain token, ignoring case.
+func hasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRemoveHopByHopHeaders(t *testing.T) {
+	tests := []struct {
+		desc       string
+		isResponse bool
+		header     http.Header
+		expHeader  http.Header
+	}{
+		{
+			desc:       "Should remove hop-by-hop and Connection-listed headers",
+			isResponse: true,
+			header: http.Header{
+				"Connection":         {"close, X-Hop"},
+				"X-Hop":              {"1"},
+				"Keep-Alive":         {"timeout=5"},
+				"Transfer-Encoding":  {"chunked"},
+				"Proxy-Authenticate": {"Basic"},
+				"Upgrade":            {"h2c"},
+				"Trailer":            {"Grpc-Status"},
+				"X-Keep":             {"1"},
+			},
+			expHeader: http.Header{
+				"Trailer": {"Grpc-Status"},
+				"X-Keep":  {"1"},
+			},
+		},
+		{
+			desc: "Should keep the headers of a protocol upgrade",
+			header: http.Header{
+				"Connection": {"Upgrade"},
+				"Upgrade":    {"websocket"},
+				"Te":         {"gzip"},
+			},
+			expHeader: http.Header{
+				"Connection": {"Upgrade"},
+				"Upgrade":    {"websocket"},
+			},
+		},
+		{
+			desc: "Should keep TE: trailers in requests",
+			header: http.Header{
+				"Te":                  {"gzip, trailers"},
+				"Trailer":             {"X-Checksum"},
+				"Proxy-Authorization": {"Basic"},
+			},
+			expHeader: http.Header{
+				"Te": {"trailers"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			removeHopByHopHeaders(test.header, test.isResponse)
+
+			if len(test.header) != len(test.expHeader) {
+				t.Errorf("expected %+v, got %+v", test.expHeader, test.header)
+			}
+			for k, expected := range test.expHeader {
+				if values := test.header[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// hostRules selects the rules of a request by its host.
+type hostRules struct {
+	exact    map[string]*ruleIndexes
+	patterns []hostPattern
+}
+
+// hostPattern is a host pattern such as "*.example.com" and its rules.
+type hostPattern struct {
+	pattern string
+	glob    *globPattern
+	indexes *ruleIndexes
+}
+
+// compileHostRules compiles and indexes the rules of each host.
+func (r *renameHeaders) compileHostRules(hosts map[string][]RenameRule) (hostRules, error) {
+	if len(hosts) == 0 {
+		return hostRules{}, nil
+	}
+
+	h := hostRules{exact: make(map[string]*ruleIndexes)}
+	for host, renames := range hosts {
+		if host == "" {
+			return hostRules{}, fmt.Errorf("hostRules: host cannot be empty")
+		}
+		set, err := CompileRules(renames)
+		if err != nil {
+			return hostRules{}, fmt.Errorf("hostRules %q: %w", host, err)
+		}
+		indexes, err := r.indexRules(set, nil)
+		if err != nil {
+			return hostRules{}, fmt.Errorf("hostRules %q: %w", host, err)
+		}
+
+		if !isGlob(host) {
+			h.exact[strings.ToLower(host)] = indexes
+			continue
+		}
+		glob, err := compileGlob(host)
+		if err != nil {
+			return hostRules{}, fmt.Errorf("hostRules: %w", err)
+		}
+		h.patterns = append(h.patterns, hostPattern{pattern: host, glob: glob, indexes: indexes})
+	}
+
+	// Try the most specific patterns first, so that the outcome does not
+	// depend on map order.
+	sort.Slice(h.patterns, func(i, j int) bool {
+		a, b := h.patterns[i].pattern, h.patterns[j].pattern
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return h, nil
+}
+
+// rulesFor returns the rules of the host of req, nil if it has none. Exact
+// hosts take precedence over patterns.
+func (h *hostRules) rulesFor(req *http.Request) *ruleIndexes {
+	if h.exact == nil {
+		return nil
+	}
+	host := strings.ToLower(requestHost(req))
+	if indexes, ok := h.exact[host]; ok {
+		return indexes
+	}
+	for _, p := range h.patterns {
+		if _, ok := p.glob.match(host); ok {
+			return p.indexes
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRules(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Id"}},
+		HostRules: map[string][]RenameRule{
+			"api.acme.example": {{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Acme-Api-Id"}},
+			"*.acme.example":   {{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Acme-Id"}},
+			"*.example":        {{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Tenant-Id"}},
+			"globex.example":   {{ExistingHeaderName: "X-Client", NewHeaderName: "X-Caller", Direction: DirectionRequest}},
+		},
+	}
+
+	tests := []struct {
+		desc          string
+		host          string
+		expReqHeader  string
+		expRespHeader string
+	}{
+		{desc: "Should apply the default rules to other hosts", host: "other.test", expReqHeader: "X-Client", expRespHeader: "X-Id"},
+		{desc: "Should prefer exact hosts", host: "API.acme.example:8443", expReqHeader: "X-Client", expRespHeader: "X-Acme-Api-Id"},
+		{desc: "Should prefer the longest pattern", host: "www.acme.example", expReqHeader: "X-Client", expRespHeader: "X-Acme-Id"},
+		{desc: "Should match shorter patterns", host: "www.initech.example", expReqHeader: "X-Client", expRespHeader: "X-Tenant-Id"},
+		{desc: "Should replace the default rules", host: "globex.example", expReqHeader: "X-Caller", expRespHeader: "X-Upstream-Id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.Header().Set("X-Upstream-Id", "42")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = test.host
+			req.Header.Set("X-Client", "web")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			expected := []string{"web"}
+			if values := forwarded[test.expReqHeader]; !testEq(values, expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+			}
+			expected = []string{"42"}
+			if values := recorder.Header()[test.expRespHeader]; !testEq(values, expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+			}
+		})
+	}
+}
+
+func TestHostRulesErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		hosts map[string][]RenameRule
+	}{
+		{desc: "Should reject empty hosts", hosts: map[string][]RenameRule{"": {{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar"}}}},
+		{desc: "Should reject invalid patterns", hosts: map[string][]RenameRule{"**.example": {{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar"}}}},
+		{desc: "Should reject invalid rules", hosts: map[string][]RenameRule{"a.example": {{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", OnConflict: "merge"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{HostRules: test.hosts}
+			if _, err := New(context.Background(), http.NotFoundHandler(), config, "rewriteHeader"); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
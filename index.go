@@ -0,0 +1,213 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// ruleIndex finds the rules that may apply to a header set without trying
+// every rule, so that the work per response depends on the headers present
+// rather than on the number of rules. Rules still apply in order.
+type ruleIndex struct {
+	rules []*compiledRule
+	// exact maps the key an exact rule looks up to the positions of the
+	// rules looking it up, in order.
+	exact map[string][]int
+	// always holds the positions of the rules tried on every header set:
+	// pattern rules and rules emitting a default value.
+	always []int
+	// next holds, for each exact rule, the positions of the later rules
+	// looking up the key it writes, so that chained renames are followed.
+	next [][]int
+}
+
+// ruleIndexes are the indexes built from one rule set.
+type ruleIndexes struct {
+	// rules, requestRules and trailerRules index the rules applying to
+	// response headers, request headers and response trailers.
+	rules        *ruleIndex
+	requestRules *ruleIndex
+	trailerRules *ruleIndex
+}
+
+// newRuleIndex indexes rules, ordered by decreasing priority and then as
+// listed. The index does not change afterwards, so it can be shared between
+// goroutines.
+func newRuleIndex(rules []*compiledRule) *ruleIndex {
+	rules = append([]*compiledRule(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+
+	x := &ruleIndex{
+		rules: rules,
+		exact: make(map[string][]int),
+		next:  make([][]int, len(rules)),
+	}
+	for i, rule := range rules {
+		if rule.isPattern() || rule.DefaultValue != "" {
+			x.always = append(x.always, i)
+		}
+		if !rule.isPattern() {
+			x.exact[rule.sourceKey()] = append(x.exact[rule.sourceKey()], i)
+		}
+	}
+	for i, rule := range rules {
+		if !rule.isPattern() {
+			x.next[i] = after(x.exact[rule.targetKey(rule.NewHeaderName)], i)
+		}
+	}
+	return x
+}
+
+// empty reports whether the index holds no rules.
+func (x *ruleIndex) empty() bool {
+	return x == nil || len(x.rules) == 0
+}
+
+// apply applies the rules in order and returns the renames performed. Rules
+// whose request conditions do not hold for req are skipped; req is nil when
+// there is no request, and then no rule is skipped. status is the response
+// status code, 0 for requests.
+//
+// The headers a terminal rule renames are held out of header until all rules
+// are applied, so that later rules neither see nor overwrite them.
+func (x *ruleIndex) apply(header http.Header, req *http.Request, status int) ([]RenamedHeader, error) {
+	return x.applyLogged(header, req, status, nil)
+}
+
+// applyLogged is apply reporting the decision of every rule to log, if not
+// nil. Rules not tried are reported as missing their header, failing rules
+// with their error. On a served request, status 0 and req set, a rule failing
+// to decode a value is reported but does not fail the request: the value is
+// left as it is.
+func (x *ruleIndex) applyLogged(header http.Header, req *http.Request, status int, log decisionLog) ([]RenamedHeader, error) {
+	if x.empty() {
+		return nil, nil
+	}
+	var tried []bool
+	if log != nil {
+		tried = make([]bool, len(x.rules))
+		defer func() {
+			for pos, rule := range x.rules {
+				if !tried[pos] {
+					log(rule, nil, reasonNoValue)
+				}
+			}
+		}()
+	}
+
+	candidates := make([]int, 0, len(x.always)+len(header))
+	candidates = append(candidates, x.always...)
+	for key := range header {
+		candidates = append(candidates, x.exact[key]...)
+	}
+	sort.Ints(candidates)
+
+	var applied []RenamedHeader
+	var held http.Header
+	for i := 0; i < len(candidates); i++ {
+		pos := candidates[i]
+		if i > 0 && pos == candidates[i-1] {
+			continue
+		}
+		rule := x.rules[pos]
+		if tried != nil {
+			tried[pos] = true
+		}
+		if req != nil && !rule.matchesRequest(req) {
+			if log != nil {
+				log(rule, nil, reasonRequestConditions)
+			}
+			continue
+		}
+
+		renamed, err := rule.apply(header, req, status)
+		applied = append(applied, renamed...)
+		if log != nil {
+			switch {
+			case err != nil:
+				log(rule, renamed, err.Error())
+			case len(renamed) > 0:
+				log(rule, renamed, "")
+			default:
+				log(rule, nil, rule.skipReason(header, status))
+			}
+		}
+		var invalid *decodeError
+		if err != nil && status == 0 && req != nil && errors.As(err, &invalid) {
+			err = nil
+		}
+		if err != nil {
+			restore(header, held)
+			return applied, err
+		}
+		if rule.Terminal && len(renamed) > 0 {
+			held = hold(header, held, renamed, rule.KeepOriginal)
+			continue
+		}
+
+		// Later rules may look up the headers this rule wrote.
+		for _, p := range x.next[pos] {
+			candidates = insertSorted(candidates, i+1, p)
+		}
+		if rule.isPattern() {
+			for _, rename := range renamed {
+				for _, p := range after(x.exact[rename.To], pos) {
+					candidates = insertSorted(candidates, i+1, p)
+				}
+			}
+		}
+	}
+	restore(header, held)
+	return applied, nil
+}
+
+// hold moves the headers written by renames, and the renamed headers when
+// they were kept, from header to held.
+func hold(header, held http.Header, renamed []RenamedHeader, keepOriginal bool) http.Header {
+	if held == nil {
+		held = make(http.Header, len(renamed))
+	}
+	for _, rename := range renamed {
+		names := []string{rename.To}
+		if keepOriginal {
+			names = append(names, rename.From)
+		}
+		for _, name := range names {
+			if values, ok := header[name]; ok {
+				held[name] = values
+				delete(header, name)
+			}
+		}
+	}
+	return held
+}
+
+// restore moves held headers back into header, replacing the values later
+// rules may have written.
+func restore(header, held http.Header) {
+	for name, values := range held {
+		header[name] = values
+	}
+}
+
+// after returns the positions greater than pos.
+func after(positions []int, pos int) []int {
+	i := sort.SearchInts(positions, pos+1)
+	return positions[i:len(positions):len(positions)]
+}
+
+// insertSorted inserts p into the sorted positions from index from on,
+// unless it is there already.
+func insertSorted(positions []int, from, p int) []int {
+	i := from + sort.SearchInts(positions[from:], p)
+	if i < len(positions) && positions[i] == p {
+		return positions
+	}
+	positions = append(positions, 0)
+	copy(positions[i+1:], positions[i:])
+	positions[i] = p
+	return positions
+}
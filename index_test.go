@@ -0,0 +1,180 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRuleIndex(t *testing.T) {
+	tests := []struct {
+		desc      string
+		renames   []RenameRule
+		header    http.Header
+		expHeader http.Header
+	}{
+		{
+			desc: "Should follow renames chained by later rules",
+			renames: []RenameRule{
+				{ExistingHeaderName: "A", NewHeaderName: "B"},
+				{ExistingHeaderName: "B", NewHeaderName: "C"},
+			},
+			header:    http.Header{"A": {"a"}},
+			expHeader: http.Header{"C": {"a"}},
+		},
+		{
+			desc: "Should not apply earlier rules to renamed headers",
+			renames: []RenameRule{
+				{ExistingHeaderName: "B", NewHeaderName: "C"},
+				{ExistingHeaderName: "A", NewHeaderName: "B"},
+			},
+			header:    http.Header{"A": {"a"}},
+			expHeader: http.Header{"B": {"a"}},
+		},
+		{
+			desc: "Should follow exact rules chained after pattern rules",
+			renames: []RenameRule{
+				{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New-$1"},
+				{ExistingHeaderName: "x-new-foo", NewHeaderName: "X-Final"},
+			},
+			header:    http.Header{"X-Old-Foo": {"foo"}, "X-Old-Bar": {"bar"}},
+			expHeader: http.Header{"X-Final": {"foo"}, "X-New-Bar": {"bar"}},
+		},
+		{
+			desc: "Should follow default values into later rules",
+			renames: []RenameRule{
+				{ExistingHeaderName: "A", NewHeaderName: "B", DefaultValue: "default"},
+				{ExistingHeaderName: "B", NewHeaderName: "C"},
+			},
+			header:    http.Header{},
+			expHeader: http.Header{"C": {"default"}},
+		},
+		{
+			desc: "Should apply higher priorities first",
+			renames: []RenameRule{
+				{ExistingHeaderName: "B", NewHeaderName: "C"},
+				{ExistingHeaderName: "A", NewHeaderName: "B", Priority: 10},
+			},
+			header:    http.Header{"A": {"a"}},
+			expHeader: http.Header{"C": {"a"}},
+		},
+		{
+			desc: "Should not chain renames after a terminal rule",
+			renames: []RenameRule{
+				{ExistingHeaderName: "A", NewHeaderName: "B", Terminal: true},
+				{ExistingHeaderName: "B", NewHeaderName: "C"},
+			},
+			header:    http.Header{"A": {"a"}},
+			expHeader: http.Header{"B": {"a"}},
+		},
+		{
+			desc: "Should keep the original header of a terminal rule from later rules",
+			renames: []RenameRule{
+				{ExistingHeaderName: "A", NewHeaderName: "B", KeepOriginal: true, Terminal: true},
+				{ExistingHeaderName: "A", NewHeaderName: "C"},
+				{ExistingHeaderName: "X-*", NewHeaderName: "Y-$1"},
+			},
+			header:    http.Header{"A": {"a"}, "X-Foo": {"foo"}},
+			expHeader: http.Header{"A": {"a"}, "B": {"a"}, "Y-Foo": {"foo"}},
+		},
+		{
+			desc: "Should not let later rules overwrite the output of a terminal rule",
+			renames: []RenameRule{
+				{ExistingHeaderName: "A", NewHeaderName: "B", Terminal: true},
+				{ExistingHeaderName: "D", NewHeaderName: "B"},
+			},
+			header:    http.Header{"A": {"a"}, "D": {"d"}},
+			expHeader: http.Header{"B": {"a"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			rules, err := compileRules(test.renames)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := newRuleIndex(rules).apply(test.header, nil, 0); err != nil {
+				t.Fatal(err)
+			}
+			if len(test.header) != len(test.expHeader) {
+				t.Errorf("expected headers %+v, got %+v", test.expHeader, test.header)
+			}
+			for k, expected := range test.expHeader {
+				if values := test.header[k]; !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkRules returns n exact rules and a response setting a few of the
+// headers they rename.
+func benchmarkRules(n int) ([]RenameRule, http.Header) {
+	renames := make([]RenameRule, 0, n+1)
+	for i := 0; i < n; i++ {
+		renames = append(renames, RenameRule{
+			ExistingHeaderName: fmt.Sprintf("X-Legacy-%d", i),
+			NewHeaderName:      fmt.Sprintf("X-Header-%d", i),
+		})
+	}
+	renames = append(renames, RenameRule{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New-$1"})
+
+	header := http.Header{
+		"Content-Type":  {"text/plain"},
+		"Cache-Control": {"no-cache"},
+		"X-Old-Trace":   {"trace"},
+	}
+	for i := 0; i < n; i += n / 4 {
+		header.Set(fmt.Sprintf("X-Legacy-%d", i), "value")
+	}
+	return renames, header
+}
+
+func BenchmarkRuleIndex(b *testing.B) {
+	for _, n := range []int{10, 100, 400} {
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			renames, header := benchmarkRules(n)
+			rules, err := compileRules(renames)
+			if err != nil {
+				b.Fatal(err)
+			}
+			index := newRuleIndex(rules)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h := header.Clone()
+				if _, err := index.apply(h, nil, http.StatusOK); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	renames, header := benchmarkRules(400)
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		for k, v := range header {
+			rw.Header()[k] = v
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), &Config{RenameData: renames}, "rewriteHeader")
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
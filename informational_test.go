@@ -0,0 +1,75 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func TestEarlyHints(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Link", NewHeaderName: "Link"},
+			{ExistingHeaderName: "X-Legacy-Id", NewHeaderName: "X-Id"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Legacy-Link", "</style.css>; rel=preload; as=style")
+		rw.WriteHeader(http.StatusEarlyHints)
+
+		rw.Header().Set("X-Legacy-Id", "42")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("body"))
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var hints textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = header
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"</style.css>; rel=preload; as=style"}
+	if values := hints["Link"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the final status to be written, got %d", resp.StatusCode)
+	}
+	expected = []string{"42"}
+	if values := resp.Header["X-Id"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	if _, ok := resp.Header["X-Legacy-Id"]; ok {
+		t.Errorf("expected the final headers to be renamed, got %+v", resp.Header)
+	}
+}
@@ -0,0 +1,275 @@
+package traefik_header_rename_plugin
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers crypto.SHA256
+	_ "crypto/sha512" // registers crypto.SHA384 and crypto.SHA512
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JWTClaims maps claims of the JSON Web Token sent by clients into request
+// headers, such as sub into X-User-Id.
+type JWTClaims struct {
+	// Header holds the token, Authorization by default. A "Bearer " prefix is
+	// stripped.
+	Header string `json:"header"`
+	// Claims maps claim names to the request headers receiving their values.
+	// Nested claims are named with dots, such as "realm_access.roles". Array
+	// claims give one value per element. Values sent by the client in these
+	// headers are always removed.
+	Claims map[string]string `json:"claims"`
+	// Secret verifies HS256, HS384 and HS512 signatures, and PublicKey, a PEM
+	// encoded RSA public key, RS256, RS384 and RS512 signatures. One of them
+	// is required unless SkipVerification is set.
+	Secret    string `json:"secret"`
+	PublicKey string `json:"publicKey"`
+	// SkipVerification maps claims without verifying signatures, which is
+	// only safe behind a proxy that verified the token already. Unsigned
+	// tokens, with algorithm none, are rejected nonetheless.
+	SkipVerification bool `json:"skipVerification"`
+}
+
+// jwtClaims is a JWTClaims prepared for use.
+type jwtClaims struct {
+	header string
+	// claims maps each header to the path of its claim.
+	claims           map[string][]string
+	secret           []byte
+	publicKey        *rsa.PublicKey
+	skipVerification bool
+}
+
+// jwtHashes are the hash functions of the supported signature algorithms,
+// by algorithm suffix.
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// compileJWTClaims validates the claim mapping.
+func compileJWTClaims(c *JWTClaims) (*jwtClaims, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if len(c.Claims) == 0 {
+		return nil, errors.New("jwtClaims: at least one claim is required")
+	}
+	if c.Secret != "" && c.PublicKey != "" {
+		return nil, errors.New("jwtClaims: secret and publicKey cannot be combined")
+	}
+	if c.SkipVerification == (c.Secret != "" || c.PublicKey != "") {
+		return nil, errors.New("jwtClaims: exactly one of secret, publicKey and skipVerification is required")
+	}
+
+	compiled := &jwtClaims{
+		header: http.CanonicalHeaderKey(c.Header),
+		claims: make(map[string][]string, len(c.Claims)),
+		secret: []byte(c.Secret),
+
+		skipVerification: c.SkipVerification,
+	}
+	if compiled.header == "" {
+		compiled.header = "Authorization"
+	}
+	for claim, header := range c.Claims {
+		if claim == "" || header == "" {
+			return nil, errors.New("jwtClaims: claim and header names cannot be empty")
+		}
+		header = http.CanonicalHeaderKey(header)
+		if _, ok := compiled.claims[header]; ok {
+			return nil, fmt.Errorf("jwtClaims: header %q receives several claims", header)
+		}
+		compiled.claims[header] = strings.Split(claim, ".")
+	}
+
+	if c.PublicKey != "" {
+		block, _ := pem.Decode([]byte(c.PublicKey))
+		if block == nil {
+			return nil, errors.New("jwtClaims: publicKey is not PEM encoded")
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwtClaims: publicKey: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("jwtClaims: publicKey is not an RSA key")
+		}
+		compiled.publicKey = rsaKey
+	}
+	return compiled, nil
+}
+
+// apply replaces the claim headers of a request by the claims of its token.
+// They are left out when the token is missing, invalid or expired.
+func (j *jwtClaims) apply(header http.Header) {
+	if j == nil {
+		return
+	}
+	for name := range j.claims {
+		delete(header, name)
+	}
+
+	token := header.Get(j.header)
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = token[7:]
+	}
+	claims, err := j.parse(strings.TrimSpace(token), time.Now())
+	if err != nil {
+		return
+	}
+	for name, path := range j.claims {
+		if values := claimValues(lookupClaim(claims, path)); len(values) > 0 {
+			header[name] = values
+		}
+	}
+}
+
+// parse verifies a compact serialized token and returns its claims.
+func (j *jwtClaims) parse(token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, err
+	}
+	if head.Alg == "" || strings.EqualFold(head.Alg, "none") {
+		return nil, errors.New("unsigned token")
+	}
+	if err := j.verify(head.Alg, parts[0]+"."+parts[1], parts[2]); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if exp, ok, err := numericDate(claims, "exp"); err != nil || ok && now.Unix() >= exp {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok, err := numericDate(claims, "nbf"); err != nil || ok && now.Unix() < nbf {
+		return nil, errors.New("token not valid yet")
+	}
+	return claims, nil
+}
+
+// numericDate returns the time claim name in seconds, and whether it is
+// set. A claim that is not a number is an error, so that a token cannot
+// escape expiry with a malformed exp.
+func numericDate(claims map[string]interface{}, name string) (int64, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return 0, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, false, fmt.Errorf("claim %s is not a number", name)
+	}
+	t, err := number.Float64()
+	if err != nil {
+		return 0, false, err
+	}
+	return int64(t), true, nil
+}
+
+// verify checks the signature of a token, unless verification is skipped.
+func (j *jwtClaims) verify(alg, signed, signature string) error {
+	if j.skipVerification {
+		return nil
+	}
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hash, ok := jwtHashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case alg[:2] == "HS" && len(j.secret) > 0:
+		mac := hmac.New(hash.New, j.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case alg[:2] == "RS" && j.publicKey != nil:
+		h := hash.New()
+		h.Write([]byte(signed))
+		return rsa.VerifyPKCS1v15(j.publicKey, hash, h.Sum(nil), sig)
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// lookupClaim follows a path of claim names into nested objects.
+func lookupClaim(claims map[string]interface{}, path []string) interface{} {
+	var value interface{} = claims
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// claimValues formats a claim as header values: one per element of arrays,
+// JSON for objects.
+func claimValues(claim interface{}) []string {
+	switch v := claim.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case json.Number:
+		return []string{v.String()}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, element := range v {
+			values = append(values, claimValues(element)...)
+		}
+		return values
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return []string{string(raw)}
+	}
+}
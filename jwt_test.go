@@ -0,0 +1,243 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// signJWT builds a token from raw JSON segments, signed with sign.
+func signJWT(head, claims string, sign func([]byte) []byte) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(head)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	const claims = `{"sub":"42","admin":true,"realm_access":{"roles":["a","b"]},"exp":4102444800}`
+
+	tests := []struct {
+		desc      string
+		jwt       JWTClaims
+		token     string
+		expHeader http.Header
+	}{
+		{
+			desc:  "Should map claims of a valid token",
+			jwt:   JWTClaims{Secret: "secret"},
+			token: "Bearer " + signJWT(`{"alg":"HS256"}`, claims, hs256("secret")),
+			expHeader: http.Header{
+				"X-User-Id": {"42"},
+				"X-Admin":   {"true"},
+				"X-Roles":   {"a", "b"},
+			},
+		},
+		{
+			desc:  "Should drop claims of a token with an invalid signature",
+			jwt:   JWTClaims{Secret: "secret"},
+			token: "Bearer " + signJWT(`{"alg":"HS256"}`, claims, hs256("other")),
+			expHeader: http.Header{
+				"X-User-Id": nil,
+				"X-Roles":   nil,
+			},
+		},
+		{
+			desc:  "Should drop claims of an unsigned token when verifying",
+			jwt:   JWTClaims{Secret: "secret"},
+			token: "Bearer " + signJWT(`{"alg":"none"}`, claims, func([]byte) []byte { return nil }),
+			expHeader: http.Header{
+				"X-User-Id": nil,
+			},
+		},
+		{
+			desc:  "Should drop claims of an expired token",
+			jwt:   JWTClaims{Secret: "secret"},
+			token: "Bearer " + signJWT(`{"alg":"HS256"}`, `{"sub":"42","exp":946684800}`, hs256("secret")),
+			expHeader: http.Header{
+				"X-User-Id": nil,
+			},
+		},
+		{
+			desc:  "Should drop claims of an unsigned token without verification",
+			jwt:   JWTClaims{SkipVerification: true},
+			token: "Bearer " + signJWT(`{"alg":"none"}`, claims, func([]byte) []byte { return nil }),
+			expHeader: http.Header{
+				"X-User-Id": nil,
+			},
+		},
+		{
+			desc:  "Should drop claims of a token whose expiry is not a number",
+			jwt:   JWTClaims{Secret: "secret"},
+			token: "Bearer " + signJWT(`{"alg":"HS256"}`, `{"sub":"42","exp":"4102444800"}`, hs256("secret")),
+			expHeader: http.Header{
+				"X-User-Id": nil,
+			},
+		},
+		{
+			desc:  "Should drop claims of a token whose not-before is not a number",
+			jwt:   JWTClaims{Secret: "secret"},
+			token: "Bearer " + signJWT(`{"alg":"HS256"}`, `{"sub":"42","nbf":null}`, hs256("secret")),
+			expHeader: http.Header{
+				"X-User-Id": nil,
+			},
+		},
+		{
+			desc:  "Should map claims without verification",
+			jwt:   JWTClaims{Header: "X-Token", SkipVerification: true},
+			token: signJWT(`{"alg":"HS256"}`, claims, hs256("any")),
+			expHeader: http.Header{
+				"X-User-Id": {"42"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			test.jwt.Claims = map[string]string{
+				"sub":                "X-User-Id",
+				"admin":              "X-Admin",
+				"realm_access.roles": "X-Roles",
+			}
+			config := &Config{JWTClaims: &test.jwt}
+
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			header := test.jwt.Header
+			if header == "" {
+				header = "Authorization"
+			}
+			req.Header.Set(header, test.token)
+			req.Header.Set("X-User-Id", "forged")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			for k, expected := range test.expHeader {
+				if values := forwarded[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTClaimsRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := compileJWTClaims(&JWTClaims{
+		Claims:    map[string]string{"sub": "X-User-Id"},
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signJWT(`{"alg":"RS256"}`, `{"sub":"42"}`, func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	})
+
+	for _, test := range []struct {
+		token    string
+		expected []string
+	}{
+		{token: token, expected: []string{"42"}},
+		{token: signJWT(`{"alg":"HS256"}`, `{"sub":"42"}`, hs256("secret")), expected: nil},
+	} {
+		header := http.Header{"Authorization": {"Bearer " + test.token}}
+		j.apply(header)
+		if values := header["X-User-Id"]; !testEq(values, test.expected) {
+			t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+		}
+	}
+}
+
+func TestJWTClaimsWithEdits(t *testing.T) {
+	config := &Config{
+		JWTClaims: &JWTClaims{
+			Claims: map[string]string{"sub": "X-User-Id"},
+			Secret: "secret",
+		},
+		RequestMaskHeaders: []HeaderMask{{Name: "Authorization", KeepFirst: 10}},
+		RequestSignHeaders: []HeaderSignature{{Header: "X-User-Id", Secret: "signing"}},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(`{"alg":"HS256"}`, `{"sub":"42"}`, hs256("secret")))
+	req.Header.Set("X-User-Id", "forged")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := []string{"42"}
+	if values := forwarded["X-User-Id"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	expected = []string{hex.EncodeToString(hs256("signing")([]byte("42")))}
+	if values := forwarded["X-User-Id-Signature"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	if token := forwarded.Get("Authorization"); token[:10] != "Bearer eyJ" || token[10:14] != "****" {
+		t.Errorf("token not masked: %q", token)
+	}
+}
+
+func TestJWTClaimsErrors(t *testing.T) {
+	claims := map[string]string{"sub": "X-User-Id"}
+	for _, c := range []*JWTClaims{
+		{Claims: claims},
+		{Claims: claims, Secret: "secret", SkipVerification: true},
+		{Claims: claims, Secret: "secret", PublicKey: "key"},
+		{Secret: "secret"},
+	} {
+		if _, err := compileJWTClaims(c); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
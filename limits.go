@@ -0,0 +1,184 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Behaviors for header values exceeding a limit.
+const (
+	// LimitTruncate cuts values down to the limit, the default.
+	LimitTruncate = "truncate"
+	// LimitDrop removes headers with values exceeding the limit.
+	LimitDrop = "drop"
+)
+
+// HeaderLimit limits the size of response headers.
+type HeaderLimit struct {
+	// Headers lists the headers the limit applies to. Names may contain
+	// wildcards; all headers are limited when empty.
+	Headers []string `json:"headers"`
+	// MaxValueLength limits the length of each value, in bytes.
+	MaxValueLength int `json:"maxValueLength"`
+	// OnExceed selects what happens to values exceeding MaxValueLength, see
+	// the Limit* constants.
+	OnExceed string `json:"onExceed"`
+	// MaxHeaders and MaxBytes limit the number of limited headers and the sum
+	// of the lengths of their names and values. The largest headers are
+	// dropped until both limits hold.
+	MaxHeaders int `json:"maxHeaders"`
+	MaxBytes   int `json:"maxBytes"`
+	// WarningHeader, when set, lists the headers truncated or dropped.
+	WarningHeader string `json:"warningHeader"`
+}
+
+// headerLimit is a HeaderLimit prepared for matching.
+type headerLimit struct {
+	HeaderLimit
+	matchers []headerMatcher
+}
+
+// compileHeaderLimits validates the configured limits.
+func compileHeaderLimits(limits []HeaderLimit) ([]headerLimit, error) {
+	compiled := make([]headerLimit, 0, len(limits))
+	for i, limit := range limits {
+		if limit.MaxValueLength < 0 || limit.MaxHeaders < 0 || limit.MaxBytes < 0 {
+			return nil, fmt.Errorf("header limit %d: limits cannot be negative", i)
+		}
+		if limit.MaxValueLength == 0 && limit.MaxHeaders == 0 && limit.MaxBytes == 0 {
+			return nil, fmt.Errorf("header limit %d: no limit set", i)
+		}
+		switch limit.OnExceed {
+		case "", LimitTruncate, LimitDrop:
+		default:
+			return nil, fmt.Errorf("header limit %d: invalid onExceed %q", i, limit.OnExceed)
+		}
+
+		matchers, err := compileHeaderMatchers(limit.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("header limit %d: %w", i, err)
+		}
+		limit.Headers = append([]string(nil), limit.Headers...)
+		limit.WarningHeader = http.CanonicalHeaderKey(limit.WarningHeader)
+		compiled = append(compiled, headerLimit{HeaderLimit: limit, matchers: matchers})
+	}
+	return compiled, nil
+}
+
+// matches reports whether the limit applies to the header name.
+func (l *headerLimit) matches(name string) bool {
+	if name == l.WarningHeader {
+		return false
+	}
+	if len(l.matchers) == 0 {
+		return true
+	}
+	for _, m := range l.matchers {
+		if m.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHeaderLimits enforces the limits on header.
+func applyHeaderLimits(header http.Header, limits []headerLimit) {
+	for i := range limits {
+		limits[i].apply(header)
+	}
+}
+
+func (l *headerLimit) apply(header http.Header) {
+	var names []string
+	for name := range header {
+		if l.matches(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var exceeded []string
+	if l.MaxValueLength > 0 {
+		limited := names[:0]
+		for _, name := range names {
+			values, ok := l.limitValues(header[name])
+			if ok {
+				limited = append(limited, name)
+				continue
+			}
+			exceeded = append(exceeded, name)
+			if values == nil {
+				delete(header, name)
+				continue
+			}
+			header[name] = values
+			limited = append(limited, name)
+		}
+		names = limited
+	}
+
+	if l.MaxHeaders > 0 || l.MaxBytes > 0 {
+		size := func(name string) int {
+			n := 0
+			for _, value := range header[name] {
+				n += len(name) + len(value)
+			}
+			return n
+		}
+		total := 0
+		for _, name := range names {
+			total += size(name)
+		}
+
+		// Drop the largest headers first, by name on ties.
+		sort.SliceStable(names, func(i, j int) bool { return size(names[i]) > size(names[j]) })
+		for len(names) > 0 && (l.MaxHeaders > 0 && len(names) > l.MaxHeaders || l.MaxBytes > 0 && total > l.MaxBytes) {
+			total -= size(names[0])
+			delete(header, names[0])
+			exceeded = append(exceeded, names[0])
+			names = names[1:]
+		}
+	}
+
+	if l.WarningHeader != "" && len(exceeded) > 0 {
+		header.Set(l.WarningHeader, strings.Join(exceeded, ", "))
+	}
+}
+
+// limitValues returns values within MaxValueLength and whether they were
+// within it already. It returns nil if the header is to be dropped.
+func (l *headerLimit) limitValues(values []string) ([]string, bool) {
+	within := true
+	for _, value := range values {
+		if len(value) > l.MaxValueLength {
+			within = false
+			break
+		}
+	}
+	if within {
+		return values, true
+	}
+	if l.OnExceed == LimitDrop {
+		return nil, false
+	}
+
+	truncated := make([]string, len(values))
+	for i, value := range values {
+		truncated[i] = truncate(value, l.MaxValueLength)
+	}
+	return truncated, false
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
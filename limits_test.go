@@ -0,0 +1,109 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaderLimits(t *testing.T) {
+	tests := []struct {
+		desc          string
+		limits        []HeaderLimit
+		expRespHeader http.Header
+	}{
+		{
+			desc:   "Should truncate long values",
+			limits: []HeaderLimit{{Headers: []string{"X-Debug-*"}, MaxValueLength: 4, WarningHeader: "X-Header-Limit"}},
+			expRespHeader: http.Header{
+				"X-Debug-Trace":  {"aaaa", "bbbb"},
+				"X-Debug-Short":  {"ok"},
+				"X-Id":           {"0123456789"},
+				"X-Header-Limit": {"X-Debug-Trace"},
+			},
+		},
+		{
+			desc:   "Should not split multi-byte characters",
+			limits: []HeaderLimit{{Headers: []string{"X-Name"}, MaxValueLength: 2}},
+			expRespHeader: http.Header{
+				"X-Name": {"é"},
+			},
+		},
+		{
+			desc:   "Should drop headers with long values",
+			limits: []HeaderLimit{{MaxValueLength: 8, OnExceed: LimitDrop}},
+			expRespHeader: http.Header{
+				"X-Debug-Trace": nil,
+				"X-Id":          nil,
+				"X-Debug-Short": {"ok"},
+			},
+		},
+		{
+			desc:   "Should drop the largest headers over the total size",
+			limits: []HeaderLimit{{Headers: []string{"X-Debug-*", "X-Id"}, MaxBytes: 40, WarningHeader: "X-Header-Limit"}},
+			expRespHeader: http.Header{
+				"X-Debug-Trace":  nil,
+				"X-Debug-Short":  {"ok"},
+				"X-Id":           {"0123456789"},
+				"X-Header-Limit": {"X-Debug-Trace"},
+			},
+		},
+		{
+			desc:   "Should drop the largest headers over the header count",
+			limits: []HeaderLimit{{Headers: []string{"X-Debug-*", "X-Id"}, MaxHeaders: 1}},
+			expRespHeader: http.Header{
+				"X-Debug-Trace": nil,
+				"X-Debug-Short": nil,
+				"X-Id":          {"0123456789"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Add("X-Debug-Trace", strings.Repeat("a", 20))
+				rw.Header().Add("X-Debug-Trace", strings.Repeat("b", 20))
+				rw.Header().Set("X-Debug-Short", "ok")
+				rw.Header().Set("X-Id", "0123456789")
+				rw.Header().Set("X-Name", "é")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), &Config{HeaderLimits: test.limits}, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for k, expected := range test.expRespHeader {
+				if values := recorder.Result().Header[k]; !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileHeaderLimitsErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		limits []HeaderLimit
+	}{
+		{desc: "Should reject a limit without limits", limits: []HeaderLimit{{Headers: []string{"X-Foo"}}}},
+		{desc: "Should reject negative limits", limits: []HeaderLimit{{MaxBytes: -1}}},
+		{desc: "Should reject unknown behaviors", limits: []HeaderLimit{{MaxValueLength: 1, OnExceed: "warn"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileHeaderLimits(test.limits); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
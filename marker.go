@@ -0,0 +1,30 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+)
+
+// markerKey is the context key marking requests processed by a handler with
+// the marker header name. Handlers further down the middleware chain of the
+// process share the request context, so the marker never has to be sent on,
+// and clients cannot set it to turn processing off.
+type markerKey struct {
+	name string
+}
+
+// marked reports whether another handler of this process processes req
+// already, as its marker tells.
+func (r *renameHeaders) marked(req *http.Request) bool {
+	return r.markerHeader != "" && req.Context().Value(markerKey{name: r.markerHeader}) != nil
+}
+
+// mark marks req, so that handlers further down the middleware chain skip it.
+// A marker header sent by the client is removed.
+func (r *renameHeaders) mark(req *http.Request) *http.Request {
+	if r.markerHeader == "" {
+		return req
+	}
+	req.Header.Del(r.markerHeader)
+	return req.WithContext(context.WithValue(req.Context(), markerKey{name: r.markerHeader}, true))
+}
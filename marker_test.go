@@ -0,0 +1,101 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMarkerHeader(t *testing.T) {
+	testCases := []struct {
+		name         string
+		markerHeader string
+		clientMarker string
+		expRequest   []string
+		expResponse  []string
+	}{
+		{
+			name:        "without marker both instances apply",
+			expRequest:  []string{"1", "1"},
+			expResponse: []string{"edge", "edge"},
+		},
+		{
+			name:         "marker skips the inner instance",
+			markerHeader: "X-Renamed-By",
+			expRequest:   []string{"1"},
+			expResponse:  []string{"edge"},
+		},
+		{
+			name:         "client marker is ignored",
+			markerHeader: "X-Renamed-By",
+			clientMarker: "anything",
+			expRequest:   []string{"1"},
+			expResponse:  []string{"edge"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{
+				MarkerHeader: test.markerHeader,
+				RequestRenameData: []RenameRule{
+					{ExistingHeaderName: "X-Client", NewHeaderName: "X-Client-Id"},
+				},
+				RequestAddHeaders: map[string]string{"X-Seen": "1"},
+				AddHeaders:        map[string]string{"X-Via": "edge"},
+			}
+
+			var forwarded http.Header
+			backend := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.Header().Set("X-Renamed-By", "echoed")
+				rw.WriteHeader(http.StatusOK)
+			})
+			inner, err := New(context.Background(), backend, config, "router")
+			if err != nil {
+				t.Fatal(err)
+			}
+			outer, err := New(context.Background(), inner, config, "entrypoint")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Client", "c")
+			if test.clientMarker != "" {
+				req.Header.Set(test.markerHeader, test.clientMarker)
+			}
+			recorder := httptest.NewRecorder()
+			outer.ServeHTTP(recorder, req)
+
+			if values := forwarded["X-Seen"]; !testEq(values, test.expRequest) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expRequest, values)
+			}
+			if values := forwarded["X-Client-Id"]; !testEq(values, []string{"c"}) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", []string{"c"}, values)
+			}
+			if values := recorder.Result().Header["X-Via"]; !testEq(values, test.expResponse) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expResponse, values)
+			}
+			if test.markerHeader != "" {
+				if values := forwarded[test.markerHeader]; values != nil {
+					t.Errorf("marker sent to the backend: %+v", values)
+				}
+				if values := recorder.Result().Header[test.markerHeader]; values != nil {
+					t.Errorf("marker not removed from the response: %+v", values)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkerHeaderInvalid(t *testing.T) {
+	config := &Config{
+		MarkerHeader: "X Marker",
+		RenameData:   []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}},
+	}
+	if _, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader"); err == nil {
+		t.Error("expected an error for an invalid marker header")
+	}
+}
@@ -0,0 +1,79 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HeaderMask hides the values of a header, keeping them visible for correlation.
+type HeaderMask struct {
+	// Name is the header to mask and may contain wildcards.
+	Name string `json:"name"`
+	// KeepFirst and KeepLast are the numbers of leading and trailing
+	// characters left visible. Values too short to hide anything are masked
+	// entirely.
+	KeepFirst int `json:"keepFirst"`
+	KeepLast  int `json:"keepLast"`
+	// MaskChar replaces each hidden character, "*" by default.
+	MaskChar string `json:"maskChar"`
+}
+
+// headerMask is a HeaderMask prepared for matching.
+type headerMask struct {
+	HeaderMask
+	matcher headerMatcher
+}
+
+// compileHeaderMasks validates the configured masks.
+func compileHeaderMasks(masks []HeaderMask) ([]headerMask, error) {
+	compiled := make([]headerMask, 0, len(masks))
+	for i, mask := range masks {
+		matchers, err := compileHeaderMatchers([]string{mask.Name})
+		if err != nil {
+			return nil, fmt.Errorf("mask %d: %w", i, err)
+		}
+		if mask.KeepFirst < 0 || mask.KeepLast < 0 {
+			return nil, fmt.Errorf("mask %d: keepFirst and keepLast cannot be negative", i)
+		}
+		if mask.MaskChar == "" {
+			mask.MaskChar = "*"
+		}
+		if len([]rune(mask.MaskChar)) != 1 {
+			return nil, fmt.Errorf("mask %d: maskChar %q must be a single character", i, mask.MaskChar)
+		}
+		compiled = append(compiled, headerMask{HeaderMask: mask, matcher: matchers[0]})
+	}
+	return compiled, nil
+}
+
+// maskHeaders masks the values of the headers matched by any of the masks.
+// The first matching mask applies.
+func maskHeaders(header http.Header, masks []headerMask) {
+	if len(masks) == 0 {
+		return
+	}
+	for name, values := range header {
+		for _, mask := range masks {
+			if !mask.matcher.matches(name) {
+				continue
+			}
+			masked := make([]string, len(values))
+			for i, value := range values {
+				masked[i] = mask.apply(value)
+			}
+			header[name] = masked
+			break
+		}
+	}
+}
+
+// apply masks one value.
+func (m *headerMask) apply(value string) string {
+	runes := []rune(value)
+	if m.KeepFirst+m.KeepLast >= len(runes) {
+		return strings.Repeat(m.MaskChar, len(runes))
+	}
+	hidden := len(runes) - m.KeepFirst - m.KeepLast
+	return string(runes[:m.KeepFirst]) + strings.Repeat(m.MaskChar, hidden) + string(runes[len(runes)-m.KeepLast:])
+}
@@ -0,0 +1,93 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderMaskApply(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mask     HeaderMask
+		value    string
+		expected string
+	}{
+		{desc: "Should mask the whole value", mask: HeaderMask{Name: "X"}, value: "secret", expected: "******"},
+		{desc: "Should keep the first and last characters", mask: HeaderMask{Name: "X", KeepFirst: 2, KeepLast: 4, MaskChar: "#"}, value: "sk-0123456789", expected: "sk#######6789"},
+		{desc: "Should mask short values entirely", mask: HeaderMask{Name: "X", KeepFirst: 4, KeepLast: 4}, value: "short", expected: "*****"},
+		{desc: "Should count characters rather than bytes", mask: HeaderMask{Name: "X", KeepLast: 1}, value: "ééé", expected: "**é"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			masks, err := compileHeaderMasks([]HeaderMask{test.mask})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if masked := masks[0].apply(test.value); masked != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, masked)
+			}
+		})
+	}
+}
+
+func TestMaskHeaders(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Upstream-Token", NewHeaderName: "X-Token"},
+		},
+		MaskHeaders: []HeaderMask{
+			{Name: "X-Token", KeepLast: 4},
+		},
+		RequestMaskHeaders: []HeaderMask{
+			{Name: "X-Api-*", KeepFirst: 3},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-Upstream-Token", "token-1234")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Api-Key", "key-secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expected := []string{"key*******"}
+	if values := forwarded["X-Api-Key"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	expected = []string{"******1234"}
+	if values := recorder.Result().Header["X-Token"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+}
+
+func TestCompileHeaderMasksErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		masks []HeaderMask
+	}{
+		{desc: "Should reject an empty name", masks: []HeaderMask{{}}},
+		{desc: "Should reject negative counts", masks: []HeaderMask{{Name: "X", KeepFirst: -1}}},
+		{desc: "Should reject long mask characters", masks: []HeaderMask{{Name: "X", MaskChar: "**"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileHeaderMasks(test.masks); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ruleMetrics counts the decisions of the rules, by direction and rule name,
+// so that rules that no longer rename anything can be spotted. Decisions are
+// counted without locking, as they are taken for every request.
+type ruleMetrics struct {
+	// counters maps a metricsKey to its *atomicCounters.
+	counters sync.Map
+}
+
+// metricsKey identifies the counters of a rule.
+type metricsKey struct {
+	direction string
+	name      string
+}
+
+// atomicCounters are the live counters of one rule.
+type atomicCounters struct {
+	matched   int64
+	skipped   int64
+	conflicts int64
+	failed    int64
+	// lastMatched is the time of the last match in Unix nanoseconds, 0 if
+	// the rule never matched.
+	lastMatched int64
+}
+
+// ruleCounters are the counters of one rule, as served on the metrics path.
+type ruleCounters struct {
+	Direction string `json:"direction"`
+	Name      string `json:"name"`
+	// Matched counts the requests or responses the rule renamed headers of,
+	// Skipped those it renamed nothing in, Conflicts the skips because the
+	// new header existed and Failed the decisions ending in an error, such as
+	// onConflict fail.
+	Matched   int64 `json:"matched"`
+	Skipped   int64 `json:"skipped"`
+	Conflicts int64 `json:"conflicts"`
+	Failed    int64 `json:"failed"`
+	// LastMatched is the time of the last match in RFC 3339 format, empty if
+	// the rule never matched.
+	LastMatched string `json:"lastMatched,omitempty"`
+}
+
+// skipReasons are the reasons of skipped rules, as opposed to errors.
+var skipReasons = map[string]bool{
+	reasonRequestConditions: true,
+	reasonConditions:        true,
+	reasonNoMatch:           true,
+	reasonNoValue:           true,
+	reasonDefaultValue:      true,
+	reasonValueMismatch:     true,
+	reasonConflict:          true,
+}
+
+func newRuleMetrics() *ruleMetrics {
+	return &ruleMetrics{}
+}
+
+// record counts a decision of rule, see decisionLog.
+func (m *ruleMetrics) record(direction string, rule *compiledRule, renamed []RenamedHeader, reason string) {
+	key := metricsKey{direction: direction, name: rule.label()}
+	value, ok := m.counters.Load(key)
+	if !ok {
+		value, _ = m.counters.LoadOrStore(key, &atomicCounters{})
+	}
+	counters := value.(*atomicCounters)
+
+	switch {
+	case len(renamed) > 0:
+		atomic.AddInt64(&counters.matched, 1)
+		atomic.StoreInt64(&counters.lastMatched, time.Now().UnixNano())
+	case !skipReasons[reason]:
+		atomic.AddInt64(&counters.failed, 1)
+	case reason == reasonConflict:
+		atomic.AddInt64(&counters.conflicts, 1)
+		atomic.AddInt64(&counters.skipped, 1)
+	default:
+		atomic.AddInt64(&counters.skipped, 1)
+	}
+}
+
+// snapshot returns a copy of the counters, sorted by direction and name.
+func (m *ruleMetrics) snapshot() []ruleCounters {
+	var all []ruleCounters
+	m.counters.Range(func(key, value interface{}) bool {
+		k, counters := key.(metricsKey), value.(*atomicCounters)
+		snapshot := ruleCounters{
+			Direction: k.direction,
+			Name:      k.name,
+			Matched:   atomic.LoadInt64(&counters.matched),
+			Skipped:   atomic.LoadInt64(&counters.skipped),
+			Conflicts: atomic.LoadInt64(&counters.conflicts),
+			Failed:    atomic.LoadInt64(&counters.failed),
+		}
+		if last := atomic.LoadInt64(&counters.lastMatched); last != 0 {
+			snapshot.LastMatched = time.Unix(0, last).UTC().Format(time.RFC3339)
+		}
+		all = append(all, snapshot)
+		return true
+	})
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Direction != all[j].Direction {
+			return all[i].Direction < all[j].Direction
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// ServeHTTP serves the counters as JSON.
+func (m *ruleMetrics) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(rw).Encode(struct {
+		Rules []ruleCounters `json:"rules"`
+	}{m.snapshot()})
+}
+
+// writeLog writes the counters to w, one line per rule.
+func (m *ruleMetrics) writeLog(w io.Writer, middleware string) {
+	var b strings.Builder
+	for _, counters := range m.snapshot() {
+		fmt.Fprintf(&b, "level=info plugin=renameHeaders middleware=%s direction=%s name=%s matched=%d skipped=%d conflicts=%d failed=%d lastMatched=%s\n",
+			strconv.Quote(middleware), counters.Direction, strconv.Quote(counters.Name),
+			counters.Matched, counters.Skipped, counters.Conflicts, counters.Failed, strconv.Quote(counters.LastMatched))
+	}
+	_, _ = io.WriteString(w, b.String())
+}
+
+// logEvery writes the counters to w every interval until ctx is done.
+func (m *ruleMetrics) logEvery(ctx context.Context, w io.Writer, middleware string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.writeLog(w, middleware)
+		}
+	}
+}
+
+// compileMetrics validates the metrics options and returns the interval of
+// the metrics log lines, 0 if they are not logged.
+func compileMetrics(path, interval string) (time.Duration, error) {
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return 0, fmt.Errorf("metricsPath %q must start with /", path)
+	}
+	if interval == "" {
+		return 0, nil
+	}
+	every, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid metricsLogInterval: %w", err)
+	}
+	if every <= 0 {
+		return 0, fmt.Errorf("metricsLogInterval %s must be positive", interval)
+	}
+	return every, nil
+}
+
+// compileMetricsSourceRange parses the clients allowed to read the metrics
+// path, which must be restricted as it reveals rules and traffic.
+func compileMetricsSourceRange(path string, values []string) (sourceRange, error) {
+	if path == "" {
+		if len(values) > 0 {
+			return nil, fmt.Errorf("metricsSourceRange requires metricsPath")
+		}
+		return nil, nil
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("metricsPath requires metricsSourceRange")
+	}
+	networks, err := parseSourceRange(values)
+	if err != nil {
+		return nil, fmt.Errorf("metricsSourceRange: %w", err)
+	}
+	return networks, nil
+}
+
+// servesMetrics reports whether req reads the metrics, from an allowed client.
+func (r *renameHeaders) servesMetrics(req *http.Request) bool {
+	return r.metricsPath != "" && req.URL.Path == r.metricsPath &&
+		r.metricsSourceRange.contains(clientIP(req, 0))
+}
@@ -0,0 +1,161 @@
+package traefik_header_rename_plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	config := &Config{
+		MetricsPath:        "/_header_rename/metrics",
+		MetricsSourceRange: []string{"192.0.2.0/24"},
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+			{ExistingHeaderName: "X-Idle", NewHeaderName: "X-Gone"},
+			{ExistingHeaderName: "X-Taken", NewHeaderName: "X-Target", OnConflict: ConflictSkip},
+			{Name: "fail", ExistingHeaderName: "X-Fail", NewHeaderName: "X-Failed", OnConflict: ConflictFail},
+		},
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Client", NewHeaderName: "X-Client-Id"},
+		},
+	}
+
+	backendCalls := 0
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		backendCalls++
+		rw.Header().Set("X-Old", "1")
+		rw.Header().Set("X-Taken", "a")
+		rw.Header().Set("X-Target", "b")
+		if req.URL.Path == "/fail" {
+			rw.Header().Set("X-Fail", "a")
+			rw.Header().Set("X-Failed", "b")
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/", "/", "/fail"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Client", "c")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Clients outside of the source range reach the backend.
+	outside := httptest.NewRequest(http.MethodGet, "/_header_rename/metrics", nil)
+	outside.RemoteAddr = "203.0.113.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), outside)
+	if backendCalls != 4 {
+		t.Errorf("metrics request from outside the source range was not forwarded: %d backend calls", backendCalls)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/_header_rename/metrics", nil))
+	if backendCalls != 4 {
+		t.Errorf("metrics request was forwarded: %d backend calls", backendCalls)
+	}
+
+	var result struct {
+		Rules []ruleCounters `json:"rules"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][4]int64{
+		"request X-Client->X-Client-Id": {3, 1, 0, 0},
+		"response X-Idle->X-Gone":       {0, 4, 0, 0},
+		"response X-Old->X-New":         {4, 0, 0, 0},
+		"response X-Taken->X-Target":    {0, 4, 4, 0},
+		"response fail":                 {0, 3, 0, 1},
+	}
+	if len(result.Rules) != len(expected) {
+		t.Fatalf("unexpected rules: %+v", result.Rules)
+	}
+	for _, counters := range result.Rules {
+		key := counters.Direction + " " + counters.Name
+		values := []int64{counters.Matched, counters.Skipped, counters.Conflicts, counters.Failed}
+		if exp := expected[key]; !testEqInt64(values, exp[:]) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", key, exp, values)
+		}
+		if (counters.Matched > 0) != (counters.LastMatched != "") {
+			t.Errorf("%s: unexpected lastMatched %q", key, counters.LastMatched)
+		}
+	}
+}
+
+func TestMetricsDryRun(t *testing.T) {
+	config := &Config{
+		DryRun:             true,
+		MetricsLogInterval: "1h",
+		RenameData:         []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}},
+	}
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Old", "1")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, err := New(ctx, http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if counters := handler.(*renameHeaders).metrics.snapshot(); len(counters) != 0 {
+		t.Errorf("planned decisions were counted: %+v", counters)
+	}
+}
+
+func TestMetricsLog(t *testing.T) {
+	metrics := newRuleMetrics()
+	rule := &compiledRule{RenameRule: RenameRule{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}}
+	metrics.record("response", rule, nil, reasonNoValue)
+	metrics.record("response", rule, nil, reasonConflict)
+
+	var b bytes.Buffer
+	metrics.writeLog(&b, "rewriteHeader")
+
+	expected := `level=info plugin=renameHeaders middleware="rewriteHeader" direction=response name="X-Old->X-New" matched=0 skipped=2 conflicts=1 failed=0 lastMatched=""` + "\n"
+	if b.String() != expected {
+		t.Errorf("expect: %q, result: %q", expected, b.String())
+	}
+}
+
+func TestMetricsErrors(t *testing.T) {
+	rules := []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}}
+	for _, config := range []*Config{
+		{MetricsPath: "metrics", MetricsSourceRange: []string{"10.0.0.0/8"}, RenameData: rules},
+		{MetricsPath: "/metrics", RenameData: rules},
+		{MetricsPath: "/metrics", MetricsSourceRange: []string{"10.0.0.0/33"}, RenameData: rules},
+		{MetricsSourceRange: []string{"10.0.0.0/8"}, RenameData: rules},
+		{MetricsLogInterval: "often", RenameData: rules},
+		{MetricsLogInterval: "-1m", RenameData: rules},
+	} {
+		_, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader")
+		if err == nil || !strings.Contains(err.Error(), "metrics") {
+			t.Errorf("%+v: unexpected error %v", config, err)
+		}
+	}
+}
+
+func testEqInt64(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
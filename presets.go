@@ -0,0 +1,187 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Presets converting trace context headers of requests between formats.
+const (
+	// PresetB3ToW3C converts Zipkin B3 headers, single or multi, to W3C traceparent.
+	PresetB3ToW3C = "b3-to-w3c"
+	// PresetW3CToB3 converts W3C traceparent to B3 multi headers.
+	PresetW3CToB3 = "w3c-to-b3"
+	// PresetW3CToB3Single converts W3C traceparent to the B3 single header.
+	PresetW3CToB3Single = "w3c-to-b3-single"
+)
+
+// b3Headers are the B3 multi headers, along with the single b3 header.
+var b3Headers = []string{"B3", "X-B3-Traceid", "X-B3-Spanid", "X-B3-Parentspanid", "X-B3-Sampled", "X-B3-Flags"}
+
+// compilePresets returns the conversion of a comma-separated list of presets,
+// applied in order, nil if none is set.
+func compilePresets(presets string) (func(http.Header), error) {
+	var conversions []func(http.Header)
+	for _, preset := range strings.Split(presets, ",") {
+		preset = strings.TrimSpace(preset)
+		if preset == "" {
+			continue
+		}
+		conversion, err := compilePreset(preset)
+		if err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, conversion)
+	}
+
+	switch len(conversions) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conversions[0], nil
+	}
+	return func(header http.Header) {
+		for _, conversion := range conversions {
+			conversion(header)
+		}
+	}, nil
+}
+
+// compilePreset returns the conversion of a preset.
+func compilePreset(preset string) (func(http.Header), error) {
+	switch preset {
+	case PresetB3ToW3C:
+		return b3ToW3C, nil
+	case PresetW3CToB3:
+		return func(header http.Header) { w3cToB3(header, false) }, nil
+	case PresetW3CToB3Single:
+		return func(header http.Header) { w3cToB3(header, true) }, nil
+	case PresetForwarded:
+		return xForwardedToForwarded, nil
+	case PresetXForwarded:
+		return forwardedToXForwarded, nil
+	default:
+		return nil, fmt.Errorf("unknown preset %q", preset)
+	}
+}
+
+// traceContext is a trace context common to B3 and W3C.
+type traceContext struct {
+	// traceID is 32 and spanID 16 lower case hex digits.
+	traceID string
+	spanID  string
+	sampled bool
+}
+
+// b3ToW3C replaces B3 headers by traceparent, unless the request carries a
+// traceparent already or the B3 headers are invalid.
+func b3ToW3C(header http.Header) {
+	if header.Get("Traceparent") != "" {
+		return
+	}
+	trace, ok := parseB3(header)
+	if !ok {
+		return
+	}
+
+	flags := "00"
+	if trace.sampled {
+		flags = "01"
+	}
+	for _, name := range b3Headers {
+		delete(header, name)
+	}
+	header.Set("Traceparent", "00-"+trace.traceID+"-"+trace.spanID+"-"+flags)
+}
+
+// parseB3 reads the single b3 header or, if absent, the B3 multi headers.
+func parseB3(header http.Header) (traceContext, bool) {
+	var traceID, spanID, sampled, flags string
+	if single := header.Get("B3"); single != "" {
+		parts := strings.Split(single, "-")
+		if len(parts) < 2 || len(parts) > 4 {
+			return traceContext{}, false
+		}
+		traceID, spanID = parts[0], parts[1]
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+	} else {
+		traceID = header.Get("X-B3-Traceid")
+		spanID = header.Get("X-B3-Spanid")
+		sampled = header.Get("X-B3-Sampled")
+		flags = header.Get("X-B3-Flags")
+	}
+
+	traceID = strings.ToLower(traceID)
+	spanID = strings.ToLower(spanID)
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !isHex(traceID, 32) || !isHex(spanID, 16) {
+		return traceContext{}, false
+	}
+	return traceContext{
+		traceID: traceID,
+		spanID:  spanID,
+		sampled: sampled == "1" || sampled == "d" || strings.EqualFold(sampled, "true") || flags == "1",
+	}, true
+}
+
+// w3cToB3 replaces traceparent by B3 multi headers or, if single is set, by
+// the b3 header, unless the request carries B3 headers already or traceparent
+// is invalid. tracestate is kept for services that understand it.
+func w3cToB3(header http.Header, single bool) {
+	for _, name := range b3Headers {
+		if header.Get(name) != "" {
+			return
+		}
+	}
+	trace, ok := parseTraceparent(header.Get("Traceparent"))
+	if !ok {
+		return
+	}
+
+	sampled := "0"
+	if trace.sampled {
+		sampled = "1"
+	}
+	delete(header, "Traceparent")
+	if single {
+		header.Set("B3", trace.traceID+"-"+trace.spanID+"-"+sampled)
+		return
+	}
+	header.Set("X-B3-Traceid", trace.traceID)
+	header.Set("X-B3-Spanid", trace.spanID)
+	header.Set("X-B3-Sampled", sampled)
+}
+
+// parseTraceparent parses a version 00 traceparent header.
+func parseTraceparent(value string) (traceContext, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(value)), "-")
+	if len(parts) != 4 || parts[0] != "00" || !isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return traceContext{}, false
+	}
+	// All zero ids are invalid.
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return traceContext{}, false
+	}
+
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	return traceContext{traceID: parts[1], spanID: parts[2], sampled: flags&1 == 1}, true
+}
+
+// isHex reports whether s consists of n lower case hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
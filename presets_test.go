@@ -0,0 +1,127 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		desc      string
+		preset    string
+		reqHeader http.Header
+		expHeader http.Header
+	}{
+		{
+			desc:   "Should convert B3 multi headers to traceparent",
+			preset: PresetB3ToW3C,
+			reqHeader: http.Header{
+				"X-B3-Traceid": {"80F198EE56343BA864FE8B2A57D3EFF7"},
+				"X-B3-Spanid":  {"e457b5a2e4d86bd1"},
+				"X-B3-Sampled": {"1"},
+			},
+			expHeader: http.Header{
+				"Traceparent":  {"00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01"},
+				"X-B3-Traceid": nil,
+				"X-B3-Spanid":  nil,
+				"X-B3-Sampled": nil,
+			},
+		},
+		{
+			desc:   "Should pad 64 bit B3 single trace ids",
+			preset: PresetB3ToW3C,
+			reqHeader: http.Header{
+				"B3":         {"64fe8b2a57d3eff7-e457b5a2e4d86bd1-0-05e3ac9a4f6e3b90"},
+				"Tracestate": {"vendor=value"},
+			},
+			expHeader: http.Header{
+				"Traceparent": {"00-000000000000000064fe8b2a57d3eff7-e457b5a2e4d86bd1-00"},
+				"Tracestate":  {"vendor=value"},
+				"B3":          nil,
+			},
+		},
+		{
+			desc:   "Should keep an existing traceparent",
+			preset: PresetB3ToW3C,
+			reqHeader: http.Header{
+				"B3":          {"64fe8b2a57d3eff7-e457b5a2e4d86bd1-1"},
+				"Traceparent": {"00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01"},
+			},
+			expHeader: http.Header{
+				"B3":          {"64fe8b2a57d3eff7-e457b5a2e4d86bd1-1"},
+				"Traceparent": {"00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01"},
+			},
+		},
+		{
+			desc:      "Should leave invalid B3 headers alone",
+			preset:    PresetB3ToW3C,
+			reqHeader: http.Header{"B3": {"xyz-e457b5a2e4d86bd1"}},
+			expHeader: http.Header{"B3": {"xyz-e457b5a2e4d86bd1"}, "Traceparent": nil},
+		},
+		{
+			desc:      "Should convert traceparent to B3 multi headers",
+			preset:    PresetW3CToB3,
+			reqHeader: http.Header{"Traceparent": {"00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01"}},
+			expHeader: http.Header{
+				"X-B3-Traceid": {"80f198ee56343ba864fe8b2a57d3eff7"},
+				"X-B3-Spanid":  {"e457b5a2e4d86bd1"},
+				"X-B3-Sampled": {"1"},
+				"Traceparent":  nil,
+			},
+		},
+		{
+			desc:      "Should convert traceparent to the B3 single header",
+			preset:    PresetW3CToB3Single,
+			reqHeader: http.Header{"Traceparent": {"00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-00"}},
+			expHeader: http.Header{
+				"B3":          {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0"},
+				"Traceparent": nil,
+			},
+		},
+		{
+			desc:      "Should leave an all zero traceparent alone",
+			preset:    PresetW3CToB3,
+			reqHeader: http.Header{"Traceparent": {"00-00000000000000000000000000000000-e457b5a2e4d86bd1-01"}},
+			expHeader: http.Header{
+				"Traceparent":  {"00-00000000000000000000000000000000-e457b5a2e4d86bd1-01"},
+				"X-B3-Traceid": nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{Preset: test.preset}
+
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = test.reqHeader
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			for k, expected := range test.expHeader {
+				if values := forwarded[k]; !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownPreset(t *testing.T) {
+	_, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), &Config{Preset: "zipkin"}, "rewriteHeader")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,43 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+)
+
+// RenameResult is the outcome of previewing rules against one header set.
+type RenameResult struct {
+	// Before is the captured header set, After the header set once renamed.
+	Before http.Header
+	After  http.Header
+	// Renamed lists the renames that would be performed, in order.
+	Renamed []RenamedHeader
+	// Err is set when the rules are invalid, After is then a copy of Before,
+	// or when a rule failed on a conflict.
+	Err error
+}
+
+// PreviewAgainst runs the response rules over captured header sets, e.g. from
+// logs, and reports what would change for each of them. The captured headers
+// are not modified. As header sets carry no status code, rules restricted to
+// status codes do not apply, and value templates see no request.
+func PreviewAgainst(headers []http.Header, rules []RenameRule) []RenameResult {
+	set, err := CompileRules(rules)
+	var index *ruleIndex
+	if err == nil {
+		index = newRuleIndex(set.rules)
+	}
+
+	results := make([]RenameResult, 0, len(headers))
+	for _, header := range headers {
+		result := RenameResult{
+			Before: header,
+			After:  header.Clone(),
+			Err:    err,
+		}
+		if err == nil {
+			result.Renamed, result.Err = index.apply(result.After, nil, 0)
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,82 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPreviewAgainst(t *testing.T) {
+	rules := []RenameRule{
+		{ExistingHeaderName: "X-Legacy-Id", NewHeaderName: "X-Id"},
+		{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New-$1"},
+	}
+
+	headers := []http.Header{
+		{"X-Legacy-Id": {"1"}, "X-Old-A": {"a"}},
+		{"X-Old-B": {"b"}, "X-Old-C": {"c"}},
+		{"Content-Type": {"text/plain"}},
+	}
+
+	results := PreviewAgainst(headers, rules)
+	if len(results) != len(headers) {
+		t.Fatalf("expected %d results, got %d", len(headers), len(results))
+	}
+
+	expRenamed := [][]RenamedHeader{
+		{
+			{Rule: "X-Legacy-Id->X-Id", From: "X-Legacy-Id", To: "X-Id"},
+			{Rule: "X-Old-*->X-New-$1", From: "X-Old-A", To: "X-New-A"},
+		},
+		{
+			{Rule: "X-Old-*->X-New-$1", From: "X-Old-B", To: "X-New-B"},
+			{Rule: "X-Old-*->X-New-$1", From: "X-Old-C", To: "X-New-C"},
+		},
+		nil,
+	}
+
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if len(result.Renamed) != len(expRenamed[i]) {
+			t.Fatalf("set %d: expected %+v, got %+v", i, expRenamed[i], result.Renamed)
+		}
+		for j, rename := range result.Renamed {
+			if rename != expRenamed[i][j] {
+				t.Errorf("set %d: expected %+v, got %+v", i, expRenamed[i][j], rename)
+			}
+		}
+	}
+
+	if values := results[0].After["X-Id"]; !testEq(values, []string{"1"}) {
+		t.Errorf("expected the renamed header in the result, got %+v", results[0].After)
+	}
+	if values := headers[0]["X-Legacy-Id"]; !testEq(values, []string{"1"}) {
+		t.Errorf("expected the captured headers to be left untouched, got %+v", headers[0])
+	}
+}
+
+func TestPreviewAgainstInvalidRules(t *testing.T) {
+	rules := []RenameRule{
+		{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New"},
+	}
+
+	results := PreviewAgainst([]http.Header{{"X-Old-A": {"a"}}}, rules)
+	if results[0].Err == nil {
+		t.Fatal("expected an error")
+	}
+	if values := results[0].After["X-Old-A"]; !testEq(values, []string{"a"}) {
+		t.Errorf("expected the headers to be unchanged, got %+v", results[0].After)
+	}
+}
+
+func TestPreviewAgainstDecodeError(t *testing.T) {
+	rules := []RenameRule{
+		{ExistingHeaderName: "X-Meta-B64", NewHeaderName: "X-Meta", Decode: "base64"},
+	}
+
+	results := PreviewAgainst([]http.Header{{"X-Meta-B64": {"not base64!"}}}, rules)
+	if results[0].Err == nil {
+		t.Errorf("expected the decode error to be reported, got %+v", results[0].After)
+	}
+}
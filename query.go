@@ -0,0 +1,97 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// QueryMapping maps a query parameter to a header or back.
+type QueryMapping struct {
+	QueryParam string `json:"queryParam"`
+	HeaderName string `json:"headerName"`
+	// Keep copies the parameter or header instead of moving it.
+	Keep bool `json:"keep"`
+}
+
+// compileQueryMappings validates the mappings and canonicalizes their header names.
+func compileQueryMappings(mappings []QueryMapping) ([]QueryMapping, error) {
+	compiled := make([]QueryMapping, 0, len(mappings))
+	for i, mapping := range mappings {
+		if mapping.QueryParam == "" || mapping.HeaderName == "" {
+			return nil, fmt.Errorf("query mapping %d: queryParam and headerName cannot be empty", i)
+		}
+		if strings.HasPrefix(mapping.HeaderName, ":") {
+			return nil, fmt.Errorf("query mapping %d: header name %q is a pseudo-header", i, mapping.HeaderName)
+		}
+		mapping.HeaderName = http.CanonicalHeaderKey(mapping.HeaderName)
+		compiled = append(compiled, mapping)
+	}
+	return compiled, nil
+}
+
+// queryToHeaders sets the mapped query parameters of req as request headers,
+// replacing headers of the same name.
+func queryToHeaders(req *http.Request, mappings []QueryMapping) {
+	if len(mappings) == 0 || req.URL.RawQuery == "" {
+		return
+	}
+
+	query := req.URL.Query()
+	removed := false
+	for _, mapping := range mappings {
+		values, ok := query[mapping.QueryParam]
+		if !ok {
+			continue
+		}
+		req.Header[mapping.HeaderName] = append([]string(nil), values...)
+		if !mapping.Keep {
+			query.Del(mapping.QueryParam)
+			removed = true
+		}
+	}
+
+	// Only re-encode when needed, as encoding changes the parameter order.
+	if removed {
+		req.URL.RawQuery = query.Encode()
+		if req.RequestURI != "" {
+			req.RequestURI = req.URL.RequestURI()
+		}
+	}
+}
+
+// headersToRedirectQuery adds the mapped response headers as query
+// parameters to the Location of redirect responses.
+func headersToRedirectQuery(header http.Header, status int, mappings []QueryMapping) {
+	if len(mappings) == 0 || status < 300 || status > 399 {
+		return
+	}
+	location := header.Get("Location")
+	if location == "" {
+		return
+	}
+	target, err := url.Parse(location)
+	if err != nil {
+		return
+	}
+
+	query := target.Query()
+	added := false
+	for _, mapping := range mappings {
+		values, ok := header[mapping.HeaderName]
+		if !ok {
+			continue
+		}
+		query[mapping.QueryParam] = append(query[mapping.QueryParam], values...)
+		added = true
+		if !mapping.Keep {
+			delete(header, mapping.HeaderName)
+		}
+	}
+
+	if added {
+		target.RawQuery = query.Encode()
+		header.Set("Location", target.String())
+	}
+}
@@ -0,0 +1,91 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryToHeaders(t *testing.T) {
+	config := &Config{
+		QueryToHeaders: []QueryMapping{
+			{QueryParam: "api_key", HeaderName: "x-api-key"},
+			{QueryParam: "tenant", HeaderName: "X-Tenant", Keep: true},
+		},
+	}
+
+	var forwarded *http.Request
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api?api_key=secret&tenant=acme&page=2", nil)
+	req.Header.Set("X-Api-Key", "spoofed")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := []string{"secret"}
+	if values := forwarded.Header["X-Api-Key"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	expected = []string{"acme"}
+	if values := forwarded.Header["X-Tenant"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+	if forwarded.URL.RawQuery != "page=2&tenant=acme" {
+		t.Errorf("unexpected query %q", forwarded.URL.RawQuery)
+	}
+	if forwarded.RequestURI != "/api?page=2&tenant=acme" {
+		t.Errorf("unexpected request URI %q", forwarded.RequestURI)
+	}
+}
+
+func TestHeadersToRedirectQuery(t *testing.T) {
+	tests := []struct {
+		desc        string
+		status      int
+		expLocation string
+		expHeader   []string
+	}{
+		{desc: "Should move the header into the redirect", status: http.StatusFound, expLocation: "https://example.com/login?next=%2F&state=abc"},
+		{desc: "Should leave other responses alone", status: http.StatusOK, expLocation: "https://example.com/login?next=%2F", expHeader: []string{"abc"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				HeadersToRedirectQuery: []QueryMapping{
+					{QueryParam: "state", HeaderName: "X-State"},
+				},
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Location", "https://example.com/login?next=%2F")
+				rw.Header().Set("X-State", "abc")
+				rw.WriteHeader(test.status)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			header := recorder.Result().Header
+			if location := header.Get("Location"); location != test.expLocation {
+				t.Errorf("expected location %q, got %q", test.expLocation, location)
+			}
+			if values := header["X-State"]; !testEq(values, test.expHeader) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expHeader, values)
+			}
+		})
+	}
+}
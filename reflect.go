@@ -0,0 +1,52 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ReflectedHeader copies a request header into the response.
+type ReflectedHeader struct {
+	Name string `json:"name"`
+	// ResponseName is the name of the response header, Name by default.
+	ResponseName string `json:"responseName"`
+	// Overwrite replaces a response header the backend set already; by
+	// default its values are kept.
+	Overwrite bool `json:"overwrite"`
+}
+
+// compileReflectedHeaders validates the reflected headers and canonicalizes their names.
+func compileReflectedHeaders(headers []ReflectedHeader) ([]ReflectedHeader, error) {
+	compiled := make([]ReflectedHeader, 0, len(headers))
+	for i, h := range headers {
+		if h.Name == "" {
+			return nil, fmt.Errorf("reflected header %d: name cannot be empty", i)
+		}
+		if h.ResponseName == "" {
+			h.ResponseName = h.Name
+		}
+		if strings.HasPrefix(h.ResponseName, ":") {
+			return nil, fmt.Errorf("reflected header %d: response name %q is a pseudo-header", i, h.ResponseName)
+		}
+		h.Name = http.CanonicalHeaderKey(h.Name)
+		h.ResponseName = http.CanonicalHeaderKey(h.ResponseName)
+		compiled = append(compiled, h)
+	}
+	return compiled, nil
+}
+
+// reflectHeaders copies the reflected headers of the request, as forwarded
+// to the backend, into the response header.
+func reflectHeaders(header http.Header, req *http.Request, headers []ReflectedHeader) {
+	for _, h := range headers {
+		values := req.Header[h.Name]
+		if len(values) == 0 {
+			continue
+		}
+		if _, ok := header[h.ResponseName]; ok && !h.Overwrite {
+			continue
+		}
+		header[h.ResponseName] = append([]string(nil), values...)
+	}
+}
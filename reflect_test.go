@@ -0,0 +1,72 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReflectHeaders(t *testing.T) {
+	config := &Config{
+		RequestRenameData: []RenameRule{{ExistingHeaderName: "X-Correlation", NewHeaderName: "X-Request-Id"}},
+		ReflectHeaders: []ReflectedHeader{
+			{Name: "x-request-id"},
+			{Name: "X-Tenant", ResponseName: "X-Served-Tenant"},
+			{Name: "X-Session", Overwrite: true},
+			{Name: "X-Locale"},
+			{Name: "X-Missing"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Session", "backend")
+		rw.Header().Set("X-Locale", "backend")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Correlation", "abc")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Session", "client")
+	req.Header.Set("X-Locale", "client")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expRespHeader := http.Header{
+		"X-Request-Id":    {"abc"},
+		"X-Served-Tenant": {"acme"},
+		"X-Tenant":        nil,
+		"X-Session":       {"client"},
+		"X-Locale":        {"backend"},
+		"X-Missing":       nil,
+	}
+	for k, expected := range expRespHeader {
+		if values := recorder.Header()[k]; !testEq(values, expected) {
+			t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
+
+func TestCompileReflectedHeadersErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		headers []ReflectedHeader
+	}{
+		{desc: "Should reject a missing name", headers: []ReflectedHeader{{ResponseName: "X-Foo"}}},
+		{desc: "Should reject pseudo-headers", headers: []ReflectedHeader{{Name: "X-Foo", ResponseName: ":status"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileReflectedHeaders(test.headers); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
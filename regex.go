@@ -0,0 +1,79 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// compileRegex compiles the header name pattern of a regex rule and checks
+// that the target only references capture groups the pattern defines. Header
+// names are matched case-insensitively.
+func compileRegex(pattern, target string) (*regexp.Regexp, error) {
+	regex, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+
+	names := make(map[string]bool)
+	for _, name := range regex.SubexpNames() {
+		if name != "" {
+			names[name] = true
+		}
+	}
+
+	for _, ref := range targetRefs(target) {
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n > regex.NumSubexp() {
+				return nil, fmt.Errorf("target %q: $%s does not refer to one of the %d capture groups of the regex", target, ref, regex.NumSubexp())
+			}
+			continue
+		}
+		if !names[ref] {
+			return nil, fmt.Errorf("target %q: $%s does not refer to a named capture group of the regex", target, ref)
+		}
+	}
+	return regex, nil
+}
+
+// targetRefs returns the group references in a target, following the syntax
+// of regexp.Expand: $name or ${name}, with $$ for a literal dollar sign.
+func targetRefs(target string) []string {
+	var refs []string
+	for i := 0; i < len(target); i++ {
+		if target[i] != '$' || i+1 == len(target) {
+			continue
+		}
+
+		if target[i+1] == '$' {
+			i++
+			continue
+		}
+
+		if target[i+1] == '{' {
+			end := i + 2
+			for end < len(target) && target[end] != '}' {
+				end++
+			}
+			if end < len(target) {
+				refs = append(refs, target[i+2:end])
+				i = end
+			}
+			continue
+		}
+
+		end := i + 1
+		for end < len(target) && isRefChar(target[end]) {
+			end++
+		}
+		if end > i+1 {
+			refs = append(refs, target[i+1:end])
+			i = end - 1
+		}
+	}
+	return refs
+}
+
+func isRefChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,168 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ruleSourceTimeout bounds fetching rules from Config.RulesURL.
+const ruleSourceTimeout = 10 * time.Second
+
+// maxRuleSourceSize bounds the size of a rules document.
+const maxRuleSourceSize = 10 << 20
+
+// ruleSource is a JSON document holding renameData rules, read from a file or
+// fetched from a URL.
+type ruleSource struct {
+	file string
+	url  string
+	// local are the rules of the configuration, merged onto the loaded ones.
+	local []RenameRule
+}
+
+// newRuleSource returns the rule source configured, nil if there is none.
+func newRuleSource(config *Config) (*ruleSource, error) {
+	if config.RulesFile != "" && config.RulesURL != "" {
+		return nil, errors.New("rulesFile and rulesURL are mutually exclusive")
+	}
+	if config.RulesFile == "" && config.RulesURL == "" {
+		if config.RulesRefreshInterval != "" {
+			return nil, errors.New("rulesRefreshInterval requires rulesFile or rulesURL")
+		}
+		return nil, nil
+	}
+	return &ruleSource{file: config.RulesFile, url: config.RulesURL, local: config.RenameData}, nil
+}
+
+// load reads the rules and merges the local rules onto them, which replace
+// loaded rules for the same existing header name.
+func (s *ruleSource) load(ctx context.Context) ([]RenameRule, error) {
+	data, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		RenameData []RenameRule `json:"renameData"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("rules source: %w", err)
+	}
+	return mergeRenames(document.RenameData, s.local), nil
+}
+
+func (s *ruleSource) read(ctx context.Context) ([]byte, error) {
+	if s.file != "" {
+		file, err := os.Open(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("rules file: %w", err)
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxRuleSourceSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("rules file: %w", err)
+		}
+		if len(data) > maxRuleSourceSize {
+			return nil, fmt.Errorf("rules file %s: document exceeds %d bytes", s.file, maxRuleSourceSize)
+		}
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ruleSourceTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rules URL: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rules URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rules URL %s: unexpected status %d", s.url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRuleSourceSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("rules URL %s: %w", s.url, err)
+	}
+	if len(data) > maxRuleSourceSize {
+		return nil, fmt.Errorf("rules URL %s: document exceeds %d bytes", s.url, maxRuleSourceSize)
+	}
+	return data, nil
+}
+
+// reload loads and compiles the rules of source and swaps them in. On any
+// error the current rules are kept.
+func (r *renameHeaders) reload(ctx context.Context, source *ruleSource) error {
+	renames, err := source.load(ctx)
+	if err != nil {
+		return err
+	}
+	set, err := CompileRules(renames)
+	if err != nil {
+		return err
+	}
+	indexes, err := r.indexRules(set, r.localRequestRules)
+	if err != nil {
+		return err
+	}
+	r.indexes.Store(indexes)
+	return nil
+}
+
+// watch reloads the rules of source every interval until ctx is done.
+func (r *renameHeaders) watch(ctx context.Context, source *ruleSource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Invalid rules are ignored until the source is fixed. Reloads
+			// cut short by the end of the middleware are not worth a log.
+			if err := r.reload(ctx, source); err != nil && ctx.Err() == nil {
+				r.logError("action=reload", err)
+			}
+		}
+	}
+}
+
+// compileRefreshInterval parses the interval rules are reloaded at, 0 if
+// they are loaded once.
+func compileRefreshInterval(interval string) (time.Duration, error) {
+	if interval == "" {
+		return 0, nil
+	}
+	every, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rulesRefreshInterval: %w", err)
+	}
+	if every <= 0 {
+		return 0, fmt.Errorf("rulesRefreshInterval %s must be positive", interval)
+	}
+	return every, nil
+}
+
+// startReloading loads the rules of source once and, with an interval, keeps
+// reloading them in the background. If the first load fails, the local rules
+// apply until a reload succeeds. Failed loads are logged.
+func (r *renameHeaders) startReloading(ctx context.Context, source *ruleSource, every time.Duration) {
+	if err := r.reload(ctx, source); err != nil {
+		r.logError("action=reload", err)
+	}
+	if every > 0 {
+		go r.watch(ctx, source, every)
+	}
+}
@@ -0,0 +1,180 @@
+package traefik_header_rename_plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// renamedTo serves a response with an X-Foo header and returns the name it
+// was renamed to, if any.
+func renamedTo(t *testing.T, handler http.Handler, names ...string) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, name := range names {
+		if recorder.Result().Header.Get(name) == "foo" {
+			return name
+		}
+	}
+	return ""
+}
+
+func TestRulesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.json")
+	writeRules := func(rules string) {
+		if err := os.WriteFile(file, []byte(rules), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRules(`{"renameData": [{"existingHeaderName": "X-Foo", "newHeaderName": "X-Bar"}]}`)
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Foo", "foo")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	config := &Config{RulesFile: file}
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := newRuleSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := renamedTo(t, handler, "X-Foo", "X-Bar", "X-Baz"); name != "X-Bar" {
+		t.Fatalf("expected the loaded rules to apply, got %q", name)
+	}
+
+	writeRules(`{"renameData": [{"existingHeaderName": "X-Foo", "newHeaderName": "X-Baz"}]}`)
+	if err := handler.(*renameHeaders).reload(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+	if name := renamedTo(t, handler, "X-Foo", "X-Bar", "X-Baz"); name != "X-Baz" {
+		t.Fatalf("expected the reloaded rules to apply, got %q", name)
+	}
+
+	for _, invalid := range []string{`{"renameData": [`, `{"renameData": [{"existingHeaderName": "X-Foo"}]}`} {
+		writeRules(invalid)
+		if err := handler.(*renameHeaders).reload(context.Background(), source); err == nil {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+		if name := renamedTo(t, handler, "X-Foo", "X-Bar", "X-Baz"); name != "X-Baz" {
+			t.Errorf("expected the last good rules to be kept, got %q", name)
+		}
+	}
+}
+
+func TestRulesURL(t *testing.T) {
+	var mu sync.Mutex
+	rules := `{"renameData": [{"existingHeaderName": "X-Foo", "newHeaderName": "X-Bar"}]}`
+	source := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = rw.Write([]byte(rules))
+	}))
+	defer source.Close()
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Foo", "foo")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := &Config{
+		RenameData:           []RenameRule{{ExistingHeaderName: "X-Local", NewHeaderName: "X-Local-Renamed"}},
+		RulesURL:             source.URL,
+		RulesRefreshInterval: "10ms",
+	}
+	handler, err := New(ctx, http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := renamedTo(t, handler, "X-Foo", "X-Bar", "X-Baz"); name != "X-Bar" {
+		t.Fatalf("expected the fetched rules to apply, got %q", name)
+	}
+
+	mu.Lock()
+	rules = `{"renameData": [{"existingHeaderName": "X-Foo", "newHeaderName": "X-Baz"}]}`
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for renamedTo(t, handler, "X-Foo", "X-Bar", "X-Baz") != "X-Baz" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the rules to be refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRuleSourceErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config *Config
+	}{
+		{desc: "Should reject both a file and a URL", config: &Config{RulesFile: "rules.json", RulesURL: "http://example.com"}},
+		{desc: "Should reject an interval without a source", config: &Config{RenameData: []RenameRule{{ExistingHeaderName: "A", NewHeaderName: "B"}}, RulesRefreshInterval: "1m"}},
+		{desc: "Should reject an invalid interval", config: &Config{RulesFile: "rules.json", RulesRefreshInterval: "soon"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), test.config, "rewriteHeader")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestRuleSourceLogsFailures(t *testing.T) {
+	large := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"renameData": [`))
+		_, _ = rw.Write(make([]byte, maxRuleSourceSize))
+	}))
+	defer large.Close()
+
+	config := &Config{RenameData: []RenameRule{{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar"}}}
+	handler, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rename := handler.(*renameHeaders)
+	var log bytes.Buffer
+	rename.errorOutput = &log
+
+	largeFile := filepath.Join(t.TempDir(), "large.json")
+	if err := os.WriteFile(largeFile, make([]byte, maxRuleSourceSize+1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		source  *ruleSource
+		exceeds bool
+	}{
+		{source: &ruleSource{file: filepath.Join(t.TempDir(), "missing.json")}},
+		{source: &ruleSource{file: largeFile}, exceeds: true},
+		{source: &ruleSource{url: large.URL}, exceeds: true},
+	} {
+		log.Reset()
+		rename.startReloading(context.Background(), test.source, 0)
+		if !strings.HasPrefix(log.String(), `level=error plugin=renameHeaders middleware="rewriteHeader" action=reload error=`) {
+			t.Errorf("unexpected log %q", log.String())
+		}
+		if test.exceeds && !strings.Contains(log.String(), "exceeds") {
+			t.Errorf("expected the document size to be bounded, got %q", log.String())
+		}
+	}
+}
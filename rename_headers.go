@@ -5,19 +5,339 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
 )
 
-// Rename holds one rename configuration.
-type renameData struct {
+// RenameRule holds one rename configuration.
+type RenameRule struct {
+	// Name identifies the rule in traces; it defaults to "existing->new".
+	Name string `json:"name"`
+	// Priority orders the rules: higher priorities apply first, and rules
+	// with equal priorities apply in the order they are listed.
+	Priority int `json:"priority"`
+	// Terminal stops later rules from applying to the headers the rule
+	// renames, including the original header when it is kept.
+	Terminal bool `json:"terminal"`
+	// Enabled false turns the rule off while keeping it in the configuration.
+	// ActiveFrom and ActiveUntil, RFC 3339 timestamps, restrict the rule to
+	// the requests received in between, e.g. to stage a cutover.
+	Enabled     *bool  `json:"enabled"`
+	ActiveFrom  string `json:"activeFrom"`
+	ActiveUntil string `json:"activeUntil"`
+
 	ExistingHeaderName string `json:"existingHeaderName"`
 	NewHeaderName      string `json:"newHeaderName"`
+	// NewHeaderNames copies the header to further names, after NewHeaderName
+	// if set, e.g. while consumers disagree on the new name.
+	NewHeaderNames []string `json:"newHeaderNames"`
+	// Regex makes ExistingHeaderName a regular expression whose capture groups
+	// can be referenced in NewHeaderName as $1 or ${name}.
+	Regex bool `json:"regex"`
+	// Except exempts headers from a wildcard or regex rule. Names may
+	// contain wildcards.
+	Except []string `json:"except"`
+	// ValueMap translates the values of the renamed header.
+	ValueMap *ValueMap `json:"valueMap"`
+	// ValueReplacement rewrites the values of the renamed header.
+	ValueReplacement *ValueReplacement `json:"valueReplacement"`
+	// ValueTemplate renders the values of the renamed header from a Go template,
+	// see templateData for the fields available.
+	ValueTemplate string `json:"valueTemplate"`
+	// Split turns comma-separated values of the renamed header into one value
+	// per line; Join folds all values into one, separated by JoinSeparator,
+	// ", " by default.
+	Split         bool   `json:"split"`
+	Join          bool   `json:"join"`
+	JoinSeparator string `json:"joinSeparator"`
+	// Decode decodes the values of the renamed header before they are
+	// rewritten, and Encode encodes them afterwards. Both name an encoding
+	// such as "base64", see valueEncodings.
+	Decode string `json:"decode"`
+	Encode string `json:"encode"`
+	// Steps replaces the value options above with an explicit pipeline of
+	// operations, each fed the output of the previous one.
+	Steps []TransformStep `json:"steps"`
+	// DefaultValue is emitted under the new name when the existing header is missing.
+	DefaultValue string `json:"defaultValue"`
+	// CanonicalizeNewName selects the header casing. When true, the new name is
+	// written in canonical MIME form; when false, both names are used byte-exact.
+	// When unset, the existing name is looked up canonically and the new name
+	// is written as configured.
+	CanonicalizeNewName *bool `json:"canonicalizeNewName"`
+	// OnConflict selects what happens when the new header already exists, see
+	// the Conflict* constants.
+	OnConflict string `json:"onConflict"`
+	// Dedupe collapses repeated identical values of the new header, including
+	// values appended to existing ones on conflict.
+	Dedupe bool `json:"dedupe"`
+	// KeepOriginal copies the value to the new name instead of moving it.
+	KeepOriginal bool `json:"keepOriginal"`
+
+	// WhenHeaderValueEquals restricts the rule to responses carrying the given header value.
+	WhenHeaderValueEquals *HeaderValue `json:"whenHeaderValueEquals"`
+	// ValueRegexp restricts the rule to headers with a value matching the regexp.
+	ValueRegexp string `json:"valueRegexp"`
+	// WhenTLSOnly restricts the rule to requests received over TLS.
+	WhenTLSOnly bool `json:"whenTLSOnly"`
+	// PathPrefix, Methods and HostRegexp restrict the rule to matching requests.
+	PathPrefix string   `json:"pathPrefix"`
+	Methods    []string `json:"methods"`
+	HostRegexp string   `json:"hostRegexp"`
+	// NameRegexp restricts the rule to the middlewares whose name, as Traefik
+	// gives it such as "rename-api@kubernetescrd", matches the regexp, so that
+	// one shared definition can vary by router.
+	NameRegexp string `json:"nameRegexp"`
+	// SamplePercent restricts the rule to a percentage of requests, e.g. to
+	// canary a migration. Requests are sampled by a hash of the value of the
+	// SampleKeyHeader request header, such as X-Request-Id, so that a request
+	// is sampled alike by every rule; at random when it is unset or missing.
+	SamplePercent   *float64 `json:"samplePercent"`
+	SampleKeyHeader string   `json:"sampleKeyHeader"`
+	// SourceRange restricts the rule to clients in one of the CIDRs. The
+	// client is the remote address or, with a SourceRangeDepth of n, the
+	// n-th address of X-Forwarded-For counting from the right.
+	SourceRange      []string `json:"sourceRange"`
+	SourceRangeDepth int      `json:"sourceRangeDepth"`
+
+	// StatusCodes restricts the rule to responses with matching status codes,
+	// given as a list of codes and ranges such as "200-299,404".
+	StatusCodes string `json:"statusCodes"`
+	// ContentTypes restricts the rule to responses whose Content-Type has one
+	// of the media types, such as "application/json" or "text/*".
+	ContentTypes []string `json:"contentTypes"`
+
+	// Direction selects the headers the rule applies to, see the Direction* constants.
+	Direction string `json:"direction"`
+	// Trailers applies the rule to response trailers as well.
+	Trailers bool `json:"trailers"`
+}
+
+// Rule directions. Rules in Config.RenameData apply to responses by default.
+const (
+	DirectionResponse = "response"
+	DirectionRequest  = "request"
+	// DirectionBoth applies the rule to the request and its reverse
+	// (new name back to existing name) to the response.
+	DirectionBoth = "both"
+)
+
+// ValueReplacement replaces the matches of a regexp in header values, with
+// $1 or ${name} in Replacement referencing capture groups.
+type ValueReplacement struct {
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+}
+
+// HeaderValue is a header name and the value it is expected to have.
+type HeaderValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 // Config holds the plugin configuration.
 type Config struct {
-	RenameData []renameData `json:"renameData"`
+	RenameData []RenameRule `json:"renameData"`
+	// Renames is a shorthand for RenameData rules that only rename, mapping
+	// existing header names to new ones. Its rules apply first, in the order
+	// of their existing names; a RenameData rule for the same existing
+	// header name replaces the shorthand one.
+	Renames map[string]string `json:"renames"`
+	// RequestRenameData holds the rules applied to request headers before
+	// the request is forwarded.
+	RequestRenameData []RenameRule `json:"requestRenameData"`
+
+	// Variants are alternative rules replacing RenameData and
+	// RequestRenameData for requests whose VariantHeader or VariantCookie
+	// value names one of them, e.g. to give experiment arms different
+	// headers. Variants and HostRules are not reloaded with RulesFile or
+	// RulesURL.
+	Variants      map[string]RuleVariant `json:"variants"`
+	VariantHeader string                 `json:"variantHeader"`
+	VariantCookie string                 `json:"variantCookie"`
+
+	// HostRules maps hosts to the rules replacing RenameData and
+	// RequestRenameData for their requests. Hosts may contain wildcards,
+	// such as "*.example.com"; exact hosts take precedence, then the longest
+	// matching pattern. A selected variant takes precedence over host rules.
+	HostRules map[string][]RenameRule `json:"hostRules"`
+
+	// Preset enables built-in conversions of request headers, given as a
+	// comma-separated list of the Preset* constants. They apply in order,
+	// before RequestRenameData.
+	Preset string `json:"preset"`
+
+	// RulesFile or RulesURL names a JSON document holding renameData rules,
+	// reloaded every RulesRefreshInterval if set. Rules in RenameData replace
+	// loaded rules with the same existing header name. When the document
+	// cannot be loaded or holds invalid rules, the previous rules are kept.
+	RulesFile            string `json:"rulesFile"`
+	RulesURL             string `json:"rulesURL"`
+	RulesRefreshInterval string `json:"rulesRefreshInterval"`
+
+	// ExtendsFile is a JSON file holding a base configuration whose rules are
+	// inherited; local rules override base rules with the same existing header name.
+	ExtendsFile string `json:"extendsFile"`
+
+	// DryRun leaves headers untouched by the rename rules, and describes what
+	// the rules would do in the DryRunHeader of requests and responses,
+	// X-Header-Rename-Plan by default. Other options still apply.
+	DryRun       bool   `json:"dryRun"`
+	DryRunHeader string `json:"dryRunHeader"`
+
+	// LogLevel debug logs the decision taken by each rule for each request
+	// and response to stdout, see the LogLevel* constants.
+	LogLevel string `json:"logLevel"`
+
+	// MetricsPath, when set, serves counters of the decisions of each rule as
+	// JSON on requests for this path from clients in MetricsSourceRange,
+	// which is required, instead of forwarding them. MetricsLogInterval, when
+	// set, logs the counters to stdout at this interval.
+	MetricsPath        string   `json:"metricsPath"`
+	MetricsSourceRange []string `json:"metricsSourceRange"`
+	MetricsLogInterval string   `json:"metricsLogInterval"`
+
+	// MarkerHeader, when set, names the marker of requests this plugin
+	// processes, so that another instance with the same marker further down
+	// the middleware chain, e.g. on a router behind an entrypoint, leaves
+	// them untouched instead of renaming headers twice. The marker is kept in
+	// the request context; a header of that name is removed from requests
+	// and responses.
+	MarkerHeader string `json:"markerHeader"`
+
+	// ResponseFilter drops the response headers that are not allowed, or
+	// that are denied, once renames and all other changes are applied.
+	// Headers such as Content-Type and Content-Length are always kept.
+	ResponseFilter *HeaderFilter `json:"responseFilter"`
+
+	// FailOpen passes requests and responses on without their renames and
+	// value changes when a rule fails or renaming panics, instead of failing
+	// them with 500 Internal Server Error. The failure is logged to stdout.
+	// Removals, JWT claims, decryption, signing, masking, security headers,
+	// the response filter and hop-by-hop removal still apply. Conflicts and
+	// missing required headers still fail as configured.
+	FailOpen bool `json:"failOpen"`
+
+	// AuditWebhook posts events about renamed and removed headers to a webhook.
+	AuditWebhook *AuditWebhook `json:"auditWebhook"`
+
+	// EmitSummaryTrailer adds a trailer summarizing the renames applied to the response.
+	EmitSummaryTrailer bool `json:"emitSummaryTrailer"`
+
+	// Strict makes rules without onConflict fail instead of overwriting a
+	// header that already exists with different values.
+	Strict bool `json:"strict"`
+	// ConflictStatus is the status of responses failing on a rename conflict,
+	// in strict mode or with onConflict fail. It defaults to 500.
+	ConflictStatus int `json:"conflictStatus"`
+
+	// SanitizeValues strips control characters such as CR and LF from the
+	// values of renamed headers. OnInvalid then selects what happens to
+	// renamed headers whose name or value is still invalid, see the
+	// Invalid* constants. Invalid names are never passed on.
+	SanitizeValues bool   `json:"sanitizeValues"`
+	OnInvalid      string `json:"onInvalid"`
+
+	// RemoveHopByHop removes hop-by-hop headers such as Connection,
+	// Keep-Alive, Transfer-Encoding and Proxy-* from requests and responses
+	// once renames and other edits are applied, so that a rename into one
+	// of them cannot corrupt the exchange.
+	RemoveHopByHop bool `json:"removeHopByHop"`
+
+	// AllowFramingHeaders allows renaming into headers such as Content-Length
+	// that control the framing of the response.
+	AllowFramingHeaders bool `json:"allowFramingHeaders"`
+
+	// RenamedFromHeader, when set, receives the original name of every renamed header.
+	RenamedFromHeader string `json:"renamedFromHeader"`
+	// OriginalHeaderPrefix, when set, keeps the original values of every
+	// renamed header under the prefixed original name.
+	OriginalHeaderPrefix string `json:"originalHeaderPrefix"`
+
+	// RemoveHeaders and RequestRemoveHeaders list the response and request
+	// headers to drop once renames are applied. Names may contain wildcards.
+	RemoveHeaders        []string `json:"removeHeaders"`
+	RequestRemoveHeaders []string `json:"requestRemoveHeaders"`
+
+	// SignHeaders and RequestSignHeaders add HMAC signatures of response and
+	// request header values, once renames and other edits are applied.
+	SignHeaders        []HeaderSignature `json:"signHeaders"`
+	RequestSignHeaders []HeaderSignature `json:"requestSignHeaders"`
+
+	// EncryptHeaders lists response headers encrypted once all other changes
+	// are applied, and decrypted when clients send them back in requests.
+	EncryptHeaders []HeaderEncryption `json:"encryptHeaders"`
+
+	// MaskHeaders and RequestMaskHeaders hide the values of response and
+	// request headers, once renames and other edits are applied.
+	MaskHeaders        []HeaderMask `json:"maskHeaders"`
+	RequestMaskHeaders []HeaderMask `json:"requestMaskHeaders"`
+
+	// DedupeHeaders lists the response headers whose repeated identical values
+	// are collapsed once all other changes are applied. Names may contain
+	// wildcards, "*" matches every header.
+	DedupeHeaders []string `json:"dedupeHeaders"`
+
+	// HeaderLimits limit the size of response headers once all other changes
+	// are applied.
+	HeaderLimits []HeaderLimit `json:"headerLimits"`
+
+	// RequireHeaders rejects requests and responses missing a header once all
+	// other changes are applied.
+	RequireHeaders *RequiredHeaders `json:"requireHeaders"`
+
+	// SetHeaders and AddHeaders set or add fixed response headers once renames
+	// and removals are applied; RequestSetHeaders and RequestAddHeaders do the
+	// same for requests.
+	SetHeaders        map[string]string `json:"setHeaders"`
+	AddHeaders        map[string]string `json:"addHeaders"`
+	RequestSetHeaders map[string]string `json:"requestSetHeaders"`
+	RequestAddHeaders map[string]string `json:"requestAddHeaders"`
+
+	// ReflectHeaders copies request headers, as forwarded to the backend,
+	// into the response once response renames are applied.
+	ReflectHeaders []ReflectedHeader `json:"reflectHeaders"`
+
+	// Coalesce and RequestCoalesce write the first non-empty of several
+	// response or request headers to a target, once renames are applied.
+	Coalesce        []CoalesceRule `json:"coalesce"`
+	RequestCoalesce []CoalesceRule `json:"requestCoalesce"`
+
+	// JWTClaims maps claims of the bearer token of requests into request
+	// headers, once request renames are applied and before the request
+	// edits: masking the token does not hide it from the claims, and
+	// signatures cover the claim values rather than those of the client.
+	JWTClaims *JWTClaims `json:"jwtClaims"`
+
+	// QueryToHeaders moves request query parameters into request headers.
+	QueryToHeaders []QueryMapping `json:"queryToHeaders"`
+	// HeadersToRedirectQuery moves response headers into query parameters of
+	// the Location of redirect responses.
+	HeadersToRedirectQuery []QueryMapping `json:"headersToRedirectQuery"`
+	// RewriteURLs rewrites the URLs of response headers such as Location,
+	// once response renames are applied.
+	RewriteURLs []URLRewrite `json:"rewriteURLs"`
+
+	// SecurityHeaders sets common security headers on responses, once all
+	// other edits are applied.
+	SecurityHeaders *SecurityHeaders `json:"securityHeaders"`
+
+	// GRPC renames gRPC metadata and rewrites gRPC statuses of gRPC calls.
+	GRPC *GRPC `json:"grpc"`
+
+	// CORS keeps CORS response headers consistent with renames.
+	CORS *CORS `json:"cors"`
+
+	// RenameCookies renames individual cookies in the Cookie request header
+	// and in Set-Cookie response headers.
+	RenameCookies []CookieRename `json:"renameCookies"`
+	// CookieAttributes rewrites attributes such as Domain of Set-Cookie
+	// response headers, once cookies are renamed.
+	CookieAttributes []CookieAttributes `json:"cookieAttributes"`
 }
 
 // CreateConfig creates and initializes the plugin configuration.
@@ -25,56 +345,505 @@ func CreateConfig() *Config {
 	return &Config{}
 }
 
+// isEmpty reports whether the configuration holds no rules at all.
+func (c *Config) isEmpty() bool {
+	return len(c.RenameData) == 0 && len(c.Renames) == 0 && len(c.RequestRenameData) == 0 &&
+		len(c.RemoveHeaders) == 0 && len(c.RequestRemoveHeaders) == 0 &&
+		len(c.SetHeaders) == 0 && len(c.AddHeaders) == 0 &&
+		len(c.RequestSetHeaders) == 0 && len(c.RequestAddHeaders) == 0 &&
+		len(c.SignHeaders) == 0 && len(c.RequestSignHeaders) == 0 &&
+		len(c.EncryptHeaders) == 0 &&
+		len(c.MaskHeaders) == 0 && len(c.RequestMaskHeaders) == 0 &&
+		len(c.DedupeHeaders) == 0 && len(c.HeaderLimits) == 0 &&
+		c.RequireHeaders == nil && len(c.Variants) == 0 && len(c.HostRules) == 0 &&
+		len(c.Coalesce) == 0 && len(c.RequestCoalesce) == 0 && len(c.ReflectHeaders) == 0 &&
+		!c.RemoveHopByHop && c.CORS == nil && c.SecurityHeaders == nil && c.JWTClaims == nil && c.GRPC == nil &&
+		len(c.QueryToHeaders) == 0 && len(c.HeadersToRedirectQuery) == 0 && len(c.RewriteURLs) == 0 &&
+		len(c.RenameCookies) == 0 && len(c.CookieAttributes) == 0 &&
+		c.RulesFile == "" && c.RulesURL == "" && c.Preset == ""
+}
+
 // renameHeaders is the main plugin structure.
 type renameHeaders struct {
 	name    string
 	next    http.Handler
-	renames []renameData
+	ruleSet *CompiledRuleSet
+	// indexes holds the current *ruleIndexes, replaced as a whole when rules
+	// are reloaded.
+	indexes atomic.Value
+
+	// localRequestRules are the rules of RequestRenameData, which apply
+	// in addition to the request rules of the rule set.
+	localRequestRules   []*compiledRule
+	allowFramingHeaders bool
+	removeHopByHop      bool
+	sanitizeValues      bool
+	onInvalid           string
+	strict              bool
+	conflictStatus      int
+	dryRun              bool
+	dryRunHeader        string
+	markerHeader        string
+	failOpen            bool
+
+	// preset converts request headers before the request rules apply.
+	preset func(http.Header)
+	// variants and hostRules replace the current rules for the requests
+	// they select.
+	variants  variants
+	hostRules hostRules
+
+	edits        headerEdits
+	requestEdits headerEdits
+
+	reflectHeaders  []ReflectedHeader
+	coalesce        []CoalesceRule
+	requestCoalesce []CoalesceRule
+
+	queryToHeaders         []QueryMapping
+	headersToRedirectQuery []QueryMapping
+	urlRewrites            urlRewrites
+	jwtClaims              *jwtClaims
+
+	cookies          cookieRenames
+	cookieAttributes []CookieAttributes
+	cors             *corsRules
+	grpc             *grpcRules
+	security         http.Header
+	responseFilter   *headerFilter
+
+	encryptions []headerEncryption
+	limits      []headerLimit
+	required    requiredHeaders
+
+	emitSummaryTrailer   bool
+	renamedFromHeader    string
+	originalHeaderPrefix string
+	spanFromContext      func(context.Context) Span
+	// logOutput receives the rule decisions, nil when they are not logged.
+	logOutput io.Writer
+	// errorOutput receives the errors that do not fail requests, such as
+	// failed rule reloads and failures passed through with failOpen.
+	errorOutput io.Writer
+	// metrics counts the rule decisions, nil when they are not counted.
+	metrics            *ruleMetrics
+	metricsPath        string
+	metricsSourceRange sourceRange
+	auditWebhook       *auditWebhook
 }
 
+// errNoRules reports a configuration without any rule.
+var errNoRules = errors.New("no rename data configured: at least one rename rule is required")
+
+// Option customizes a handler created with NewWithOptions.
+type Option func(*renameHeaders)
+
 // New creates a new Custom Header plugin.
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	return NewWithOptions(ctx, next, config, name)
+}
+
+// NewWithOptions creates a new Custom Header plugin for programmatic use.
+func NewWithOptions(ctx context.Context, next http.Handler, config *Config, name string, opts ...Option) (http.Handler, error) {
 	// Config validation
 	if config == nil {
 		return nil, errors.New("config cannot be nil")
 	}
 	
-	if len(config.RenameData) == 0 {
-		return nil, errors.New("no rename data configured: at least one rename rule is required")
+	config, err := config.resolve()
+	if err != nil {
+		return nil, err
 	}
-	
+
+	handler := &renameHeaders{
+		name: name,
+		next: next,
+
+		allowFramingHeaders: config.AllowFramingHeaders,
+		removeHopByHop:      config.RemoveHopByHop,
+		sanitizeValues:      config.SanitizeValues,
+		strict:              config.Strict,
+		conflictStatus:      config.ConflictStatus,
+		dryRun:              config.DryRun,
+		dryRunHeader:        config.DryRunHeader,
+		markerHeader:        config.MarkerHeader,
+		failOpen:            config.FailOpen,
+
+		emitSummaryTrailer:   config.EmitSummaryTrailer,
+		renamedFromHeader:    config.RenamedFromHeader,
+		originalHeaderPrefix: config.OriginalHeaderPrefix,
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	handler.onInvalid, err = compileOnInvalid(config.OnInvalid)
+	if err != nil {
+		return nil, err
+	}
+	if handler.dryRunHeader == "" {
+		handler.dryRunHeader = DefaultDryRunHeader
+	} else if !isToken(handler.dryRunHeader) {
+		return nil, fmt.Errorf("dryRunHeader %q is not a valid header name", handler.dryRunHeader)
+	}
+	if handler.markerHeader != "" && !isToken(handler.markerHeader) {
+		return nil, fmt.Errorf("markerHeader %q is not a valid header name", handler.markerHeader)
+	}
+	debug, err := compileLogLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	if debug {
+		handler.logOutput = os.Stdout
+	}
+	handler.errorOutput = os.Stdout
+	metricsInterval, err := compileMetrics(config.MetricsPath, config.MetricsLogInterval)
+	if err != nil {
+		return nil, err
+	}
+	handler.metricsSourceRange, err = compileMetricsSourceRange(config.MetricsPath, config.MetricsSourceRange)
+	if err != nil {
+		return nil, err
+	}
+	if config.MetricsPath != "" || metricsInterval > 0 {
+		handler.metrics = newRuleMetrics()
+		handler.metricsPath = config.MetricsPath
+	}
+	if handler.conflictStatus == 0 {
+		handler.conflictStatus = http.StatusInternalServerError
+	} else if handler.conflictStatus < 400 || handler.conflictStatus > 599 {
+		return nil, fmt.Errorf("conflictStatus %d is not an error status", handler.conflictStatus)
+	}
+	if handler.ruleSet == nil && config.isEmpty() {
+		return nil, errNoRules
+	}
+	source, err := newRuleSource(config)
+	if err != nil {
+		return nil, err
+	}
+	if source != nil && handler.ruleSet != nil {
+		return nil, errors.New("rulesFile and rulesURL cannot be combined with WithRuleSet")
+	}
+	refreshInterval, err := compileRefreshInterval(config.RulesRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate each rename configuration
-	for i, rename := range config.RenameData {
-		if rename.ExistingHeaderName == "" {
-			return nil, fmt.Errorf("rename rule %d: existing header name cannot be empty", i)
-		}
-		if rename.NewHeaderName == "" {
-			return nil, fmt.Errorf("rename rule %d: new header name cannot be empty", i)
+	if handler.ruleSet == nil {
+		handler.ruleSet, err = CompileRules(config.RenameData)
+		if err != nil {
+			return nil, err
 		}
 	}
-	
-	return &renameHeaders{
-		name:    name,
-		next:    next,
-		renames: config.RenameData,
+
+	handler.localRequestRules, err = compileRequestRules(config.RequestRenameData)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := handler.indexRules(handler.ruleSet, handler.localRequestRules)
+	if err != nil {
+		return nil, err
+	}
+	handler.indexes.Store(indexes)
+	handler.variants, err = handler.compileVariants(config)
+	if err != nil {
+		return nil, err
+	}
+	handler.hostRules, err = handler.compileHostRules(config.HostRules)
+	if err != nil {
+		return nil, err
+	}
+
+	handler.edits, err = compileHeaderEdits(config.RemoveHeaders, config.SetHeaders, config.AddHeaders)
+	if err != nil {
+		return nil, err
+	}
+	handler.edits.sign, err = compileHeaderSignatures(config.SignHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("signHeaders: %w", err)
+	}
+	handler.edits.mask, err = compileHeaderMasks(config.MaskHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("maskHeaders: %w", err)
+	}
+	handler.edits.dedupe, err = compileHeaderMatchers(config.DedupeHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("dedupeHeaders: %w", err)
+	}
+	handler.requestEdits, err = compileHeaderEdits(config.RequestRemoveHeaders, config.RequestSetHeaders, config.RequestAddHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("request %w", err)
+	}
+	handler.requestEdits.sign, err = compileHeaderSignatures(config.RequestSignHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("requestSignHeaders: %w", err)
+	}
+	handler.requestEdits.mask, err = compileHeaderMasks(config.RequestMaskHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("requestMaskHeaders: %w", err)
+	}
+	handler.preset, err = compilePresets(config.Preset)
+	if err != nil {
+		return nil, err
+	}
+	handler.security = compileSecurityHeaders(config.SecurityHeaders)
+	handler.grpc, err = compileGRPC(config.GRPC)
+	if err != nil {
+		return nil, err
+	}
+	handler.cors, err = compileCORS(config.CORS)
+	if err != nil {
+		return nil, err
+	}
+	handler.reflectHeaders, err = compileReflectedHeaders(config.ReflectHeaders)
+	if err != nil {
+		return nil, err
+	}
+	handler.coalesce, err = compileCoalesceRules(config.Coalesce)
+	if err != nil {
+		return nil, err
+	}
+	handler.requestCoalesce, err = compileCoalesceRules(config.RequestCoalesce)
+	if err != nil {
+		return nil, fmt.Errorf("request %w", err)
+	}
+	handler.queryToHeaders, err = compileQueryMappings(config.QueryToHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("queryToHeaders: %w", err)
+	}
+	handler.headersToRedirectQuery, err = compileQueryMappings(config.HeadersToRedirectQuery)
+	if err != nil {
+		return nil, fmt.Errorf("headersToRedirectQuery: %w", err)
+	}
+	handler.urlRewrites, err = compileURLRewrites(config.RewriteURLs)
+	if err != nil {
+		return nil, fmt.Errorf("rewriteURLs: %w", err)
+	}
+	handler.jwtClaims, err = compileJWTClaims(config.JWTClaims)
+	if err != nil {
+		return nil, err
+	}
+	handler.encryptions, err = compileHeaderEncryptions(config.EncryptHeaders)
+	if err != nil {
+		return nil, err
+	}
+	handler.limits, err = compileHeaderLimits(config.HeaderLimits)
+	if err != nil {
+		return nil, err
+	}
+	handler.required, err = compileRequiredHeaders(config.RequireHeaders)
+	if err != nil {
+		return nil, err
+	}
+	handler.cookies, err = compileCookieRenames(config.RenameCookies)
+	if err != nil {
+		return nil, err
+	}
+	handler.cookieAttributes, err = compileCookieAttributes(config.CookieAttributes)
+	if err != nil {
+		return nil, err
+	}
+	handler.responseFilter, err = compileHeaderFilter(config.ResponseFilter)
+	if err != nil {
+		return nil, err
+	}
+	handler.auditWebhook, err = compileAuditWebhook(ctx, config.AuditWebhook, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Background work starts once the configuration is valid, so that it
+	// does not outlive a handler New failed to create.
+	if metricsInterval > 0 {
+		go handler.metrics.logEvery(ctx, os.Stdout, name, metricsInterval)
+	}
+	if source != nil {
+		handler.startReloading(ctx, source, refreshInterval)
+	}
+	return handler, nil
+}
+
+// indexRules validates the targets of a rule set and indexes its rules, with
+// local request rules applying in addition to its request rules.
+func (r *renameHeaders) indexRules(set *CompiledRuleSet, local []*compiledRule) (*ruleIndexes, error) {
+	rules := forMiddleware(set.rules, r.name)
+	// Copy, as the set may be shared with other handlers.
+	requestRules := forMiddleware(append(append([]*compiledRule(nil), set.requestRules...), local...), r.name)
+	if err := validateTargets(rules, r.allowFramingHeaders); err != nil {
+		return nil, err
+	}
+	if err := validateTargets(requestRules, r.allowFramingHeaders); err != nil {
+		return nil, fmt.Errorf("request %w", err)
+	}
+	if r.allowFramingHeaders {
+		rules = framingRules(rules)
+		requestRules = framingRules(requestRules)
+	}
+	if r.strict {
+		rules = strictRules(rules)
+		requestRules = strictRules(requestRules)
+	}
+
+	return &ruleIndexes{
+		rules:        newRuleIndex(rules),
+		requestRules: newRuleIndex(requestRules),
+		trailerRules: newRuleIndex(trailerRules(rules)),
 	}, nil
 }
 
+// currentRules returns the current rule indexes.
+func (r *renameHeaders) currentRules() *ruleIndexes {
+	return r.indexes.Load().(*ruleIndexes)
+}
+
+// rulesFor returns the rule indexes of the variant or host selected by req,
+// the current ones when it selects none.
+func (r *renameHeaders) rulesFor(req *http.Request) *ruleIndexes {
+	if indexes := r.variants.rulesFor(req); indexes != nil {
+		return indexes
+	}
+	if indexes := r.hostRules.rulesFor(req); indexes != nil {
+		return indexes
+	}
+	return r.currentRules()
+}
+
 // ServeHTTP implements the http.Handler interface.
 func (r *renameHeaders) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	wrappedWriter := &responseWriter{
-		ResponseWriter:  rw,
-		headersToRename: r.renames,
+	req = withSample(withMiddleware(req, r.name))
+	if r.servesMetrics(req) {
+		r.metrics.ServeHTTP(rw, req)
+		return
+	}
+	if r.marked(req) {
+		r.next.ServeHTTP(rw, req)
+		return
+	}
+	decryptHeaders(req.Header, r.encryptions)
+
+	var restore func()
+	if r.failOpen {
+		restore = snapshotRequest(req)
 	}
+	var indexes, planned *ruleIndexes
+	var renamed []RenamedHeader
+	err := r.guard("request", restore, func() error {
+		if r.preset != nil {
+			r.preset(req.Header)
+		}
+
+		// Load the rules once, so that a reload does not change them mid-request.
+		indexes = r.rulesFor(req)
+		if r.dryRun {
+			// The rules are only planned, and no rule applies.
+			planned, indexes = indexes, &ruleIndexes{}
+			r.setPlan(planned.requestRules, req.Header, req, 0)
+		}
+		applied, err := r.apply(indexes.requestRules, req.Header, req, 0)
+		if err != nil {
+			return err
+		}
+		if r.grpc.appliesTo(req) {
+			r.grpc.applyMetadata(req.Header)
+		}
+		coalesceHeaders(req.Header, r.requestCoalesce)
+		queryToHeaders(req, r.queryToHeaders)
+		r.cookies.applyRequest(req.Header)
+		renamed = applied
+		return nil
+	})
+	if err != nil {
+		status := r.errorStatus(err)
+		http.Error(rw, http.StatusText(status), status)
+		return
+	}
+	if indexes == nil {
+		// The request failed open before its rules were loaded.
+		indexes = r.currentRules()
+	}
+	// Removals and the other edits guarding the backend apply even when the
+	// renames failed open.
+	r.jwtClaims.apply(req.Header)
+	r.auditWebhook.removed(req, 0, r.requestEdits.apply(req.Header))
+	if r.removeHopByHop {
+		removeHopByHopHeaders(req.Header, false)
+	}
+	if missing(req.Header, r.required.request) != "" {
+		http.Error(rw, http.StatusText(r.required.requestStatus), r.required.requestStatus)
+		return
+	}
+
+	req = r.mark(req)
+	wrappedWriter := newResponseWriter(rw, r, req, indexes)
+	wrappedWriter.planned = planned
+	wrappedWriter.requestRenamed = renamed
 	
 	r.next.ServeHTTP(wrappedWriter, req)
+	if !wrappedWriter.headerWritten {
+		// The handler wrote nothing, and the server would write the head
+		// without the response changes once it returns.
+		wrappedWriter.WriteHeader(http.StatusOK)
+	}
+
+	wrappedWriter.renameTrailers()
+	if r.emitSummaryTrailer {
+		wrappedWriter.writeSummaryTrailer()
+	}
+	wrappedWriter.release()
+}
+
+// responseWriters pools the wrappers, which are allocated for every request.
+var responseWriters = sync.Pool{
+	New: func() interface{} {
+		return &responseWriter{}
+	},
+}
+
+// newResponseWriter returns a wrapper from the pool.
+func newResponseWriter(rw http.ResponseWriter, handler *renameHeaders, req *http.Request, indexes *ruleIndexes) *responseWriter {
+	w := responseWriters.Get().(*responseWriter)
+	w.ResponseWriter = rw
+	w.handler = handler
+	w.req = req
+	w.indexes = indexes
+	w.grpc = handler.grpc.appliesTo(req)
+	return w
+}
+
+// release resets the wrapper and returns it to the pool once the handler has
+// returned. A hijacked connection may still reference it, so it is then
+// left to the garbage collector.
+func (r *responseWriter) release() {
+	if r.hijacked {
+		return
+	}
+	*r = responseWriter{}
+	responseWriters.Put(r)
 }
 
 // responseWriter wraps the original http.ResponseWriter to intercept and modify headers.
 type responseWriter struct {
 	http.ResponseWriter
-	headersToRename []renameData
-	headerWritten   bool
+	handler       *renameHeaders
+	req           *http.Request
+	indexes       *ruleIndexes
+	renamed       []RenamedHeader
+	status        int
+	headerWritten bool
+	hijacked      bool
+	// requestRenamed holds the renames applied to the request, recorded on
+	// the span with those of the response.
+	requestRenamed []RenamedHeader
+	// grpc is set for gRPC calls, when gRPC options are configured.
+	grpc bool
+	// discard drops the body once the response has been replaced by an error.
+	discard bool
+
+	// trailers holds the names of the trailers declared by the handler.
+	trailers []string
+	// planned holds the rules only planned in dry-run mode.
+	planned *ruleIndexes
 }
 
 // WriteHeader intercepts the status code writing to rename headers before they are sent.
@@ -82,48 +851,172 @@ func (r *responseWriter) WriteHeader(statusCode int) {
 	if r.headerWritten {
 		return
 	}
+	if isInformational(statusCode) {
+		r.writeInformational(statusCode)
+		return
+	}
 	
 	// Rename headers before writing
-	for _, headerToRename := range r.headersToRename {
-		headerValues := r.Header().Values(headerToRename.ExistingHeaderName)
-		
-		if len(headerValues) == 0 {
-			continue
-		}
-		
-		// Remove old header and add with new name
-		r.Header().Del(headerToRename.ExistingHeaderName)
-		r.Header()[headerToRename.NewHeaderName] = headerValues
+	r.status = statusCode
+	var restore func()
+	if r.handler.failOpen {
+		restore = snapshotHeader(r.Header())
+	}
+	err := r.handler.guard("response", restore, func() error {
+		return r.transform(r.Header(), statusCode)
+	})
+	if err != nil {
+		r.fail(r.handler.errorStatus(err))
+		return
+	}
+	r.edit(r.Header(), statusCode)
+	if missing(r.Header(), r.handler.required.response) != "" {
+		r.fail(r.handler.required.responseStatus)
+		return
+	}
+	r.handler.recordSpan(r.req.Context(), r.requestRenamed, r.renamed)
+	if r.handler.emitSummaryTrailer {
+		r.Header().Add("Trailer", SummaryTrailer)
 	}
 	
 	r.headerWritten = true
 	r.ResponseWriter.WriteHeader(statusCode)
 }
 
+// transform applies the renames and value changes to the response header,
+// r.Header() unless the head is written to a hijacked connection. The renames
+// are only recorded once all changes are applied.
+func (r *responseWriter) transform(header http.Header, statusCode int) error {
+	if !r.indexes.trailerRules.empty() || r.grpc {
+		r.renameTrailerDeclarations(header)
+	}
+	if r.planned != nil {
+		r.handler.setPlan(r.planned.rules, header, r.req, statusCode)
+	}
+	renamed, err := r.handler.apply(r.indexes.rules, header, r.req, statusCode)
+	if err != nil {
+		return err
+	}
+	if r.grpc {
+		// Trailers-only responses carry the status in the header.
+		r.handler.grpc.applyMetadata(header)
+		r.handler.grpc.applyStatus(header)
+	}
+	reflectHeaders(header, r.req, r.handler.reflectHeaders)
+	coalesceHeaders(header, r.handler.coalesce)
+	r.handler.urlRewrites.apply(header)
+	headersToRedirectQuery(header, statusCode, r.handler.headersToRedirectQuery)
+	r.handler.cookies.applyResponse(header)
+	rewriteCookieAttributes(header, r.handler.cookieAttributes)
+	r.handler.cors.apply(header, r.req, renamed)
+	r.renamed = renamed
+	return nil
+}
+
+// edit applies the removals, security headers and other edits of the
+// response header, which apply even when the renames failed open.
+func (r *responseWriter) edit(header http.Header, statusCode int) {
+	r.handler.auditWebhook.removed(r.req, statusCode, r.handler.edits.apply(header))
+	setSecurityHeaders(header, r.handler.security)
+	if r.handler.markerHeader != "" {
+		header.Del(r.handler.markerHeader)
+	}
+	r.handler.responseFilter.apply(header, statusCode)
+	if r.handler.removeHopByHop {
+		removeHopByHopHeaders(header, true)
+	}
+	encryptHeaders(header, r.handler.encryptions)
+	applyHeaderLimits(header, r.handler.limits)
+}
+
+// isInformational reports whether status is an interim response followed by
+// the final one. 101 Switching Protocols ends the HTTP exchange instead.
+func isInformational(status int) bool {
+	return status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+}
+
+// writeInformational renames the headers sent with an interim response such
+// as 103 Early Hints. The header map is kept for the final response, which
+// is renamed again when written.
+func (r *responseWriter) writeInformational(statusCode int) {
+	var restore func()
+	if r.handler.failOpen {
+		restore = snapshotHeader(r.Header())
+	}
+	err := r.handler.guard("response", restore, func() error {
+		_, err := r.handler.apply(r.indexes.rules, r.Header(), r.req, statusCode)
+		return err
+	})
+	if err != nil {
+		r.fail(r.handler.errorStatus(err))
+		return
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
 // Write ensures headers are written before body.
 func (r *responseWriter) Write(bytes []byte) (int, error) {
 	if !r.headerWritten {
 		r.WriteHeader(http.StatusOK)
 	}
+	if r.discard {
+		return len(bytes), nil
+	}
 	return r.ResponseWriter.Write(bytes)
 }
 
+// ReadFrom implements the io.ReaderFrom interface, so that the underlying
+// writer can use sendfile or splice for large bodies.
+func (r *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !r.headerWritten {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.discard {
+		return io.Copy(io.Discard, src)
+	}
+	return io.Copy(r.ResponseWriter, src)
+}
+
 // Hijack implements the http.Hijacker interface for WebSocket support.
 func (r *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hijacker, ok := r.ResponseWriter.(http.Hijacker)
 	if !ok {
 		return nil, nil, fmt.Errorf("ResponseWriter of type %T does not support hijacking", r.ResponseWriter)
 	}
-	return hijacker.Hijack()
+
+	// A 101 Switching Protocols written through WriteHeader has been renamed
+	// already and is flushed by the server on hijack. Otherwise the handler
+	// writes the response head to the connection itself, as the reverse
+	// proxy does for upgrades, and it is transformed on the way.
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+	if !r.headerWritten {
+		conn, rw = r.interceptUpgrade(conn, rw)
+	}
+	r.headerWritten = true
+	r.hijacked = true
+	return conn, rw, nil
 }
 
 // Flush implements the http.Flusher interface for SSE and streaming responses.
+// Flushing commits the response head, so headers are renamed first.
 func (r *responseWriter) Flush() {
+	if !r.headerWritten {
+		r.WriteHeader(http.StatusOK)
+	}
 	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
 		flusher.Flush()
 	}
 }
 
+// Unwrap returns the underlying writer, so that http.ResponseController can
+// reach methods such as SetWriteDeadline that the wrapper does not implement.
+func (r *responseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // Push implements the http.Pusher interface for HTTP/2 server push support.
 func (r *responseWriter) Push(target string, opts *http.PushOptions) error {
 	if pusher, ok := r.ResponseWriter.(http.Pusher); ok {
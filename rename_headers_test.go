@@ -2,21 +2,27 @@ package traefik_header_rename_plugin
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestServeHTTP(t *testing.T) {
 	tests := []struct {
 		desc          string
-		renames       []renameData
+		renames       []RenameRule
 		reqHeader     http.Header
+		tls           bool
+		status        int
 		expRespHeader http.Header
 	}{
 		{
 			desc: "Should rename headers while keeping their values",
-			renames: []renameData{
+			renames: []RenameRule{
 				{
 					ExistingHeaderName: "Foo",
 					NewHeaderName:      "bar",
@@ -35,6 +41,424 @@ func TestServeHTTP(t *testing.T) {
 				"another-bar": {"another-fooval", "another-fooval2"},
 			},
 		},
+		{
+			desc: "Should map wildcard segments positionally",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-*-Old-*",
+					NewHeaderName:      "X-$1-New-$2",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Foo-Old-Bar":     {"foobar"},
+				"X-Foo-Baz-Old-Qux": {"foobazqux"},
+				"X-Foo-Old":         {"untouched"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Foo-New-Bar":     {"foobar"},
+				"X-Foo-Baz-New-Qux": {"foobazqux"},
+				"X-Foo-Old":         {"untouched"},
+				"X-Foo-Old-Bar":     nil,
+				"X-Foo-Baz-Old-Qux": nil,
+			},
+		},
+		{
+			desc: "Should allow reordering wildcard segments",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-*-*",
+					NewHeaderName:      "X-$2-$1",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Foo-Bar": {"foobar"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Bar-Foo": {"foobar"},
+			},
+		},
+		{
+			desc: "Should bulk rename headers sharing a prefix",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Old-*",
+					NewHeaderName:      "X-New-*",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Old-Foo":     {"foo"},
+				"X-Old-Bar-Baz": {"barbaz"},
+				"X-Other":       {"other"},
+			},
+			expRespHeader: map[string][]string{
+				"X-New-Foo":     {"foo"},
+				"X-New-Bar-Baz": {"barbaz"},
+				"X-Other":       {"other"},
+				"X-Old-Foo":     nil,
+				"X-Old-Bar-Baz": nil,
+			},
+		},
+		{
+			desc: "Should rename headers matching a regex using capture groups",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "^X-Legacy-(.*)$",
+					NewHeaderName:      "X-New-$1",
+					Regex:              true,
+				},
+				{
+					ExistingHeaderName: "^X-(?P<kind>Old|Older)-Id$",
+					NewHeaderName:      "X-Id-${kind}",
+					Regex:              true,
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Legacy-Token": {"secret"},
+				"X-Legacy-User":  {"alice"},
+				"X-Older-Id":     {"1"},
+				"X-Unrelated":    {"untouched"},
+			},
+			expRespHeader: map[string][]string{
+				"X-New-Token":    {"secret"},
+				"X-New-User":     {"alice"},
+				"X-Id-Older":     {"1"},
+				"X-Unrelated":    {"untouched"},
+				"X-Legacy-Token": nil,
+				"X-Older-Id":     nil,
+			},
+		},
+		{
+			desc: "Should exempt excepted headers from pattern rules",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-*",
+					NewHeaderName:      "X-Legacy-$1",
+					Except:             []string{"x-frame-options", "X-Content-*"},
+				},
+				{
+					ExistingHeaderName: "^Y-(.*)$",
+					NewHeaderName:      "Z-$1",
+					Regex:              true,
+					Except:             []string{"Y-Keep"},
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Id":                   {"1"},
+				"X-Frame-Options":        {"DENY"},
+				"X-Content-Type-Options": {"nosniff"},
+				"Y-Id":                   {"2"},
+				"Y-Keep":                 {"3"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Legacy-Id":            {"1"},
+				"X-Frame-Options":        {"DENY"},
+				"X-Content-Type-Options": {"nosniff"},
+				"Z-Id":                   {"2"},
+				"Y-Keep":                 {"3"},
+				"Z-Keep":                 nil,
+			},
+		},
+		{
+			desc: "Should copy headers when keeping the original",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Legacy-Token",
+					NewHeaderName:      "X-Token",
+					KeepOriginal:       true,
+				},
+				{
+					ExistingHeaderName: "X-Old-*",
+					NewHeaderName:      "X-New-*",
+					KeepOriginal:       true,
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Legacy-Token": {"secret", "secret2"},
+				"X-Old-Foo":      {"foo"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Legacy-Token": {"secret", "secret2"},
+				"X-Token":        {"secret", "secret2"},
+				"X-Old-Foo":      {"foo"},
+				"X-New-Foo":      {"foo"},
+			},
+		},
+		{
+			desc: "Should rewrite values of renamed headers",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Served-By",
+					NewHeaderName:      "X-Server",
+					ValueReplacement: &ValueReplacement{
+						Regexp:      `^([^.]+)\.internal\.local$`,
+						Replacement: "$1",
+					},
+				},
+				{
+					ExistingHeaderName: "X-Region",
+					NewHeaderName:      "X-Region",
+					ValueReplacement: &ValueReplacement{
+						Regexp:      "dc-",
+						Replacement: "",
+					},
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Served-By": {"web-1.internal.local", "web-2.example.com"},
+				"X-Region":    {"dc-eu"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Server":    {"web-1", "web-2.example.com"},
+				"X-Served-By": nil,
+				"X-Region":    {"eu"},
+			},
+		},
+		{
+			desc: "Should split comma-separated values into lines",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Folded",
+					NewHeaderName:      "X-Lines",
+					Split:              true,
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Folded": {`a, "b, c"`, "d,,e"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Lines": {"a", `"b, c"`, "d", "e"},
+			},
+		},
+		{
+			desc: "Should join values with the separator",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Lines",
+					NewHeaderName:      "X-Folded",
+					Join:               true,
+					JoinSeparator:      ";",
+				},
+				{
+					ExistingHeaderName: "X-Other-Lines",
+					NewHeaderName:      "X-Other-Folded",
+					Join:               true,
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Lines":       {"a", "b"},
+				"X-Other-Lines": {"a", "b"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Folded":       {"a;b"},
+				"X-Other-Folded": {"a, b"},
+			},
+		},
+		{
+			desc: "Should copy a header to several new names",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Request-Id",
+					NewHeaderName:      "X-Correlation-Id",
+					NewHeaderNames:     []string{"Trace-Id"},
+				},
+				{
+					ExistingHeaderName: "X-Tenant",
+					NewHeaderNames:     []string{"X-Tenant-Id", "X-Org"},
+					KeepOriginal:       true,
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Request-Id": {"abc"},
+				"X-Tenant":     {"acme"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Correlation-Id": {"abc"},
+				"Trace-Id":         {"abc"},
+				"X-Request-Id":     nil,
+				"X-Tenant-Id":      {"acme"},
+				"X-Org":            {"acme"},
+				"X-Tenant":         {"acme"},
+			},
+		},
+		{
+			desc: "Should emit the default value when the header is missing",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Tenant",
+					NewHeaderName:      "X-Tenant-Id",
+					DefaultValue:       "default",
+				},
+				{
+					ExistingHeaderName: "X-Plan",
+					NewHeaderName:      "X-Plan-Id",
+					DefaultValue:       "free",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Plan": {"pro"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Tenant-Id": {"default"},
+				"X-Plan-Id":   {"pro"},
+			},
+		},
+		{
+			desc: "Should rename when the conditional header has the expected value",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName:    "X-Served-By",
+					NewHeaderName:         "X-Origin-Served-By",
+					WhenHeaderValueEquals: &HeaderValue{Name: "X-Cache", Value: "MISS"},
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Cache":     {"MISS"},
+				"X-Served-By": {"origin-1"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Origin-Served-By": {"origin-1"},
+				"X-Served-By":        nil,
+			},
+		},
+		{
+			desc: "Should skip when the conditional header has another value",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName:    "X-Served-By",
+					NewHeaderName:         "X-Origin-Served-By",
+					WhenHeaderValueEquals: &HeaderValue{Name: "X-Cache", Value: "MISS"},
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Cache":     {"HIT"},
+				"X-Served-By": {"origin-1"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Origin-Served-By": nil,
+				"X-Served-By":        {"origin-1"},
+			},
+		},
+		{
+			desc: "Should apply rules to responses with a matching status code",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Cache-Control",
+					NewHeaderName:      "X-Upstream-Cache-Control",
+					StatusCodes:        "200-299,404",
+				},
+			},
+			reqHeader: map[string][]string{
+				"Cache-Control": {"max-age=60"},
+			},
+			status: http.StatusNotFound,
+			expRespHeader: map[string][]string{
+				"X-Upstream-Cache-Control": {"max-age=60"},
+				"Cache-Control":            nil,
+			},
+		},
+		{
+			desc: "Should skip rules on responses with another status code",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Cache-Control",
+					NewHeaderName:      "X-Upstream-Cache-Control",
+					StatusCodes:        "200-299",
+				},
+			},
+			reqHeader: map[string][]string{
+				"Cache-Control": {"no-store"},
+			},
+			status: http.StatusBadGateway,
+			expRespHeader: map[string][]string{
+				"X-Upstream-Cache-Control": nil,
+				"Cache-Control":            {"no-store"},
+			},
+		},
+		{
+			desc: "Should only rename headers whose value matches",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Backend",
+					NewHeaderName:      "X-Legacy-Backend",
+					ValueRegexp:        "^legacy-",
+				},
+				{
+					ExistingHeaderName: "X-Node-*",
+					NewHeaderName:      "X-Legacy-Node-*",
+					ValueRegexp:        "^legacy-",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Backend": {"legacy-eu-1"},
+				"X-Node-A":  {"legacy-a"},
+				"X-Node-B":  {"modern-b"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Legacy-Backend": {"legacy-eu-1"},
+				"X-Backend":        nil,
+				"X-Legacy-Node-A":  {"legacy-a"},
+				"X-Node-B":         {"modern-b"},
+				"X-Legacy-Node-B":  nil,
+			},
+		},
+		{
+			desc: "Should skip headers whose value does not match",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Backend",
+					NewHeaderName:      "X-Legacy-Backend",
+					ValueRegexp:        "^legacy-",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Backend": {"modern-eu-1"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Legacy-Backend": nil,
+				"X-Backend":        {"modern-eu-1"},
+			},
+		},
+		{
+			desc: "Should apply TLS only rules to TLS requests",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Internal-Id",
+					NewHeaderName:      "X-Id",
+					WhenTLSOnly:        true,
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Internal-Id": {"42"},
+			},
+			tls: true,
+			expRespHeader: map[string][]string{
+				"X-Id":          {"42"},
+				"X-Internal-Id": nil,
+			},
+		},
+		{
+			desc: "Should skip TLS only rules on plaintext requests",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Internal-Id",
+					NewHeaderName:      "X-Id",
+					WhenTLSOnly:        true,
+				},
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Internal-Id": {"42"},
+				"Foo":           {"foo"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Id":          nil,
+				"X-Internal-Id": {"42"},
+				"Bar":           {"foo"},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -43,13 +467,18 @@ func TestServeHTTP(t *testing.T) {
 				RenameData: test.renames,
 			}
 
+			status := test.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
 			next := func(rw http.ResponseWriter, req *http.Request) {
 				for k, v := range test.reqHeader {
 					for _, h := range v {
 						rw.Header().Add(k, h)
 					}
 				}
-				rw.WriteHeader(http.StatusOK)
+				rw.WriteHeader(status)
 			}
 
 			rewriteBody, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
@@ -59,6 +488,9 @@ func TestServeHTTP(t *testing.T) {
 
 			recorder := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
 
 			rewriteBody.ServeHTTP(recorder, req)
 			for k, expected := range test.expRespHeader {
@@ -72,6 +504,823 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+func TestCanonicalizeNewName(t *testing.T) {
+	canonical, literal := true, false
+	tests := []struct {
+		desc          string
+		canonicalize  *bool
+		respHeader    http.Header
+		expRespHeader http.Header
+	}{
+		{
+			desc: "Should look up canonically and write literally by default",
+			respHeader: map[string][]string{
+				"X-Foo": {"foo"},
+			},
+			expRespHeader: map[string][]string{
+				"x-bar": {"foo"},
+				"X-Foo": nil,
+				"X-Bar": nil,
+			},
+		},
+		{
+			desc:         "Should write the canonical name when enabled",
+			canonicalize: &canonical,
+			respHeader: map[string][]string{
+				"X-Foo": {"foo"},
+			},
+			expRespHeader: map[string][]string{
+				"X-Bar": {"foo"},
+				"x-bar": nil,
+				"X-Foo": nil,
+			},
+		},
+		{
+			desc:         "Should match and write names byte-exact when disabled",
+			canonicalize: &literal,
+			respHeader: map[string][]string{
+				"x-foo": {"literal"},
+				"X-Foo": {"canonical"},
+			},
+			expRespHeader: map[string][]string{
+				"x-bar": {"literal"},
+				"x-foo": nil,
+				"X-Foo": {"canonical"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{
+					{
+						ExistingHeaderName:  "x-foo",
+						NewHeaderName:       "x-bar",
+						CanonicalizeNewName: test.canonicalize,
+					},
+				},
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				for k, v := range test.respHeader {
+					rw.Header()[k] = v
+				}
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			for k, expected := range test.expRespHeader {
+				values := recorder.Result().Header[k]
+
+				if !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestConditions(t *testing.T) {
+	rule := RenameRule{
+		ExistingHeaderName: "Foo",
+		NewHeaderName:      "Bar",
+		PathPrefix:         "/api/v1",
+		Methods:            []string{"get", "POST"},
+		HostRegexp:         `^(www\.)?example\.com$`,
+	}
+
+	tests := []struct {
+		desc       string
+		method     string
+		target     string
+		expRenamed bool
+	}{
+		{desc: "Should apply to matching requests", method: http.MethodGet, target: "http://example.com:8080/api/v1/users", expRenamed: true},
+		{desc: "Should match methods case-insensitively", method: http.MethodPost, target: "http://www.example.com/api/v1", expRenamed: true},
+		{desc: "Should skip other paths", method: http.MethodGet, target: "http://example.com/api/v2"},
+		{desc: "Should skip other methods", method: http.MethodDelete, target: "http://example.com/api/v1"},
+		{desc: "Should skip other hosts", method: http.MethodGet, target: "http://example.org/api/v1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{rule},
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Foo", "foo")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.target, nil))
+
+			if renamed := recorder.Result().Header.Get("Bar") == "foo"; renamed != test.expRenamed {
+				t.Errorf("expected renamed %v, got headers %+v", test.expRenamed, recorder.Result().Header)
+			}
+		})
+	}
+}
+
+func TestNameRegexp(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "Foo", NewHeaderName: "Bar", NameRegexp: `^api-.*@file$`},
+			{ExistingHeaderName: "Foo", NewHeaderName: "Baz", NameRegexp: `^web-`},
+		},
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Client", NewHeaderName: "X-Api-Client", NameRegexp: `^api-`},
+		},
+	}
+
+	tests := []struct {
+		desc        string
+		name        string
+		expResponse map[string][]string
+		expRequest  map[string][]string
+	}{
+		{
+			desc:        "Should apply the rules matching the middleware name",
+			name:        "api-rename@file",
+			expResponse: map[string][]string{"Foo": nil, "Bar": {"foo"}, "Baz": nil},
+			expRequest:  map[string][]string{"X-Client": nil, "X-Api-Client": {"c"}},
+		},
+		{
+			desc:        "Should skip the rules of other middlewares",
+			name:        "web-rename@file",
+			expResponse: map[string][]string{"Foo": nil, "Bar": nil, "Baz": {"foo"}},
+			expRequest:  map[string][]string{"X-Client": {"c"}, "X-Api-Client": nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.Header().Set("Foo", "foo")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Client", "c")
+			handler.ServeHTTP(recorder, req)
+
+			for k, expected := range test.expRequest {
+				if values := forwarded[k]; !testEq(values, expected) {
+					t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+			for k, expected := range test.expResponse {
+				if values := recorder.Result().Header[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestServeHTTPRequest(t *testing.T) {
+	tests := []struct {
+		desc         string
+		renames      []RenameRule
+		reqHeader    http.Header
+		expReqHeader http.Header
+	}{
+		{
+			desc: "Should rename request headers before forwarding",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Legacy-Token",
+					NewHeaderName:      "X-Token",
+				},
+				{
+					ExistingHeaderName: "X-Client-*",
+					NewHeaderName:      "X-Device-$1",
+				},
+			},
+			reqHeader: map[string][]string{
+				"X-Legacy-Token": {"secret"},
+				"X-Client-Os":    {"linux"},
+			},
+			expReqHeader: map[string][]string{
+				"X-Token":        {"secret"},
+				"X-Device-Os":    {"linux"},
+				"X-Legacy-Token": nil,
+				"X-Client-Os":    nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RequestRenameData: test.renames,
+			}
+
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range test.reqHeader {
+				for _, h := range v {
+					req.Header.Add(k, h)
+				}
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			for k, expected := range test.expReqHeader {
+				values := forwarded[k]
+
+				if !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestDirectionBoth(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{
+				ExistingHeaderName: "X-Auth-User",
+				NewHeaderName:      "X-User",
+				Direction:          DirectionBoth,
+			},
+			{
+				ExistingHeaderName: "X-Client",
+				NewHeaderName:      "X-Upstream-Client",
+				Direction:          DirectionRequest,
+			},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-User", req.Header.Get("X-User"))
+		rw.Header().Set("X-Client", "response")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Auth-User", "alice")
+	req.Header.Set("X-Client", "cli")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expReqHeader := map[string][]string{
+		"X-User":            {"alice"},
+		"X-Auth-User":       nil,
+		"X-Upstream-Client": {"cli"},
+	}
+	for k, expected := range expReqHeader {
+		if values := forwarded[k]; !testEq(values, expected) {
+			t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+
+	expRespHeader := map[string][]string{
+		"X-Auth-User":       {"alice"},
+		"X-User":            nil,
+		"X-Client":          {"response"},
+		"X-Upstream-Client": nil,
+	}
+	for k, expected := range expRespHeader {
+		if values := recorder.Result().Header[k]; !testEq(values, expected) {
+			t.Errorf("response %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
+
+func TestDirectionBothValues(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{
+				ExistingHeaderName: "X-Auth-User",
+				NewHeaderName:      "X-User-B64",
+				Direction:          DirectionBoth,
+				Encode:             "base64",
+			},
+			{
+				ExistingHeaderName: "X-Tenant",
+				NewHeaderName:      "X-Upstream-Tenant",
+				Direction:          DirectionBoth,
+				DefaultValue:       "default",
+			},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Set("X-User-B64", req.Header.Get("X-User-B64"))
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Auth-User", "hello")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	expReqHeader := map[string][]string{
+		"X-User-B64":        {"aGVsbG8="},
+		"X-Upstream-Tenant": {"default"},
+	}
+	for k, expected := range expReqHeader {
+		if values := forwarded[k]; !testEq(values, expected) {
+			t.Errorf("request %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+
+	// The values are renamed back as they are, and no default is added.
+	expRespHeader := map[string][]string{
+		"X-Auth-User": {"aGVsbG8="},
+		"X-Tenant":    nil,
+	}
+	for k, expected := range expRespHeader {
+		if values := recorder.Result().Header[k]; !testEq(values, expected) {
+			t.Errorf("response %s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		renames []RenameRule
+	}{
+		{
+			desc: "Should reject an empty name among new header names",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Request-Id",
+					NewHeaderNames:     []string{"Trace-Id", ""},
+				},
+			},
+		},
+		{
+			desc: "Should reject new header names in both directions",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Request-Id",
+					NewHeaderNames:     []string{"Trace-Id", "X-Correlation-Id"},
+					Direction:          DirectionBoth,
+				},
+			},
+		},
+		{
+			desc: "Should reject a target using fewer segments than the pattern has wildcards",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-*-Old-*",
+					NewHeaderName:      "X-$1-New",
+				},
+			},
+		},
+		{
+			desc: "Should reject a target referencing a missing segment",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Old-*",
+					NewHeaderName:      "X-New-$1-$2",
+				},
+			},
+		},
+		{
+			desc: "Should reject a target with more wildcards than the pattern",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Old-*",
+					NewHeaderName:      "X-New-*-*",
+				},
+			},
+		},
+		{
+			desc: "Should reject a target mixing wildcards and segment references",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-*-Old-*",
+					NewHeaderName:      "X-$1-New-*",
+				},
+			},
+		},
+		{
+			desc: "Should reject an invalid regex",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "^X-(",
+					NewHeaderName:      "X-New",
+					Regex:              true,
+				},
+			},
+		},
+		{
+			desc: "Should reject a target referencing a missing capture group",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "^X-Legacy-(.*)$",
+					NewHeaderName:      "X-New-$2",
+					Regex:              true,
+				},
+			},
+		},
+		{
+			desc: "Should reject a target referencing a missing named capture group",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "^X-Legacy-(?P<name>.*)$",
+					NewHeaderName:      "X-New-${other}",
+					Regex:              true,
+				},
+			},
+		},
+		{
+			desc: "Should reject a default value on a pattern rule",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Old-*",
+					NewHeaderName:      "X-New-*",
+					DefaultValue:       "none",
+				},
+			},
+		},
+		{
+			desc: "Should reject except on an exact rule",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Old",
+					NewHeaderName:      "X-New",
+					Except:             []string{"X-Old"},
+				},
+			},
+		},
+		{
+			desc: "Should reject an unknown conflict strategy",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					OnConflict:         "merge",
+				},
+			},
+		},
+		{
+			desc: "Should reject an unknown direction",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					Direction:          "inbound",
+				},
+			},
+		},
+		{
+			desc: "Should reject reversing wildcard rules",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Old-*",
+					NewHeaderName:      "X-New-$1",
+					Direction:          DirectionBoth,
+				},
+			},
+		},
+		{
+			desc: "Should reject invalid status codes",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					StatusCodes:        "200-abc",
+				},
+			},
+		},
+		{
+			desc: "Should reject an empty status code range",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					StatusCodes:        "299-200",
+				},
+			},
+		},
+		{
+			desc: "Should reject an invalid host regexp",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					HostRegexp:         "(",
+				},
+			},
+		},
+		{
+			desc: "Should reject an invalid name regexp",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					NameRegexp:         "(",
+				},
+			},
+		},
+		{
+			desc: "Should reject an invalid value replacement regexp",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "Foo",
+					NewHeaderName:      "Bar",
+					ValueReplacement:   &ValueReplacement{Regexp: "("},
+				},
+			},
+		},
+		{
+			desc: "Should reject renaming into a pseudo-header",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Status",
+					NewHeaderName:      ":status",
+				},
+			},
+		},
+		{
+			desc: "Should reject renaming into a framing header by default",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Upstream-Length",
+					NewHeaderName:      "content-length",
+				},
+			},
+		},
+		{
+			desc: "Should reject splitting and joining in one rule",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Foo",
+					NewHeaderName:      "X-Bar",
+					Split:              true,
+					Join:               true,
+				},
+			},
+		},
+		{
+			desc: "Should reject a value template calling a disallowed function",
+			renames: []RenameRule{
+				{
+					ExistingHeaderName: "X-Foo",
+					NewHeaderName:      "X-Bar",
+					ValueTemplate:      `{{ call .Value }}`,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: test.renames,
+			}
+
+			_, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestAllowFramingHeaders(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Upstream-Length", NewHeaderName: "Content-Length"},
+		},
+		AllowFramingHeaders: true,
+	}
+
+	_, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPatternFramingTargets(t *testing.T) {
+	tests := []struct {
+		desc         string
+		allowFraming bool
+		expected     http.Header
+	}{
+		{
+			desc:     "Should not rename into a framing header by default",
+			expected: http.Header{"X-Up-Content-Length": {"1"}, "X-Forwarded": {"a"}},
+		},
+		{
+			desc:         "Should rename into a framing header when allowed",
+			allowFraming: true,
+			expected:     http.Header{"Content-Length": {"1"}, "X-Forwarded": {"a"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "X-Up-*", NewHeaderName: "$1"},
+				},
+				AllowFramingHeaders: test.allowFraming,
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Up-Content-Length", "1")
+				rw.Header().Set("X-Up-X-Forwarded", "a")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for name, expected := range test.expected {
+				if values := recorder.Header()[name]; !testEq(values, expected) {
+					t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFrom(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Id", NewHeaderName: "X-Id"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := rw.(io.ReaderFrom); !ok {
+			t.Errorf("expected %T to implement io.ReaderFrom", rw)
+		}
+		rw.Header().Set("X-Legacy-Id", "42")
+		_, _ = io.Copy(rw, strings.NewReader("body"))
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if values := recorder.Result().Header["X-Id"]; !testEq(values, []string{"42"}) {
+		t.Errorf("expected the header to be renamed before the body, got %+v", recorder.Result().Header)
+	}
+	if body := recorder.Body.String(); body != "body" {
+		t.Errorf("expected body %q, got %q", "body", body)
+	}
+}
+
+func TestFlushBeforeWriteHeader(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Id", NewHeaderName: "X-Id"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("X-Legacy-Id", "42")
+		rw.(http.Flusher).Flush()
+		_, _ = rw.Write([]byte("data: 1\n\n"))
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !recorder.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if values := recorder.Result().Header["X-Id"]; !testEq(values, []string{"42"}) {
+		t.Errorf("expected the header to be renamed before the flush, got %+v", recorder.Result().Header)
+	}
+}
+
+func TestResponseController(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Id", NewHeaderName: "X-Id"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		controller := http.NewResponseController(rw)
+		if err := controller.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			t.Errorf("expected write deadlines to be supported, got %v", err)
+		}
+		if err := controller.EnableFullDuplex(); err != nil {
+			t.Errorf("expected full duplex to be supported, got %v", err)
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+// discardWriter is a ResponseWriter reusing one header map, so that
+// benchmarks only count the allocations of the plugin.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func BenchmarkServeHTTPAllocs(b *testing.B) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Id", NewHeaderName: "X-Id"},
+		},
+	}
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		b.Fatal(err)
+	}
+	rw := &discardWriter{header: make(http.Header)}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(rw, req)
+	}
+}
+
 func testEq(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
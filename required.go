@@ -0,0 +1,70 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequiredHeaders rejects requests and responses missing a header once all
+// changes are applied.
+type RequiredHeaders struct {
+	// Request lists the headers a request must carry to be forwarded.
+	// Requests missing one are answered with RequestStatus, 400 by default.
+	Request       []string `json:"request"`
+	RequestStatus int      `json:"requestStatus"`
+	// Response lists the headers a response must carry. Responses missing
+	// one are replaced with an empty ResponseStatus response, 502 by default.
+	Response       []string `json:"response"`
+	ResponseStatus int      `json:"responseStatus"`
+}
+
+// requiredHeaders is a RequiredHeaders with defaults applied.
+type requiredHeaders struct {
+	request        []string
+	requestStatus  int
+	response       []string
+	responseStatus int
+}
+
+// compileRequiredHeaders validates the required headers.
+func compileRequiredHeaders(required *RequiredHeaders) (requiredHeaders, error) {
+	if required == nil {
+		return requiredHeaders{}, nil
+	}
+
+	compiled := requiredHeaders{
+		request:        append([]string(nil), required.Request...),
+		requestStatus:  required.RequestStatus,
+		response:       append([]string(nil), required.Response...),
+		responseStatus: required.ResponseStatus,
+	}
+	if compiled.requestStatus == 0 {
+		compiled.requestStatus = http.StatusBadRequest
+	}
+	if compiled.responseStatus == 0 {
+		compiled.responseStatus = http.StatusBadGateway
+	}
+	for _, status := range []int{compiled.requestStatus, compiled.responseStatus} {
+		if status < 400 || status > 599 {
+			return requiredHeaders{}, fmt.Errorf("requireHeaders: status %d is not an error status", status)
+		}
+	}
+	for _, name := range append(compiled.request, compiled.response...) {
+		if name == "" {
+			return requiredHeaders{}, fmt.Errorf("requireHeaders: header name cannot be empty")
+		}
+	}
+	return compiled, nil
+}
+
+// missing returns the first of names absent from header, or "" if all are
+// present. Names are looked up as configured and in canonical form, as rules
+// may write either.
+func missing(header http.Header, names []string) string {
+	for _, name := range names {
+		if len(header[name]) == 0 && len(header.Values(name)) == 0 {
+			return name
+		}
+	}
+	return ""
+}
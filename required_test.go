@@ -0,0 +1,112 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireHeaders(t *testing.T) {
+	tests := []struct {
+		desc       string
+		required   *RequiredHeaders
+		reqHeader  http.Header
+		respHeader http.Header
+		expStatus  int
+		expCalled  bool
+	}{
+		{
+			desc:      "Should forward requests carrying the renamed header",
+			required:  &RequiredHeaders{Request: []string{"Authorization"}},
+			reqHeader: http.Header{"X-Auth": {"Bearer t"}},
+			expStatus: http.StatusOK,
+			expCalled: true,
+		},
+		{
+			desc:      "Should reject requests missing a header with 400",
+			required:  &RequiredHeaders{Request: []string{"Authorization"}},
+			reqHeader: http.Header{},
+			expStatus: http.StatusBadRequest,
+		},
+		{
+			desc:      "Should reject requests with the configured status",
+			required:  &RequiredHeaders{Request: []string{"authorization"}, RequestStatus: http.StatusUnauthorized},
+			reqHeader: http.Header{},
+			expStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "Should replace responses missing a header with 502",
+			required:   &RequiredHeaders{Response: []string{"X-Request-Id"}},
+			reqHeader:  http.Header{},
+			respHeader: http.Header{"X-Trace": {"abc"}},
+			expStatus:  http.StatusBadGateway,
+			expCalled:  true,
+		},
+		{
+			desc:       "Should keep responses carrying the renamed header",
+			required:   &RequiredHeaders{Response: []string{"X-Request-Id"}},
+			reqHeader:  http.Header{},
+			respHeader: http.Header{"X-Upstream-Id": {"abc"}},
+			expStatus:  http.StatusOK,
+			expCalled:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			called := false
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				called = true
+				for name, values := range test.respHeader {
+					rw.Header()[name] = values
+				}
+				rw.WriteHeader(http.StatusOK)
+				_, _ = rw.Write([]byte("body"))
+			}
+
+			config := &Config{
+				RenameData:        []RenameRule{{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Request-Id"}},
+				RequestRenameData: []RenameRule{{ExistingHeaderName: "X-Auth", NewHeaderName: "Authorization"}},
+				RequireHeaders:    test.required,
+			}
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = test.reqHeader
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expStatus {
+				t.Errorf("expected status %d, got %d", test.expStatus, recorder.Code)
+			}
+			if called != test.expCalled {
+				t.Errorf("expected next to be called: %t, got %t", test.expCalled, called)
+			}
+			if test.expStatus == http.StatusBadGateway && recorder.Body.Len() != 0 {
+				t.Errorf("expected the body to be discarded, got %q", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestCompileRequiredHeadersErrors(t *testing.T) {
+	tests := []struct {
+		desc     string
+		required *RequiredHeaders
+	}{
+		{desc: "Should reject success statuses", required: &RequiredHeaders{Request: []string{"X-Foo"}, RequestStatus: http.StatusOK}},
+		{desc: "Should reject empty names", required: &RequiredHeaders{Response: []string{""}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileRequiredHeaders(test.required); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,674 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RenamedHeader describes one header renamed by a rule.
+type RenamedHeader struct {
+	Rule string
+	From string
+	To   string
+}
+
+// compiledRule is a RenameRule prepared for matching at response time.
+type compiledRule struct {
+	RenameRule
+	// index is the position of the rule in its configuration, for logs.
+	index  int
+	glob   *globPattern
+	target *targetTemplate
+	regex  *regexp.Regexp
+	except []headerMatcher
+
+	statusCodes  statusCodes
+	contentTypes contentTypes
+	hostRegexp   *regexp.Regexp
+	nameRegexp   *regexp.Regexp
+	sourceRange  sourceRange
+	valueRegexp  *regexp.Regexp
+	activeFrom   time.Time
+	activeUntil  time.Time
+
+	// transforms rewrite the values of renamed headers, in order.
+	transforms []valueTransform
+
+	// strict fails on conflicts with different values rather than
+	// overwriting, see strictRules.
+	strict bool
+	// allowFraming lets a pattern rule rename into framing headers, see
+	// framingRules.
+	allowFraming bool
+}
+
+// CompiledRuleSet is a validated and compiled list of rules. It is immutable,
+// so one set can be shared by any number of handlers and goroutines.
+type CompiledRuleSet struct {
+	rules        []*compiledRule
+	requestRules []*compiledRule
+}
+
+// CompileRules validates and compiles rules once, for use by several handlers
+// through WithRuleSet.
+func CompileRules(renames []RenameRule) (*CompiledRuleSet, error) {
+	rules, err := compileRules(renames)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &CompiledRuleSet{}
+	for _, rule := range rules {
+		switch rule.Direction {
+		case DirectionRequest:
+			set.requestRules = append(set.requestRules, rule)
+		case DirectionBoth:
+			set.requestRules = append(set.requestRules, rule)
+			set.rules = append(set.rules, rule.reverse())
+		default:
+			set.rules = append(set.rules, rule)
+		}
+	}
+	// Clip the capacity so that handlers appending their own request rules
+	// never write into the shared array.
+	set.requestRules = set.requestRules[:len(set.requestRules):len(set.requestRules)]
+	return set, nil
+}
+
+// WithRuleSet makes the handler use a precompiled rule set instead of
+// compiling the rename rules of its configuration.
+func WithRuleSet(set *CompiledRuleSet) Option {
+	return func(r *renameHeaders) {
+		r.ruleSet = set
+	}
+}
+
+// compileRules validates and compiles rules in order. Disabled rules are
+// validated but left out.
+func compileRules(renames []RenameRule) ([]*compiledRule, error) {
+	rules := make([]*compiledRule, 0, len(renames))
+	for i, rename := range renames {
+		compiled, err := compileRename(rename)
+		if err != nil {
+			return nil, fmt.Errorf("rename rule %d: %w", i, err)
+		}
+		for _, rule := range compiled {
+			rule.index = i
+		}
+		if rename.enabled() {
+			rules = append(rules, compiled...)
+		}
+	}
+	return rules, nil
+}
+
+// compileRename validates one rename configuration and compiles it into one
+// rule per new header name.
+func compileRename(rename RenameRule) ([]*compiledRule, error) {
+	if rename.ExistingHeaderName == "" {
+		return nil, errors.New("existing header name cannot be empty")
+	}
+	if rename.NewHeaderName == "" && len(rename.NewHeaderNames) == 0 {
+		return nil, errors.New("new header name cannot be empty")
+	}
+
+	fanned, err := fanOut(rename)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*compiledRule, 0, len(fanned))
+	for _, rename := range fanned {
+		rule, err := compileRule(rename)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// fanOut splits a rule with several new header names into one rule per name.
+// All but the last keep the original header, so that each sees it; the last
+// keeps it only if the rule does, and is the only one to be terminal.
+func fanOut(rename RenameRule) ([]RenameRule, error) {
+	if len(rename.NewHeaderNames) == 0 {
+		return []RenameRule{rename}, nil
+	}
+	if rename.Direction == DirectionBoth {
+		return nil, errors.New("direction both is not supported with newHeaderNames")
+	}
+
+	names := rename.NewHeaderNames
+	if rename.NewHeaderName != "" {
+		names = append([]string{rename.NewHeaderName}, names...)
+	}
+	fanned := make([]RenameRule, len(names))
+	for i, name := range names {
+		if name == "" {
+			return nil, errors.New("new header names cannot be empty")
+		}
+		fanned[i] = rename
+		fanned[i].NewHeaderName = name
+		fanned[i].NewHeaderNames = nil
+		if i < len(names)-1 {
+			fanned[i].KeepOriginal = true
+			fanned[i].Terminal = false
+		}
+	}
+	return fanned, nil
+}
+
+// framingHeaders control how the response is delimited; overwriting them
+// through the header map can corrupt the response.
+var framingHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// compileRequestRules compiles the rules of RequestRenameData, rejecting the
+// options that only apply to response rules.
+func compileRequestRules(renames []RenameRule) ([]*compiledRule, error) {
+	for i, rename := range renames {
+		if err := checkRequestRename(rename); err != nil {
+			return nil, fmt.Errorf("request rename rule %d: %w", i, err)
+		}
+	}
+	rules, err := compileRules(renames)
+	if err != nil {
+		return nil, fmt.Errorf("request %w", err)
+	}
+	return rules, nil
+}
+
+// checkRequestRename rejects the options of a RequestRenameData rule that
+// only apply to response rules.
+func checkRequestRename(rename RenameRule) error {
+	switch {
+	case rename.Direction != "":
+		return errors.New("direction is only supported in renameData")
+	case rename.StatusCodes != "":
+		return errors.New("statusCodes only applies to responses")
+	case len(rename.ContentTypes) > 0:
+		return errors.New("contentTypes only applies to responses")
+	}
+	return nil
+}
+
+// forMiddleware returns the rules applying to the middleware named name,
+// rules itself when they all do.
+func forMiddleware(rules []*compiledRule, name string) []*compiledRule {
+	for i, rule := range rules {
+		if rule.nameRegexp == nil || rule.nameRegexp.MatchString(name) {
+			continue
+		}
+
+		kept := append([]*compiledRule(nil), rules[:i]...)
+		for _, rule := range rules[i+1:] {
+			if rule.nameRegexp == nil || rule.nameRegexp.MatchString(name) {
+				kept = append(kept, rule)
+			}
+		}
+		return kept
+	}
+	return rules
+}
+
+// framingRules returns rules with the pattern rules allowed to rename into
+// framing headers. Rules are copied, as the set may be shared.
+func framingRules(rules []*compiledRule) []*compiledRule {
+	allowed := make([]*compiledRule, len(rules))
+	for i, rule := range rules {
+		allowed[i] = rule
+		if rule.isPattern() {
+			copied := *rule
+			copied.allowFraming = true
+			allowed[i] = &copied
+		}
+	}
+	return allowed
+}
+
+// validateTargets rejects rules renaming into pseudo-headers, and into framing
+// headers unless allowFraming is set.
+func validateTargets(rules []*compiledRule, allowFraming bool) error {
+	for i, rule := range rules {
+		if err := rule.validateTarget(allowFraming); err != nil {
+			return fmt.Errorf("rename rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateTarget rejects a rule renaming into a pseudo-header, or into a
+// framing header unless allowFraming is set.
+func (c *compiledRule) validateTarget(allowFraming bool) error {
+	if strings.HasPrefix(c.NewHeaderName, ":") {
+		return fmt.Errorf("new header name %q is a pseudo-header", c.NewHeaderName)
+	}
+	if !allowFraming && framingHeaders[http.CanonicalHeaderKey(c.NewHeaderName)] {
+		return fmt.Errorf("new header name %q controls response framing, set allowFramingHeaders to rename into it", c.NewHeaderName)
+	}
+	return nil
+}
+
+// compileRule validates a rename configuration and prepares it for matching.
+func compileRule(rename RenameRule) (*compiledRule, error) {
+	rule := &compiledRule{RenameRule: rename}
+	switch rename.Direction {
+	case "", DirectionResponse, DirectionRequest:
+	case DirectionBoth:
+		if rename.Regex || isGlob(rename.ExistingHeaderName) {
+			return nil, errors.New("direction both is not supported for pattern rules")
+		}
+	default:
+		return nil, fmt.Errorf("unknown direction %q", rename.Direction)
+	}
+	if cond := rename.WhenHeaderValueEquals; cond != nil {
+		if cond.Name == "" {
+			return nil, errors.New("whenHeaderValueEquals: header name cannot be empty")
+		}
+		// Copy so that the caller cannot change the compiled rule.
+		copied := *cond
+		rule.WhenHeaderValueEquals = &copied
+	}
+	if rename.StatusCodes != "" {
+		if rename.Direction == DirectionRequest || rename.Direction == DirectionBoth {
+			return nil, errors.New("statusCodes only applies to responses")
+		}
+		codes, err := parseStatusCodes(rename.StatusCodes)
+		if err != nil {
+			return nil, err
+		}
+		rule.statusCodes = codes
+	}
+	if len(rename.ContentTypes) > 0 {
+		if rename.Direction == DirectionRequest || rename.Direction == DirectionBoth {
+			return nil, errors.New("contentTypes only applies to responses")
+		}
+		types, err := parseContentTypes(rename.ContentTypes)
+		if err != nil {
+			return nil, err
+		}
+		rule.contentTypes = types
+	}
+	if rename.HostRegexp != "" {
+		hostRegexp, err := regexp.Compile(rename.HostRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hostRegexp %q: %w", rename.HostRegexp, err)
+		}
+		rule.hostRegexp = hostRegexp
+	}
+	if rename.NameRegexp != "" {
+		nameRegexp, err := regexp.Compile(rename.NameRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nameRegexp %q: %w", rename.NameRegexp, err)
+		}
+		rule.nameRegexp = nameRegexp
+	}
+	if rename.ValueRegexp != "" {
+		valueRegexp, err := regexp.Compile(rename.ValueRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid valueRegexp %q: %w", rename.ValueRegexp, err)
+		}
+		rule.valueRegexp = valueRegexp
+	}
+	if rename.CanonicalizeNewName != nil {
+		// Copy so that the caller cannot change the compiled rule.
+		canonicalize := *rename.CanonicalizeNewName
+		rule.CanonicalizeNewName = &canonicalize
+	}
+	if err := rule.compileSourceRange(); err != nil {
+		return nil, err
+	}
+	if err := rule.compileSampling(); err != nil {
+		return nil, err
+	}
+	if err := rule.compileSchedule(); err != nil {
+		return nil, err
+	}
+	if rename.Enabled != nil {
+		// Copy so that the caller cannot change the compiled rule.
+		enabled := *rename.Enabled
+		rule.Enabled = &enabled
+	}
+	if err := rule.compileTransforms(); err != nil {
+		return nil, err
+	}
+	if len(rename.Except) > 0 {
+		if !rename.Regex && !isGlob(rename.ExistingHeaderName) {
+			return nil, errors.New("except only applies to wildcard and regex rules")
+		}
+		except, err := compileHeaderMatchers(rename.Except)
+		if err != nil {
+			return nil, fmt.Errorf("except: %w", err)
+		}
+		rule.except = except
+	}
+	// Copy so that the caller cannot change the compiled rule.
+	rule.Methods = append([]string(nil), rename.Methods...)
+	rule.Except = append([]string(nil), rename.Except...)
+	switch rename.OnConflict {
+	case "", ConflictOverwrite, ConflictAppend, ConflictSkip, ConflictFail:
+	default:
+		return nil, fmt.Errorf("unknown onConflict strategy %q", rename.OnConflict)
+	}
+	if rename.DefaultValue != "" && (rename.Regex || isGlob(rename.ExistingHeaderName)) {
+		return nil, errors.New("defaultValue is not supported for pattern rules")
+	}
+	if rename.Regex {
+		regex, err := compileRegex(rename.ExistingHeaderName, rename.NewHeaderName)
+		if err != nil {
+			return nil, err
+		}
+		rule.regex = regex
+		return rule, rule.validateTargetName()
+	}
+	if !isGlob(rename.ExistingHeaderName) {
+		return rule, rule.validateTargetName()
+	}
+
+	glob, err := compileGlob(rename.ExistingHeaderName)
+	if err != nil {
+		return nil, err
+	}
+	target, err := compileTarget(rename.NewHeaderName, glob.wildcards())
+	if err != nil {
+		return nil, err
+	}
+	rule.glob = glob
+	rule.target = target
+	return rule, rule.validateTargetName()
+}
+
+// isPattern reports whether the rule matches header names by pattern rather
+// than by exact name.
+func (c *compiledRule) isPattern() bool {
+	return c.glob != nil || c.regex != nil
+}
+
+// newName returns the name a header matched by a pattern rule is renamed to.
+// Headers exempted by Except do not match.
+func (c *compiledRule) newName(name string) (string, bool) {
+	for _, m := range c.except {
+		if m.matches(name) {
+			return "", false
+		}
+	}
+	var newName string
+	if c.regex != nil {
+		match := c.regex.FindStringSubmatchIndex(name)
+		if match == nil {
+			return "", false
+		}
+		newName = c.targetKey(string(c.regex.ExpandString(nil, c.NewHeaderName, name, match)))
+	} else {
+		captures, ok := c.glob.match(name)
+		if !ok {
+			return "", false
+		}
+		newName = c.targetKey(c.target.expand(captures))
+	}
+	if !c.allowedTarget(newName) {
+		return "", false
+	}
+	return newName, true
+}
+
+// allowedTarget reports whether a pattern rule may rename into name, which is
+// only known once expanded. As with literal targets, see validateTarget,
+// pseudo-headers are refused, and framing headers unless allowed.
+func (c *compiledRule) allowedTarget(name string) bool {
+	if strings.HasPrefix(name, ":") {
+		return false
+	}
+	return c.allowFraming || !framingHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// sourceKey returns the key the existing header of an exact rule is looked up
+// under: its canonical form unless the rule opted out of canonicalization.
+func (c *compiledRule) sourceKey() string {
+	if c.CanonicalizeNewName != nil && !*c.CanonicalizeNewName {
+		return c.ExistingHeaderName
+	}
+	return http.CanonicalHeaderKey(c.ExistingHeaderName)
+}
+
+// targetKey returns the key a header renamed to name is stored under: name
+// as configured unless the rule opted into canonicalization.
+func (c *compiledRule) targetKey(name string) string {
+	if c.CanonicalizeNewName != nil && *c.CanonicalizeNewName {
+		return http.CanonicalHeaderKey(name)
+	}
+	return name
+}
+
+// reverse returns the rule renaming the new header name back to the existing
+// one. The value transformations and default value only apply to the
+// forward rule, so the values are renamed back as they are.
+func (c *compiledRule) reverse() *compiledRule {
+	reversed := *c
+	reversed.ExistingHeaderName = c.NewHeaderName
+	reversed.NewHeaderName = c.ExistingHeaderName
+	reversed.transforms = nil
+	reversed.DefaultValue = ""
+	return &reversed
+}
+
+// matchesRequest reports whether the rule's request conditions hold for req.
+func (c *compiledRule) matchesRequest(req *http.Request) bool {
+	if c.WhenTLSOnly && req.TLS == nil {
+		return false
+	}
+	if c.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, c.PathPrefix) {
+		return false
+	}
+	if len(c.Methods) > 0 && !containsFold(c.Methods, req.Method) {
+		return false
+	}
+	if c.hostRegexp != nil && !c.hostRegexp.MatchString(requestHost(req)) {
+		return false
+	}
+	if c.sourceRange != nil && !c.sourceRange.contains(clientIP(req, c.SourceRangeDepth)) {
+		return false
+	}
+	if c.SamplePercent != nil && !c.sampled(req) {
+		return false
+	}
+	if c.scheduled() && !c.active(time.Now()) {
+		return false
+	}
+	return true
+}
+
+// requestHost returns the host of req without port.
+func requestHost(req *http.Request) string {
+	if host, _, err := net.SplitHostPort(req.Host); err == nil {
+		return host
+	}
+	return req.Host
+}
+
+// containsFold reports whether values contains value under case folding.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the rule's conditions hold for the given headers.
+// status is the response status code, 0 for requests.
+func (c *compiledRule) matches(header http.Header, status int) bool {
+	if c.statusCodes != nil && !c.statusCodes.contains(status) {
+		return false
+	}
+	if c.contentTypes != nil && !c.contentTypes.contains(header.Get("Content-Type")) {
+		return false
+	}
+	if cond := c.WhenHeaderValueEquals; cond != nil {
+		found := false
+		for _, value := range header.Values(cond.Name) {
+			if value == cond.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// label returns the name identifying the rule.
+func (c *compiledRule) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.ExistingHeaderName + "->" + c.NewHeaderName
+}
+
+// apply renames the headers matched by the rule. req is the request being
+// served, if any, for transformations that depend on it.
+func (c *compiledRule) apply(header http.Header, req *http.Request, status int) ([]RenamedHeader, error) {
+	if !c.matches(header, status) {
+		return nil, nil
+	}
+
+	if !c.isPattern() {
+		name := c.sourceKey()
+		newName := c.targetKey(c.NewHeaderName)
+		headerValues := header[name]
+		if len(headerValues) == 0 && c.DefaultValue != "" && len(existingValues(header, name, newName)) == 0 {
+			header[newName] = []string{c.DefaultValue}
+			return nil, nil
+		}
+		if len(headerValues) == 0 || !c.valueMatches(headerValues) {
+			return nil, nil
+		}
+
+		// Remove old header and add with new name
+		moved, err := c.move(header, req, name, newName, headerValues)
+		if !moved {
+			return nil, err
+		}
+		return []RenamedHeader{{Rule: c.label(), From: name, To: newName}}, nil
+	}
+
+	// Collect matches first so that renamed headers are not matched again.
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	renamed := make(map[string]string)
+	for _, name := range names {
+		newName, ok := c.newName(name)
+		if !ok || newName == name && len(c.transforms) == 0 || !c.valueMatches(header[name]) {
+			continue
+		}
+		renamed[name] = newName
+	}
+	var applied []RenamedHeader
+	var invalid error
+	for _, name := range names {
+		newName, ok := renamed[name]
+		if !ok {
+			continue
+		}
+		moved, err := c.move(header, req, name, newName, header[name])
+		var decode *decodeError
+		if errors.As(err, &decode) {
+			// The other matches are renamed still, the first value that
+			// cannot be decoded is reported once they are.
+			if invalid == nil {
+				invalid = err
+			}
+			continue
+		}
+		if err != nil {
+			return applied, err
+		}
+		if moved {
+			applied = append(applied, RenamedHeader{Rule: c.label(), From: name, To: newName})
+		}
+	}
+	return applied, invalid
+}
+
+// valueMatches reports whether one of the values satisfies the rule's value regexp.
+func (c *compiledRule) valueMatches(values []string) bool {
+	if c.valueRegexp == nil {
+		return true
+	}
+	for _, value := range values {
+		if c.valueRegexp.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// move sets the transformed values under newName, removing name unless the
+// original is kept. It reports whether the header was moved, which it is not
+// when the target exists and the rule skips or fails on conflicts.
+func (c *compiledRule) move(header http.Header, req *http.Request, name, newName string, values []string) (bool, error) {
+	if len(c.transforms) > 0 {
+		var err error
+		if values, err = c.transform(values, req); err != nil {
+			return false, err
+		}
+		if len(values) == 0 {
+			// Every value was dropped: remove the header rather than
+			// leaving it empty under the new name.
+			if !c.KeepOriginal {
+				delete(header, name)
+			}
+			return true, nil
+		}
+	} else if c.KeepOriginal {
+		// Copy so that both headers can be changed independently.
+		values = append([]string(nil), values...)
+	}
+
+	if existing := existingValues(header, name, newName); len(existing) > 0 {
+		switch c.OnConflict {
+		case ConflictSkip:
+			return false, nil
+		case ConflictFail:
+			return false, &ConflictError{Rule: c.label(), Header: newName}
+		case ConflictAppend:
+			values = append(append([]string(nil), existing...), values...)
+		default:
+			if c.strict && !equalValues(existing, values) {
+				return false, &ConflictError{Rule: c.label(), Header: newName}
+			}
+		}
+	}
+	if c.Dedupe {
+		values = dedupeValues(values)
+	}
+	if !c.KeepOriginal {
+		delete(header, name)
+	}
+	// Drop the canonical form of a literal target, so the values end up
+	// under a single key.
+	if canonical := http.CanonicalHeaderKey(newName); canonical != newName && canonical != name {
+		delete(header, canonical)
+	}
+	header[newName] = values
+	return true, nil
+}
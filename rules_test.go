@@ -0,0 +1,68 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestCompiledRuleSet(t *testing.T) {
+	renames := []RenameRule{
+		{
+			ExistingHeaderName:    "Foo",
+			NewHeaderName:         "Bar",
+			WhenHeaderValueEquals: &HeaderValue{Name: "X-Cache", Value: "MISS"},
+		},
+	}
+
+	set, err := CompileRules(renames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The compiled set must not observe later changes to the rules it was built from.
+	renames[0].NewHeaderName = "Changed"
+	renames[0].WhenHeaderValueEquals.Value = "HIT"
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Cache", "MISS")
+		rw.Header().Set("Foo", req.URL.Path)
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	var handlers []http.Handler
+	for i := 0; i < 3; i++ {
+		handler, err := NewWithOptions(context.Background(), http.HandlerFunc(next), CreateConfig(), "rewriteHeader", WithRuleSet(set))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handlers = append(handlers, handler)
+	}
+
+	var wg sync.WaitGroup
+	for _, handler := range handlers {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(handler http.Handler) {
+				defer wg.Done()
+
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/path", nil))
+
+				if values := recorder.Result().Header["Bar"]; !testEq(values, []string{"/path"}) {
+					t.Errorf("expected the header to be renamed, got %+v", recorder.Result().Header)
+				}
+			}(handler)
+		}
+	}
+	wg.Wait()
+}
+
+func TestCompileRulesErrors(t *testing.T) {
+	_, err := CompileRules([]RenameRule{{ExistingHeaderName: "Foo"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,61 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+)
+
+// compileSampling validates the sampling configuration of the rule.
+func (c *compiledRule) compileSampling() error {
+	if c.SamplePercent == nil {
+		if c.SampleKeyHeader != "" {
+			return errors.New("sampleKeyHeader requires samplePercent")
+		}
+		return nil
+	}
+
+	percent := *c.SamplePercent
+	if percent < 0 || percent > 100 {
+		return errors.New("samplePercent must be between 0 and 100")
+	}
+	// Copy so that the caller cannot change the compiled rule.
+	c.SamplePercent = &percent
+	return nil
+}
+
+// sampleKey is the context key of the random sampling bucket of a request.
+type sampleKey struct{}
+
+// withSample draws the random sampling bucket of req once, so that every rule
+// and both sides of the request are sampled alike.
+func withSample(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), sampleKey{}, rand.Float64()*100))
+}
+
+// sampled reports whether req falls within the sampled percentage of
+// requests. Requests carrying the key header are sampled by a hash of its
+// value, others by the bucket drawn for them.
+func (c *compiledRule) sampled(req *http.Request) bool {
+	percent := *c.SamplePercent
+	if percent >= 100 {
+		return true
+	}
+	if percent <= 0 {
+		return false
+	}
+
+	var bucket float64
+	if key := req.Header.Get(c.SampleKeyHeader); c.SampleKeyHeader != "" && key != "" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		bucket = float64(h.Sum64()%10000) / 100
+	} else if drawn, ok := req.Context().Value(sampleKey{}).(float64); ok {
+		bucket = drawn
+	} else {
+		bucket = rand.Float64() * 100
+	}
+	return bucket < percent
+}
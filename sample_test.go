@@ -0,0 +1,126 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSamplePercent(t *testing.T) {
+	tests := []struct {
+		desc     string
+		percent  float64
+		keyed    bool
+		min, max int
+	}{
+		{desc: "Should never apply at 0%", percent: 0, min: 0, max: 0},
+		{desc: "Should always apply at 100%", percent: 100, min: 1000, max: 1000},
+		{desc: "Should apply to about the given percentage at random", percent: 25, min: 150, max: 350},
+		{desc: "Should apply to about the given percentage by key", percent: 25, keyed: true, min: 150, max: 350},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			percent := test.percent
+			config := &Config{RequestRenameData: []RenameRule{
+				{ExistingHeaderName: "X-Old", NewHeaderName: "X-New", SamplePercent: &percent, SampleKeyHeader: "X-Request-Id"},
+			}}
+
+			renamed := 0
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				if req.Header.Get("X-New") != "" {
+					renamed++
+				}
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 1000; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-Old", "v")
+				if test.keyed {
+					req.Header.Set("X-Request-Id", fmt.Sprintf("req-%d", i))
+				}
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			if renamed < test.min || renamed > test.max {
+				t.Errorf("expected between %d and %d renames, got %d", test.min, test.max, renamed)
+			}
+		})
+	}
+}
+
+func TestSampleKeyIsStable(t *testing.T) {
+	percent := 50.0
+	rule, err := compileRule(RenameRule{ExistingHeaderName: "X-Old", NewHeaderName: "X-New", SamplePercent: &percent, SampleKeyHeader: "X-Request-Id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Id", fmt.Sprintf("req-%d", i))
+
+		first := rule.sampled(req)
+		for j := 0; j < 10; j++ {
+			if rule.sampled(req) != first {
+				t.Fatalf("expected request %d to be sampled alike every time", i)
+			}
+		}
+	}
+}
+
+func TestCompileSamplingErrors(t *testing.T) {
+	tooHigh := 101.0
+	tests := []struct {
+		desc string
+		rule RenameRule
+	}{
+		{desc: "Should reject percentages above 100", rule: RenameRule{ExistingHeaderName: "X-Old", NewHeaderName: "X-New", SamplePercent: &tooHigh}},
+		{desc: "Should reject a key header without percentage", rule: RenameRule{ExistingHeaderName: "X-Old", NewHeaderName: "X-New", SampleKeyHeader: "X-Request-Id"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileRule(test.rule); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestSampleOncePerRequest(t *testing.T) {
+	percent := 50.0
+	config := &Config{RenameData: []RenameRule{
+		{ExistingHeaderName: "X-Old", NewHeaderName: "X-New", Direction: DirectionBoth, SamplePercent: &percent},
+	}}
+
+	var forwarded bool
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Get("X-New") != ""
+		rw.Header().Set("X-New", "v")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 200; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Old", "v")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if renamedBack := recorder.Result().Header.Get("X-Old") != ""; renamedBack != forwarded {
+			t.Fatalf("expected both directions to be sampled alike, request renamed: %t, response renamed: %t", forwarded, renamedBack)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Policies for renamed headers with an invalid name or value.
+const (
+	// InvalidPass leaves headers with invalid values alone, the default.
+	// Headers with an invalid name are dropped.
+	InvalidPass = "pass"
+	// InvalidDrop removes invalid headers.
+	InvalidDrop = "drop"
+	// InvalidFail fails the request with 500 Internal Server Error.
+	InvalidFail = "fail"
+)
+
+// InvalidHeaderError reports a renamed header with an invalid name or value
+// under the InvalidFail policy.
+type InvalidHeaderError struct {
+	Rule   string
+	Header string
+}
+
+func (e *InvalidHeaderError) Error() string {
+	return fmt.Sprintf("rename rule %s: header %q has an invalid name or value", e.Rule, e.Header)
+}
+
+// isToken reports whether name is a token of RFC 7230, as field names,
+// cookie names and Forwarded parameter values are.
+func isToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenChar reports whether c may appear in a token.
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+	}
+}
+
+// isControl reports whether c is a control character other than tab, which
+// header values must not contain.
+func isControl(c byte) bool {
+	return c < ' ' && c != '\t' || c == 0x7f
+}
+
+// validValue reports whether value contains no control characters.
+func validValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if isControl(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// stripControls removes the control characters from value.
+func stripControls(value string) string {
+	if validValue(value) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if !isControl(value[i]) {
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// validateTargetName rejects targets that are not valid field names. Only
+// the literal parts of wildcard and regex targets are known beforehand; the
+// expanded names are checked by sanitizeRenamed.
+func (c *compiledRule) validateTargetName() error {
+	// Pseudo-headers are reported by validateTargets.
+	if strings.HasPrefix(c.NewHeaderName, ":") {
+		return nil
+	}
+	literals := []string{c.NewHeaderName}
+	switch {
+	case c.regex != nil:
+		literals = templateLiterals(c.NewHeaderName)
+	case c.target != nil:
+		literals = c.target.literals
+	}
+	for _, literal := range literals {
+		if literal != "" && !isToken(literal) {
+			return fmt.Errorf("new header name %q is not a valid header name", c.NewHeaderName)
+		}
+	}
+	return nil
+}
+
+// templateLiterals returns the text of a regexp replacement template around
+// its $name and ${name} references, read as regexp.Expand does.
+func templateLiterals(template string) []string {
+	var literals []string
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' {
+			b.WriteByte(template[i])
+			continue
+		}
+		rest := template[i+1:]
+		switch {
+		case strings.HasPrefix(rest, "$"):
+			b.WriteByte('$')
+			i++
+		case strings.HasPrefix(rest, "{") && strings.IndexByte(rest, '}') > 1:
+			literals = append(literals, b.String())
+			b.Reset()
+			i += strings.IndexByte(rest, '}') + 1
+		default:
+			n := 0
+			for n < len(rest) && isNameChar(rest[n]) {
+				n++
+			}
+			if n == 0 {
+				// Not a reference, the $ is copied.
+				b.WriteByte('$')
+				continue
+			}
+			literals = append(literals, b.String())
+			b.Reset()
+			i += n
+		}
+	}
+	return append(literals, b.String())
+}
+
+// isNameChar reports whether c may appear in the name of a template reference.
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// sanitizeRenamed strips control characters from the values of the renamed
+// headers if enabled, then applies the invalid header policy to them. A name
+// that is not valid is never passed on, it is dropped with InvalidPass.
+func (r *renameHeaders) sanitizeRenamed(header http.Header, renamed []RenamedHeader) error {
+	for _, rename := range renamed {
+		values, ok := header[rename.To]
+		if !ok {
+			continue
+		}
+		if r.sanitizeValues {
+			for i, value := range values {
+				values[i] = stripControls(value)
+			}
+		}
+		if validHeader(rename.To, values) || r.onInvalid == InvalidPass && isToken(rename.To) {
+			continue
+		}
+		if r.onInvalid == InvalidFail {
+			return &InvalidHeaderError{Rule: rename.Rule, Header: rename.To}
+		}
+		delete(header, rename.To)
+	}
+	return nil
+}
+
+// validHeader reports whether name and values are valid.
+func validHeader(name string, values []string) bool {
+	if !isToken(name) {
+		return false
+	}
+	for _, value := range values {
+		if !validValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// compileOnInvalid validates the invalid header policy.
+func compileOnInvalid(policy string) (string, error) {
+	switch policy {
+	case "":
+		return InvalidPass, nil
+	case InvalidPass, InvalidDrop, InvalidFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown onInvalid policy %q", policy)
+	}
+}
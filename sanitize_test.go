@@ -0,0 +1,110 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		desc      string
+		sanitize  bool
+		onInvalid string
+		expStatus int
+		expValues []string
+	}{
+		{desc: "Should pass invalid values by default", expStatus: http.StatusOK, expValues: []string{"a\x00b"}},
+		{desc: "Should strip control characters", sanitize: true, expStatus: http.StatusOK, expValues: []string{"ab"}},
+		{desc: "Should drop invalid headers", onInvalid: InvalidDrop, expStatus: http.StatusOK},
+		{desc: "Should fail on invalid headers", onInvalid: InvalidFail, expStatus: http.StatusInternalServerError},
+		{desc: "Should apply the policy after sanitizing", sanitize: true, onInvalid: InvalidFail, expStatus: http.StatusOK, expValues: []string{"ab"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{
+				RenameData:     []RenameRule{{ExistingHeaderName: "X-Upstream", NewHeaderName: "X-Downstream"}},
+				SanitizeValues: test.sanitize,
+				OnInvalid:      test.onInvalid,
+			}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["X-Upstream"] = []string{"a\x00b"}
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != test.expStatus {
+				t.Errorf("expected status %d, got %d", test.expStatus, recorder.Code)
+			}
+			if values := recorder.Header()["X-Downstream"]; !testEq(values, test.expValues) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expValues, values)
+			}
+		})
+	}
+}
+
+func TestSanitizeRegexTargets(t *testing.T) {
+	for _, onInvalid := range []string{"", InvalidDrop} {
+		t.Run("onInvalid "+onInvalid, func(t *testing.T) {
+			config := &Config{
+				RenameData: []RenameRule{{ExistingHeaderName: "^X-Old-(.*)$", NewHeaderName: "X-New-$1", Regex: true}},
+				OnInvalid:  onInvalid,
+			}
+
+			// The expanded name is only invalid once the backend's header
+			// name is captured.
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["X-Old-Foo Bar"] = []string{"foo"}
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if len(recorder.Header()) != 0 {
+				t.Errorf("expected the invalid header to be dropped, got %+v", recorder.Header())
+			}
+		})
+	}
+}
+
+func TestValidateTargetName(t *testing.T) {
+	tests := []struct {
+		desc    string
+		rule    RenameRule
+		wantErr bool
+	}{
+		{desc: "Should accept valid names", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar_1.v2"}},
+		{desc: "Should reject names with spaces", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X Bar"}, wantErr: true},
+		{desc: "Should reject names with CRLF", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar\r\nX-Evil: 1"}, wantErr: true},
+		{desc: "Should reject invalid wildcard targets", rule: RenameRule{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New:$1"}, wantErr: true},
+		{desc: "Should accept valid wildcard targets", rule: RenameRule{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New-$1"}},
+		{desc: "Should reject invalid regex targets", rule: RenameRule{ExistingHeaderName: "^X-Old-(.*)$", NewHeaderName: "X New $1", Regex: true}, wantErr: true},
+		{desc: "Should reject invalid text between regex references", rule: RenameRule{ExistingHeaderName: "^X-(?P<a>.*)-(.*)$", NewHeaderName: "X-${a}:$2", Regex: true}, wantErr: true},
+		{desc: "Should accept valid regex targets", rule: RenameRule{ExistingHeaderName: "^X-(?P<a>.*)-(.*)$", NewHeaderName: "X-${a}-$2$$", Regex: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := compileRule(test.rule)
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %t, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
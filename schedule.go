@@ -0,0 +1,48 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// enabled reports whether the rule is enabled, as rules are by default.
+func (r RenameRule) enabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// compileSchedule parses the activation window of the rule.
+func (c *compiledRule) compileSchedule() error {
+	var err error
+	if c.ActiveFrom != "" {
+		if c.activeFrom, err = time.Parse(time.RFC3339, c.ActiveFrom); err != nil {
+			return fmt.Errorf("invalid activeFrom %q: %w", c.ActiveFrom, err)
+		}
+	}
+	if c.ActiveUntil != "" {
+		if c.activeUntil, err = time.Parse(time.RFC3339, c.ActiveUntil); err != nil {
+			return fmt.Errorf("invalid activeUntil %q: %w", c.ActiveUntil, err)
+		}
+	}
+	if !c.activeFrom.IsZero() && !c.activeUntil.IsZero() && !c.activeFrom.Before(c.activeUntil) {
+		return errors.New("activeFrom must be before activeUntil")
+	}
+	return nil
+}
+
+// active reports whether now falls within the activation window of the
+// rule, which includes activeFrom and excludes activeUntil.
+func (c *compiledRule) active(now time.Time) bool {
+	if !c.activeFrom.IsZero() && now.Before(c.activeFrom) {
+		return false
+	}
+	if !c.activeUntil.IsZero() && !now.Before(c.activeUntil) {
+		return false
+	}
+	return true
+}
+
+// scheduled reports whether the rule has an activation window.
+func (c *compiledRule) scheduled() bool {
+	return !c.activeFrom.IsZero() || !c.activeUntil.IsZero()
+}
@@ -0,0 +1,105 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRuleSchedule(t *testing.T) {
+	disabled := false
+	enabled := true
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		desc      string
+		rule      RenameRule
+		expHeader http.Header
+	}{
+		{
+			desc: "Should apply enabled rules",
+			rule: RenameRule{Enabled: &enabled},
+			expHeader: http.Header{
+				"X-New": {"v"},
+			},
+		},
+		{
+			desc: "Should not apply disabled rules",
+			rule: RenameRule{Enabled: &disabled},
+			expHeader: http.Header{
+				"X-Old": {"v"},
+				"X-New": nil,
+			},
+		},
+		{
+			desc: "Should not apply rules before activeFrom",
+			rule: RenameRule{ActiveFrom: future},
+			expHeader: http.Header{
+				"X-Old": {"v"},
+				"X-New": nil,
+			},
+		},
+		{
+			desc: "Should not apply rules from activeUntil",
+			rule: RenameRule{ActiveUntil: past},
+			expHeader: http.Header{
+				"X-Old": {"v"},
+				"X-New": nil,
+			},
+		},
+		{
+			desc: "Should apply rules within their window",
+			rule: RenameRule{ActiveFrom: past, ActiveUntil: future},
+			expHeader: http.Header{
+				"X-New": {"v"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			rule := test.rule
+			rule.ExistingHeaderName = "X-Old"
+			rule.NewHeaderName = "X-New"
+			config := &Config{RenameData: []RenameRule{rule}}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Old", "v")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for k, expected := range test.expHeader {
+				if values := recorder.Header()[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestRuleScheduleErrors(t *testing.T) {
+	disabled := false
+	for _, rule := range []RenameRule{
+		{ActiveFrom: "tomorrow"},
+		{ActiveUntil: "2024-01-01"},
+		{ActiveFrom: "2024-02-01T00:00:00Z", ActiveUntil: "2024-01-01T00:00:00Z"},
+		{Enabled: &disabled, ActiveFrom: "tomorrow"},
+	} {
+		rule.ExistingHeaderName = "X-Old"
+		rule.NewHeaderName = "X-New"
+		if _, err := compileRules([]RenameRule{rule}); err == nil {
+			t.Errorf("expected an error for %+v", rule)
+		}
+	}
+}
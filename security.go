@@ -0,0 +1,54 @@
+package traefik_header_rename_plugin
+
+import "net/http"
+
+// SecurityHeadersOff disables one of the security headers.
+const SecurityHeadersOff = "off"
+
+// SecurityHeaders sets common security headers on responses that do not
+// carry them already. Each field overrides the default value of its header,
+// and SecurityHeadersOff leaves the header out.
+type SecurityHeaders struct {
+	// StrictTransportSecurity defaults to "max-age=31536000; includeSubDomains".
+	StrictTransportSecurity string `json:"strictTransportSecurity"`
+	// ContentTypeOptions defaults to "nosniff".
+	ContentTypeOptions string `json:"contentTypeOptions"`
+	// FrameOptions defaults to "DENY".
+	FrameOptions string `json:"frameOptions"`
+	// ReferrerPolicy defaults to "strict-origin-when-cross-origin".
+	ReferrerPolicy string `json:"referrerPolicy"`
+}
+
+// compileSecurityHeaders returns the headers to set, with defaults applied.
+func compileSecurityHeaders(s *SecurityHeaders) http.Header {
+	if s == nil {
+		return nil
+	}
+
+	header := make(http.Header)
+	for _, h := range []struct{ name, value, def string }{
+		{"Strict-Transport-Security", s.StrictTransportSecurity, "max-age=31536000; includeSubDomains"},
+		{"X-Content-Type-Options", s.ContentTypeOptions, "nosniff"},
+		{"X-Frame-Options", s.FrameOptions, "DENY"},
+		{"Referrer-Policy", s.ReferrerPolicy, "strict-origin-when-cross-origin"},
+	} {
+		switch h.value {
+		case SecurityHeadersOff:
+		case "":
+			header.Set(h.name, h.def)
+		default:
+			header.Set(h.name, h.value)
+		}
+	}
+	return header
+}
+
+// setSecurityHeaders sets the security headers header does not carry.
+func setSecurityHeaders(header, security http.Header) {
+	for name, values := range security {
+		if _, ok := header[name]; !ok {
+			// Copy, as later changes may rewrite values in place.
+			header[name] = append([]string(nil), values...)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		desc      string
+		security  *SecurityHeaders
+		expHeader http.Header
+	}{
+		{
+			desc:     "Should set the defaults",
+			security: &SecurityHeaders{},
+			expHeader: http.Header{
+				"Strict-Transport-Security": {"max-age=31536000; includeSubDomains"},
+				"X-Content-Type-Options":    {"nosniff"},
+				"X-Frame-Options":           {"SAMEORIGIN"},
+				"Referrer-Policy":           {"strict-origin-when-cross-origin"},
+			},
+		},
+		{
+			desc: "Should apply overrides",
+			security: &SecurityHeaders{
+				StrictTransportSecurity: "max-age=63072000; includeSubDomains; preload",
+				ReferrerPolicy:          SecurityHeadersOff,
+			},
+			expHeader: http.Header{
+				"Strict-Transport-Security": {"max-age=63072000; includeSubDomains; preload"},
+				"X-Content-Type-Options":    {"nosniff"},
+				"Referrer-Policy":           nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{SecurityHeaders: test.security}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for k, expected := range test.expHeader {
+				if values := recorder.Header()[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
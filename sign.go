@@ -0,0 +1,107 @@
+package traefik_header_rename_plugin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+)
+
+// HeaderSignature signs the values of a header with an HMAC into a companion
+// header, so that backends can verify the header was set by the middleware.
+type HeaderSignature struct {
+	Header string `json:"header"`
+	// SignatureHeader receives one signature per value, in order. It defaults
+	// to Header with a "-Signature" suffix. Signatures sent by the client are
+	// always removed.
+	SignatureHeader string `json:"signatureHeader"`
+	// Algorithm is sha256, the default, sha384 or sha512.
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"secret"`
+	// Encoding of the signature is hex, the default, or base64.
+	Encoding string `json:"encoding"`
+}
+
+// hmacAlgorithms are the hash functions signatures can use.
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// headerSignature is a HeaderSignature prepared for signing.
+type headerSignature struct {
+	header          string
+	signatureHeader string
+	hash            func() hash.Hash
+	secret          []byte
+	encode          func([]byte) string
+}
+
+// compileHeaderSignatures validates the configured signatures.
+func compileHeaderSignatures(signatures []HeaderSignature) ([]headerSignature, error) {
+	compiled := make([]headerSignature, 0, len(signatures))
+	for i, signature := range signatures {
+		if signature.Header == "" {
+			return nil, fmt.Errorf("signature %d: header cannot be empty", i)
+		}
+		if signature.Secret == "" {
+			return nil, fmt.Errorf("signature %d: secret cannot be empty", i)
+		}
+
+		algorithm := signature.Algorithm
+		if algorithm == "" {
+			algorithm = "sha256"
+		}
+		newHash, ok := hmacAlgorithms[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("signature %d: unsupported algorithm %q", i, signature.Algorithm)
+		}
+
+		var encode func([]byte) string
+		switch signature.Encoding {
+		case "", "hex":
+			encode = hex.EncodeToString
+		case "base64":
+			encode = base64.StdEncoding.EncodeToString
+		default:
+			return nil, fmt.Errorf("signature %d: unsupported encoding %q", i, signature.Encoding)
+		}
+
+		signatureHeader := signature.SignatureHeader
+		if signatureHeader == "" {
+			signatureHeader = signature.Header + "-Signature"
+		}
+		compiled = append(compiled, headerSignature{
+			header:          http.CanonicalHeaderKey(signature.Header),
+			signatureHeader: http.CanonicalHeaderKey(signatureHeader),
+			hash:            newHash,
+			secret:          []byte(signature.Secret),
+			encode:          encode,
+		})
+	}
+	return compiled, nil
+}
+
+// signHeaders replaces the signature headers by signatures of the current values.
+func signHeaders(header http.Header, signatures []headerSignature) {
+	for _, signature := range signatures {
+		delete(header, signature.signatureHeader)
+
+		values := header[signature.header]
+		if len(values) == 0 {
+			continue
+		}
+		signed := make([]string, len(values))
+		for i, value := range values {
+			mac := hmac.New(signature.hash, signature.secret)
+			mac.Write([]byte(value))
+			signed[i] = signature.encode(mac.Sum(nil))
+		}
+		header[signature.signatureHeader] = signed
+	}
+}
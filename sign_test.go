@@ -0,0 +1,92 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignHeaders(t *testing.T) {
+	config := &Config{
+		RequestRenameData: []RenameRule{
+			{ExistingHeaderName: "X-Auth-User", NewHeaderName: "X-User-Id"},
+		},
+		RequestSignHeaders: []HeaderSignature{
+			{Header: "x-user-id", Secret: "secret"},
+			{Header: "X-Tenant", SignatureHeader: "X-Tenant-Mac", Algorithm: "sha512", Encoding: "base64", Secret: "other"},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Auth-User", "42")
+	req.Header.Add("X-Tenant", "acme")
+	req.Header.Add("X-Tenant", "globex")
+	req.Header.Set("X-Tenant-Mac", "forged")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("42"))
+	expected := []string{hex.EncodeToString(mac.Sum(nil))}
+	if values := forwarded["X-User-Id-Signature"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+
+	expected = nil
+	for _, value := range []string{"acme", "globex"} {
+		mac := hmac.New(sha512.New, []byte("other"))
+		mac.Write([]byte(value))
+		expected = append(expected, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	}
+	if values := forwarded["X-Tenant-Mac"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+}
+
+func TestSignHeadersRemovesForgedSignatures(t *testing.T) {
+	signatures, err := compileHeaderSignatures([]HeaderSignature{{Header: "X-User-Id", Secret: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{"X-User-Id-Signature": {"forged"}}
+	signHeaders(header, signatures)
+	if _, ok := header["X-User-Id-Signature"]; ok {
+		t.Errorf("expected the signature of a missing header to be removed, got %+v", header)
+	}
+}
+
+func TestCompileHeaderSignaturesErrors(t *testing.T) {
+	tests := []struct {
+		desc       string
+		signatures []HeaderSignature
+	}{
+		{desc: "Should reject a missing secret", signatures: []HeaderSignature{{Header: "X-User-Id"}}},
+		{desc: "Should reject unknown algorithms", signatures: []HeaderSignature{{Header: "X-User-Id", Secret: "s", Algorithm: "md5"}}},
+		{desc: "Should reject unknown encodings", signatures: []HeaderSignature{{Header: "X-User-Id", Secret: "s", Encoding: "base32"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileHeaderSignatures(test.signatures); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// sourceRange is a parsed list of networks requests may come from.
+type sourceRange []*net.IPNet
+
+// parseSourceRange parses a list of CIDRs; plain addresses stand for a single
+// host.
+func parseSourceRange(values []string) (sourceRange, error) {
+	networks := make(sourceRange, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("sourceRange: invalid address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("sourceRange: %w", err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// contains reports whether ip, given as text, is in one of the networks.
+func (s sourceRange) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range s {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSourceRange validates the source range of the rule.
+func (c *compiledRule) compileSourceRange() error {
+	if len(c.SourceRange) == 0 {
+		if c.SourceRangeDepth != 0 {
+			return errors.New("sourceRangeDepth requires sourceRange")
+		}
+		return nil
+	}
+	if c.SourceRangeDepth < 0 {
+		return errors.New("sourceRangeDepth cannot be negative")
+	}
+
+	networks, err := parseSourceRange(c.SourceRange)
+	if err != nil {
+		return err
+	}
+	c.sourceRange = networks
+	// Copy so that the caller cannot change the compiled rule.
+	c.SourceRange = append([]string(nil), c.SourceRange...)
+	return nil
+}
+
+// clientIP returns the address of the client of req. With a depth of 0 it is
+// the remote address; otherwise it is the depth-th address of X-Forwarded-For
+// counting from the right, or "" when there are fewer addresses.
+func clientIP(req *http.Request, depth int) string {
+	if depth == 0 {
+		ip, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			return req.RemoteAddr
+		}
+		return ip
+	}
+
+	var addrs []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		addrs = append(addrs, strings.Split(value, ",")...)
+	}
+	if depth > len(addrs) {
+		return ""
+	}
+	return strings.TrimSpace(addrs[len(addrs)-depth])
+}
@@ -0,0 +1,89 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSourceRange(t *testing.T) {
+	tests := []struct {
+		desc         string
+		depth        int
+		remoteAddr   string
+		forwardedFor []string
+		expRenamed   bool
+	}{
+		{desc: "Should rename for clients in range", remoteAddr: "203.0.113.7:4711", expRenamed: true},
+		{desc: "Should not rename for clients out of range", remoteAddr: "10.0.0.5:4711"},
+		{desc: "Should match single addresses", remoteAddr: "[2001:db8::1]:4711", expRenamed: true},
+		{
+			desc:         "Should use X-Forwarded-For at the given depth",
+			depth:        2,
+			remoteAddr:   "10.0.0.1:4711",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7", "10.0.0.2"},
+			expRenamed:   true,
+		},
+		{
+			desc:         "Should not rename when X-Forwarded-For is too short",
+			depth:        3,
+			remoteAddr:   "203.0.113.7:4711",
+			forwardedFor: []string{"203.0.113.8"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{RenameData: []RenameRule{
+				{
+					ExistingHeaderName: "X-Internal-Id",
+					NewHeaderName:      "X-Id",
+					SourceRange:        []string{"203.0.113.0/24", "2001:db8::1"},
+					SourceRangeDepth:   test.depth,
+				},
+			}}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Internal-Id", "42")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header["X-Forwarded-For"] = test.forwardedFor
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			renamed := recorder.Header().Get("X-Id") != ""
+			if renamed != test.expRenamed {
+				t.Errorf("expected renamed: %t, got %t", test.expRenamed, renamed)
+			}
+		})
+	}
+}
+
+func TestCompileSourceRangeErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		rule RenameRule
+	}{
+		{desc: "Should reject invalid CIDRs", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", SourceRange: []string{"10.0.0.0/33"}}},
+		{desc: "Should reject invalid addresses", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", SourceRange: []string{"localhost"}}},
+		{desc: "Should reject a depth without range", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", SourceRangeDepth: 1}},
+		{desc: "Should reject negative depths", rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", SourceRange: []string{"10.0.0.0/8"}, SourceRangeDepth: -1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileRule(test.rule); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// statusRange is an inclusive range of status codes.
+type statusRange struct {
+	from, to int
+}
+
+// statusCodes is a parsed list of status codes and ranges.
+type statusCodes []statusRange
+
+// parseStatusCodes parses a list such as "200-299,404".
+func parseStatusCodes(value string) (statusCodes, error) {
+	var codes statusCodes
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+
+		from, to, isRange := strings.Cut(part, "-")
+		r, err := parseStatusRange(from, to, isRange)
+		if err != nil {
+			return nil, fmt.Errorf("statusCodes %q: %w", value, err)
+		}
+		codes = append(codes, r)
+	}
+	return codes, nil
+}
+
+func parseStatusRange(from, to string, isRange bool) (statusRange, error) {
+	start, err := parseStatusCode(from)
+	if err != nil {
+		return statusRange{}, err
+	}
+	if !isRange {
+		return statusRange{from: start, to: start}, nil
+	}
+
+	end, err := parseStatusCode(to)
+	if err != nil {
+		return statusRange{}, err
+	}
+	if end < start {
+		return statusRange{}, fmt.Errorf("range %s-%s is empty", from, to)
+	}
+	return statusRange{from: start, to: end}, nil
+}
+
+func parseStatusCode(value string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || code < 100 || code > 999 {
+		return 0, fmt.Errorf("invalid status code %q", value)
+	}
+	return code, nil
+}
+
+// contains reports whether status is one of the codes.
+func (s statusCodes) contains(status int) bool {
+	for _, r := range s {
+		if r.from <= status && status <= r.to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package traefik_header_rename_plugin
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SummaryTrailer is the trailer summarizing the renames applied to a response.
+const SummaryTrailer = "X-Header-Rename-Summary"
+
+// writeSummaryTrailer sets the summary trailer declared in WriteHeader. It
+// must be called once the handler has returned.
+func (r *responseWriter) writeSummaryTrailer() {
+	if !r.headerWritten || r.hijacked || r.discard {
+		return
+	}
+	r.Header().Set(SummaryTrailer, summarizeRenames(r.renamed))
+}
+
+// summarizeRenames formats renames as "rule=count" pairs, in rule order.
+func summarizeRenames(renamed []RenamedHeader) string {
+	if len(renamed) == 0 {
+		return "none"
+	}
+
+	var rules []string
+	counts := make(map[string]int)
+	for _, rename := range renamed {
+		if counts[rename.Rule] == 0 {
+			rules = append(rules, rename.Rule)
+		}
+		counts[rename.Rule]++
+	}
+
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		parts = append(parts, rule+"="+strconv.Itoa(counts[rule]))
+	}
+	return strings.Join(parts, "; ")
+}
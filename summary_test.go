@@ -0,0 +1,52 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSummaryTrailer(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{Name: "foo", ExistingHeaderName: "Foo", NewHeaderName: "Bar"},
+			{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New-$1"},
+		},
+		EmitSummaryTrailer: true,
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Foo", "foo")
+		rw.Header().Set("X-Old-A", "a")
+		rw.Header().Set("X-Old-B", "b")
+		_, _ = rw.Write([]byte("body"))
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "foo=1; X-Old-*->X-New-$1=2"
+	if summary := resp.Trailer.Get(SummaryTrailer); summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, summary)
+	}
+	if resp.Header.Get("Bar") != "foo" {
+		t.Errorf("expected the header to be renamed, got %+v", resp.Header)
+	}
+}
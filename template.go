@@ -0,0 +1,178 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// safeTemplateBuiltins are the text/template builtins templates may use.
+// Builtins such as call, which invokes arbitrary function values, are left out.
+var safeTemplateBuiltins = map[string]bool{
+	"and":     true,
+	"or":      true,
+	"not":     true,
+	"eq":      true,
+	"ne":      true,
+	"lt":      true,
+	"le":      true,
+	"gt":      true,
+	"ge":      true,
+	"len":     true,
+	"index":   true,
+	"print":   true,
+	"printf":  true,
+	"println": true,
+}
+
+// parseSafeTemplate parses a configured template, only allowing the safe
+// builtins and the given functions. Nested template definitions and
+// invocations are rejected, so that a template cannot reach anything but its
+// own text and data.
+func parseSafeTemplate(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, fmt.Errorf("template %q: nested template definitions are not allowed", name)
+	}
+	if tmpl.Tree == nil {
+		return tmpl, nil
+	}
+
+	if err := checkTemplateNode(tmpl.Tree.Root, funcs); err != nil {
+		return nil, fmt.Errorf("template %q: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// checkTemplateNode walks a template parse tree and rejects disallowed constructs.
+func checkTemplateNode(node parse.Node, funcs template.FuncMap) error {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkTemplateNode(child, funcs); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkTemplateNode(n.Pipe, funcs)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkTemplateNode(cmd, funcs); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkTemplateNode(arg, funcs); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkTemplateNode(n.Node, funcs)
+	case *parse.IfNode:
+		return checkBranchNode(&n.BranchNode, funcs)
+	case *parse.RangeNode:
+		return checkBranchNode(&n.BranchNode, funcs)
+	case *parse.WithNode:
+		return checkBranchNode(&n.BranchNode, funcs)
+	case *parse.TemplateNode:
+		return fmt.Errorf("invoking template %q is not allowed", n.Name)
+	case *parse.IdentifierNode:
+		if _, ok := funcs[n.Ident]; !ok && !safeTemplateBuiltins[n.Ident] {
+			return fmt.Errorf("function %q is not allowed", n.Ident)
+		}
+	}
+	return nil
+}
+
+func checkBranchNode(n *parse.BranchNode, funcs template.FuncMap) error {
+	if err := checkTemplateNode(n.Pipe, funcs); err != nil {
+		return err
+	}
+	if err := checkTemplateNode(n.List, funcs); err != nil {
+		return err
+	}
+	return checkTemplateNode(n.ElseList, funcs)
+}
+
+// templateData is what value templates are executed against, once per value.
+type templateData struct {
+	// Value is the value of the header being renamed.
+	Value string
+	// Host, Method and Path describe the request, ClientIP is its remote
+	// address without the port.
+	Host     string
+	Method   string
+	Path     string
+	ClientIP string
+	// Middleware is the name of the middleware, as Traefik gives it.
+	Middleware string
+
+	req *http.Request
+}
+
+// middlewareKey is the context key of the middleware name.
+type middlewareKey struct{}
+
+// withMiddleware records the name of the middleware handling req for templates.
+func withMiddleware(req *http.Request, name string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), middlewareKey{}, name))
+}
+
+// Header returns the first value of the named request header.
+func (d *templateData) Header(name string) string {
+	if d.req == nil {
+		return ""
+	}
+	return d.req.Header.Get(name)
+}
+
+// newTemplateData describes req for a template; req may be nil.
+func newTemplateData(req *http.Request) templateData {
+	if req == nil {
+		return templateData{}
+	}
+
+	middleware, _ := req.Context().Value(middlewareKey{}).(string)
+	return templateData{
+		Host:       req.Host,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		ClientIP:   clientIP(req, 0),
+		Middleware: middleware,
+		req:        req,
+	}
+}
+
+// renderValues replaces each value by the output of tmpl.
+func renderValues(tmpl *template.Template) valueTransform {
+	return func(values []string, req *http.Request) ([]string, error) {
+		data := newTemplateData(req)
+
+		rendered := make([]string, len(values))
+		var b strings.Builder
+		for i, value := range values {
+			data.Value = value
+			b.Reset()
+			if err := tmpl.Execute(&b, &data); err != nil {
+				return nil, err
+			}
+			rendered[i] = b.String()
+		}
+		return rendered, nil
+	}
+}
@@ -0,0 +1,91 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestParseSafeTemplate(t *testing.T) {
+	funcs := template.FuncMap{
+		"lower": strings.ToLower,
+	}
+
+	tests := []struct {
+		desc  string
+		text  string
+		valid bool
+	}{
+		{desc: "Should accept plain text", text: "X-New", valid: true},
+		{desc: "Should accept fields and whitelisted builtins", text: `{{ if eq .Host "a" }}{{ printf "%s-%s" .Host .Path }}{{ end }}`, valid: true},
+		{desc: "Should accept configured functions", text: `{{ lower .Host }}`, valid: true},
+		{desc: "Should reject unknown functions", text: `{{ exec .Host }}`},
+		{desc: "Should reject the call builtin", text: `{{ call .Func }}`},
+		{desc: "Should reject disallowed builtins inside branches", text: `{{ with .Host }}{{ call $.Func }}{{ end }}`},
+		{desc: "Should reject nested definitions", text: `{{ define "x" }}x{{ end }}{{ template "x" }}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := parseSafeTemplate("test", test.text, funcs)
+			if test.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestValueTemplate(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{
+				ExistingHeaderName: "X-Upstream-Context",
+				NewHeaderName:      "X-Request-Context",
+				ValueTemplate:      `{{ .Host }}{{ .Path }} {{ .Method }} {{ .ClientIP }} {{ .Header "X-Request-Id" }} {{ .Middleware }} {{ .Value }}`,
+			},
+		},
+		RequestRenameData: []RenameRule{
+			{
+				ExistingHeaderName: "X-Request-Id",
+				NewHeaderName:      "X-Trace-Id",
+				KeepOriginal:       true,
+				ValueTemplate:      `trace-{{ .Value }}`,
+			},
+		},
+	}
+
+	var forwarded http.Header
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		rw.Header().Add("X-Upstream-Context", "a")
+		rw.Header().Add("X-Upstream-Context", "b")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Request-Id", "42")
+	handler.ServeHTTP(recorder, req)
+
+	expected := []string{"trace-42"}
+	if values := forwarded["X-Trace-Id"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+
+	expected = []string{"example.com/api POST 192.0.2.1 42 rewriteHeader a", "example.com/api POST 192.0.2.1 42 rewriteHeader b"}
+	if values := recorder.Result().Header["X-Request-Context"]; !testEq(values, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+	}
+}
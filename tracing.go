@@ -0,0 +1,51 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+)
+
+// Span attribute keys set on the request span.
+const (
+	SpanAttributeRenameCount = "header_rename.count"
+	SpanAttributeRenameRules = "header_rename.rules"
+)
+
+// Span is the part of a tracing span the plugin writes to. It is kept minimal
+// so that embedders can adapt an OpenTelemetry span without this plugin
+// depending on the OpenTelemetry API.
+type Span interface {
+	SetAttribute(key string, value interface{})
+}
+
+// WithSpanFromContext makes the handler record rename counts and the names of
+// the applied rules on the span returned by fn for the request context. fn may
+// return nil when the request is not traced.
+func WithSpanFromContext(fn func(context.Context) Span) Option {
+	return func(r *renameHeaders) {
+		r.spanFromContext = fn
+	}
+}
+
+// recordSpan sets the attributes of the renames applied to the request and
+// to its response on the request span, if any.
+func (r *renameHeaders) recordSpan(ctx context.Context, request, response []RenamedHeader) {
+	if r.spanFromContext == nil {
+		return
+	}
+	span := r.spanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	renamed := append(append([]RenamedHeader(nil), request...), response...)
+	var rules []string
+	seen := make(map[string]bool)
+	for _, rename := range renamed {
+		if !seen[rename.Rule] {
+			seen[rename.Rule] = true
+			rules = append(rules, rename.Rule)
+		}
+	}
+	span.SetAttribute(SpanAttributeRenameCount, len(renamed))
+	span.SetAttribute(SpanAttributeRenameRules, rules)
+}
@@ -0,0 +1,133 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type spanKey struct{}
+
+type fakeSpan struct {
+	attributes map[string]interface{}
+}
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func TestSpanAttributes(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{Name: "foo", ExistingHeaderName: "Foo", NewHeaderName: "Bar"},
+			{ExistingHeaderName: "X-Old-*", NewHeaderName: "X-New-$1"},
+			{ExistingHeaderName: "Missing", NewHeaderName: "Other"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Foo", "foo")
+		rw.Header().Set("X-Old-A", "a")
+		rw.Header().Set("X-Old-B", "b")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	spanFromContext := func(ctx context.Context) Span {
+		span, _ := ctx.Value(spanKey{}).(Span)
+		return span
+	}
+
+	handler, err := NewWithOptions(context.Background(), http.HandlerFunc(next), config, "rewriteHeader", WithSpanFromContext(spanFromContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should record renames on the request span", func(t *testing.T) {
+		span := &fakeSpan{attributes: make(map[string]interface{})}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), spanKey{}, span))
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if count := span.attributes[SpanAttributeRenameCount]; count != 3 {
+			t.Errorf("expected 3 renames, got %v", count)
+		}
+		rules, _ := span.attributes[SpanAttributeRenameRules].([]string)
+		if expected := []string{"foo", "X-Old-*->X-New-$1"}; !testEq(rules, expected) {
+			t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, rules)
+		}
+	})
+
+	t.Run("Should ignore untraced requests", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if recorder.Result().Header.Get("Bar") != "foo" {
+			t.Error("expected the header to be renamed")
+		}
+	})
+}
+
+func TestSpanAttributesRequestRules(t *testing.T) {
+	config := &Config{
+		RequestRenameData: []RenameRule{
+			{Name: "client", ExistingHeaderName: "X-Client-Id", NewHeaderName: "X-Upstream-Id"},
+		},
+		RenameData: []RenameRule{
+			{Name: "foo", ExistingHeaderName: "Foo", NewHeaderName: "Bar"},
+		},
+	}
+
+	spanFromContext := func(ctx context.Context) Span {
+		span, _ := ctx.Value(spanKey{}).(Span)
+		return span
+	}
+
+	tests := []struct {
+		desc     string
+		next     http.HandlerFunc
+		count    int
+		expected []string
+	}{
+		{
+			desc: "Should record request and response renames",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Foo", "foo")
+				rw.WriteHeader(http.StatusOK)
+			},
+			count:    2,
+			expected: []string{"client", "foo"},
+		},
+		{
+			desc:     "Should record request renames when the handler writes nothing",
+			next:     func(rw http.ResponseWriter, req *http.Request) {},
+			count:    1,
+			expected: []string{"client"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			handler, err := NewWithOptions(context.Background(), test.next, config, "rewriteHeader", WithSpanFromContext(spanFromContext))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			span := &fakeSpan{attributes: make(map[string]interface{})}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Client-Id", "42")
+			req = req.WithContext(context.WithValue(req.Context(), spanKey{}, span))
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if count := span.attributes[SpanAttributeRenameCount]; count != test.count {
+				t.Errorf("expected %d renames, got %v", test.count, count)
+			}
+			rules, _ := span.attributes[SpanAttributeRenameRules].([]string)
+			if !testEq(rules, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, rules)
+			}
+		})
+	}
+}
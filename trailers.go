@@ -0,0 +1,109 @@
+package traefik_header_rename_plugin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// trailerRules returns the rules that also apply to trailers.
+func trailerRules(rules []*compiledRule) []*compiledRule {
+	var trailers []*compiledRule
+	for _, rule := range rules {
+		if rule.Trailers {
+			trailers = append(trailers, rule)
+		}
+	}
+	return trailers
+}
+
+// renameTrailerDeclarations records the trailers declared in the Trailer
+// header and renames the declarations, so that they announce the renamed
+// trailers. It is called before the header is written.
+func (r *responseWriter) renameTrailerDeclarations(header http.Header) {
+	declarations := header["Trailer"]
+	if len(declarations) == 0 {
+		return
+	}
+
+	renamed := make([]string, 0, len(declarations))
+	for _, declaration := range declarations {
+		names := strings.Split(declaration, ",")
+		for i, name := range names {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			r.trailers = append(r.trailers, name)
+			for _, rule := range r.indexes.trailerRules.rules {
+				if !rule.matchesRequest(r.req) {
+					continue
+				}
+				if newName, ok := rule.renameName(name); ok {
+					name = newName
+				}
+			}
+			if r.grpc {
+				if newName, ok := r.handler.grpc.renameName(name); ok {
+					name = newName
+				}
+			}
+			names[i] = name
+		}
+		renamed = append(renamed, strings.Join(names, ", "))
+	}
+	header["Trailer"] = renamed
+}
+
+// renameName returns the name a header is renamed to by the rule, ignoring
+// any condition on the header values.
+func (c *compiledRule) renameName(name string) (string, bool) {
+	if c.isPattern() {
+		return c.newName(name)
+	}
+	if name != c.sourceKey() && name != c.ExistingHeaderName {
+		return "", false
+	}
+	return c.targetKey(c.NewHeaderName), true
+}
+
+// renameTrailers applies the trailer rules, and the gRPC options of gRPC
+// calls, to the trailers set by the handler.
+// It must be called once the handler has returned. Renamed trailers are set
+// with http.TrailerPrefix, which sends them whether declared or not.
+func (r *responseWriter) renameTrailers() {
+	if (r.indexes.trailerRules.empty() && !r.grpc) || !r.headerWritten || r.hijacked || r.discard {
+		return
+	}
+
+	header := r.Header()
+	trailers := make(http.Header)
+	for _, name := range r.trailers {
+		if values, ok := header[name]; ok {
+			trailers[name] = values
+			delete(header, name)
+		}
+	}
+	for key, values := range header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			trailers[strings.TrimPrefix(key, http.TrailerPrefix)] = values
+			delete(header, key)
+		}
+	}
+	if len(trailers) == 0 {
+		return
+	}
+
+	renamed, err := r.indexes.trailerRules.apply(trailers, r.req, r.status)
+	r.renamed = append(r.renamed, renamed...)
+	if err != nil {
+		// The status has been sent already, so the trailers are dropped instead.
+		return
+	}
+	if r.grpc {
+		r.handler.grpc.applyMetadata(trailers)
+		r.handler.grpc.applyStatus(trailers)
+	}
+	for name, values := range trailers {
+		header[http.TrailerPrefix+name] = values
+	}
+}
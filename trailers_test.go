@@ -0,0 +1,62 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenameTrailers(t *testing.T) {
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-Legacy-Checksum", NewHeaderName: "X-Checksum", Trailers: true},
+			{ExistingHeaderName: "X-Legacy-*", NewHeaderName: "X-Renamed-$1", Trailers: true},
+			{ExistingHeaderName: "X-Status", NewHeaderName: "X-Grpc-Status"},
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Trailer", "X-Legacy-Checksum, X-Status")
+		rw.Header().Set("X-Legacy-Header", "header")
+		_, _ = rw.Write([]byte("body"))
+		rw.Header().Set("X-Legacy-Checksum", "abc")
+		rw.Header().Set("X-Status", "0")
+		rw.Header().Set(http.TrailerPrefix+"X-Legacy-Undeclared", "undeclared")
+	}
+
+	handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"X-Checksum":           {"abc"},
+		"X-Renamed-Undeclared": {"undeclared"},
+		"X-Status":             {"0"},
+		"X-Legacy-Checksum":    nil,
+		"X-Grpc-Status":        nil,
+	}
+	for k, expected := range expected {
+		if values := resp.Trailer[k]; !testEq(values, expected) {
+			t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+		}
+	}
+	if resp.Header.Get("X-Renamed-Header") != "header" {
+		t.Errorf("expected the header to be renamed, got %+v", resp.Header)
+	}
+}
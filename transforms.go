@@ -0,0 +1,211 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// valueTransform rewrites the values of a renamed header. It returns a new
+// slice and never modifies the values it is given. req is the request being
+// served, nil when there is none.
+type valueTransform func(values []string, req *http.Request) ([]string, error)
+
+// TransformStep is one operation of a rule's Steps. Exactly one of its
+// fields must be set, apart from JoinSeparator which goes with Join.
+type TransformStep struct {
+	Split         bool              `json:"split"`
+	Join          bool              `json:"join"`
+	JoinSeparator string            `json:"joinSeparator"`
+	Decode        string            `json:"decode"`
+	Encode        string            `json:"encode"`
+	Map           *ValueMap         `json:"map"`
+	Replace       *ValueReplacement `json:"replace"`
+	Template      string            `json:"template"`
+	Dedupe        bool              `json:"dedupe"`
+}
+
+// operations returns the number of operations the step sets.
+func (s *TransformStep) operations() int {
+	n := 0
+	for _, set := range []bool{
+		s.Split, s.Join, s.Decode != "", s.Encode != "",
+		s.Map != nil, s.Replace != nil, s.Template != "", s.Dedupe,
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// compileTransforms prepares the value transformations configured on the
+// rule, either as Steps or as value options applied in a fixed order.
+func (c *compiledRule) compileTransforms() error {
+	if len(c.Steps) == 0 {
+		steps, err := c.implicitSteps()
+		if err != nil {
+			return err
+		}
+		for _, step := range steps {
+			transform, err := c.compileStep(step)
+			if err != nil {
+				return err
+			}
+			c.transforms = append(c.transforms, transform)
+		}
+		return nil
+	}
+
+	if c.Split || c.Join || c.JoinSeparator != "" || c.Decode != "" || c.Encode != "" ||
+		c.ValueMap != nil || c.ValueReplacement != nil || c.ValueTemplate != "" {
+		return errors.New("steps cannot be combined with split, join, decode, encode, valueMap, valueReplacement or valueTemplate")
+	}
+	for i, step := range c.Steps {
+		if n := step.operations(); n != 1 {
+			return fmt.Errorf("step %d: expected one operation, got %d", i, n)
+		}
+		transform, err := c.compileStep(step)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		c.transforms = append(c.transforms, transform)
+	}
+	// Copy so that the caller cannot change the compiled rule.
+	c.Steps = append([]TransformStep(nil), c.Steps...)
+	return nil
+}
+
+// implicitSteps returns the steps equivalent to the value options of the
+// rule: split first, so that the other steps see single values, then
+// decode, map, replace, template, join and finally encode.
+func (c *compiledRule) implicitSteps() ([]TransformStep, error) {
+	if c.Split && c.Join {
+		return nil, errors.New("split and join are mutually exclusive")
+	}
+
+	var steps []TransformStep
+	if c.Split {
+		steps = append(steps, TransformStep{Split: true})
+	}
+	if c.Decode != "" {
+		steps = append(steps, TransformStep{Decode: c.Decode})
+	}
+	if c.ValueMap != nil {
+		steps = append(steps, TransformStep{Map: c.ValueMap})
+	}
+	if c.ValueReplacement != nil {
+		// Copy so that the caller cannot change the compiled rule.
+		copied := *c.ValueReplacement
+		c.ValueReplacement = &copied
+		steps = append(steps, TransformStep{Replace: c.ValueReplacement})
+	}
+	if c.ValueTemplate != "" {
+		steps = append(steps, TransformStep{Template: c.ValueTemplate})
+	}
+	if c.Join || c.JoinSeparator != "" {
+		steps = append(steps, TransformStep{Join: c.Join, JoinSeparator: c.JoinSeparator})
+	}
+	if c.Encode != "" {
+		steps = append(steps, TransformStep{Encode: c.Encode})
+	}
+	return steps, nil
+}
+
+// compileStep prepares the operation of one step.
+func (c *compiledRule) compileStep(step TransformStep) (valueTransform, error) {
+	switch {
+	case step.JoinSeparator != "" && !step.Join:
+		return nil, errors.New("joinSeparator requires join")
+	case step.Split:
+		return splitValues, nil
+	case step.Join:
+		separator := step.JoinSeparator
+		if separator == "" {
+			separator = ", "
+		}
+		return joinValues(separator), nil
+	case step.Decode != "":
+		encoding, err := lookupEncoding(step.Decode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decode: %w", err)
+		}
+		return decodeValues(encoding), nil
+	case step.Encode != "":
+		encoding, err := lookupEncoding(step.Encode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encode: %w", err)
+		}
+		return encodeValues(encoding), nil
+	case step.Map != nil:
+		transform, err := compileValueMap(step.Map)
+		if err != nil {
+			return nil, fmt.Errorf("invalid valueMap: %w", err)
+		}
+		return transform, nil
+	case step.Replace != nil:
+		regex, err := regexp.Compile(step.Replace.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid valueReplacement regexp %q: %w", step.Replace.Regexp, err)
+		}
+		return replaceValues(regex, step.Replace.Replacement), nil
+	case step.Template != "":
+		tmpl, err := parseSafeTemplate(c.label(), step.Template, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid valueTemplate: %w", err)
+		}
+		return renderValues(tmpl), nil
+	default:
+		return dedupeStep, nil
+	}
+}
+
+// transform runs the values through the rule's transformations.
+func (c *compiledRule) transform(values []string, req *http.Request) ([]string, error) {
+	for _, t := range c.transforms {
+		var err error
+		if values, err = t(values, req); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", c.label(), err)
+		}
+	}
+	return values, nil
+}
+
+// splitValues turns comma-separated lists into one value per element. Commas
+// in quoted strings do not separate elements.
+func splitValues(values []string, _ *http.Request) ([]string, error) {
+	split := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			if element = strings.TrimSpace(element); element != "" {
+				split = append(split, element)
+			}
+		}
+	}
+	return split, nil
+}
+
+// dedupeStep drops repeated identical values.
+func dedupeStep(values []string, _ *http.Request) ([]string, error) {
+	return append([]string(nil), dedupeValues(values)...), nil
+}
+
+// joinValues folds values into a single one.
+func joinValues(separator string) valueTransform {
+	return func(values []string, _ *http.Request) ([]string, error) {
+		return []string{strings.Join(values, separator)}, nil
+	}
+}
+
+// replaceValues replaces the matches of regex in each value.
+func replaceValues(regex *regexp.Regexp, replacement string) valueTransform {
+	return func(values []string, _ *http.Request) ([]string, error) {
+		replaced := make([]string, len(values))
+		for i, value := range values {
+			replaced[i] = regex.ReplaceAllString(value, replacement)
+		}
+		return replaced, nil
+	}
+}
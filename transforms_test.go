@@ -0,0 +1,105 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		desc     string
+		steps    []TransformStep
+		values   []string
+		expected []string
+	}{
+		{
+			desc: "Should apply the steps in order",
+			steps: []TransformStep{
+				{Split: true},
+				{Replace: &ValueReplacement{Regexp: `^dc-`, Replacement: ""}},
+				{Dedupe: true},
+				{Join: true, JoinSeparator: ";"},
+				{Encode: "base64"},
+			},
+			values:   []string{"dc-eu, eu", "dc-us"},
+			expected: []string{"ZXU7dXM="},
+		},
+		{
+			desc: "Should allow repeating operations",
+			steps: []TransformStep{
+				{Decode: "base64"},
+				{Decode: "base64"},
+				{Template: "<{{ .Value }}>"},
+			},
+			values:   []string{"WVdKaklEMD0="},
+			expected: []string{"<abc =>"},
+		},
+		{
+			desc: "Should map values after replacing them",
+			steps: []TransformStep{
+				{Replace: &ValueReplacement{Regexp: `-\d+$`, Replacement: ""}},
+				{Map: &ValueMap{Values: map[string]string{"dc-ams": "eu-west"}, OnUnmapped: UnmappedDrop}},
+			},
+			values:   []string{"dc-ams-1", "dc-nyc-2"},
+			expected: []string{"eu-west"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["X-Source"] = test.values
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			config := &Config{RenameData: []RenameRule{
+				{ExistingHeaderName: "X-Source", NewHeaderName: "X-Target", Steps: test.steps},
+			}}
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if values := recorder.Header()["X-Target"]; !testEq(values, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+			}
+		})
+	}
+}
+
+func TestStepsErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		rule RenameRule
+	}{
+		{
+			desc: "Should reject steps with several operations",
+			rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", Steps: []TransformStep{{Split: true, Dedupe: true}}},
+		},
+		{
+			desc: "Should reject empty steps",
+			rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", Steps: []TransformStep{{}}},
+		},
+		{
+			desc: "Should reject steps combined with value options",
+			rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", Split: true, Steps: []TransformStep{{Dedupe: true}}},
+		},
+		{
+			desc: "Should reject invalid steps",
+			rule: RenameRule{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", Steps: []TransformStep{{Encode: "rot13"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileRule(test.rule); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package traefik_header_rename_plugin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxUpgradeHeadSize bounds the response head buffered on a hijacked
+// connection. Longer heads are passed through unchanged.
+const maxUpgradeHeadSize = 64 << 10
+
+// upgradeHead intercepts the response head a handler writes to a hijacked
+// connection, such as the 101 Switching Protocols written by a reverse proxy,
+// and applies the same changes as WriteHeader before passing it on.
+// Everything after the head is passed through unchanged.
+type upgradeHead struct {
+	rw *responseWriter
+
+	// mu guards the fields below, written to through both the connection
+	// and the buffered writer returned on hijack.
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	done bool
+	err  error
+}
+
+// writeTo buffers p until the head is complete and writes the transformed
+// head and anything following it to dst.
+func (h *upgradeHead) writeTo(dst io.Writer, p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return 0, h.err
+	}
+	if h.done {
+		return dst.Write(p)
+	}
+
+	h.buf.Write(p)
+	if !mayBeResponseHead(h.buf.Bytes()) {
+		// Not a response head, e.g. data of an upgraded protocol.
+		return len(p), h.flush(dst, h.buf.Bytes())
+	}
+	end := bytes.Index(h.buf.Bytes(), []byte("\r\n\r\n"))
+	if end < 0 {
+		if h.buf.Len() > maxUpgradeHeadSize {
+			return len(p), h.flush(dst, h.buf.Bytes())
+		}
+		return len(p), nil
+	}
+
+	head, err := h.transform(h.buf.Bytes()[:end+4])
+	if err != nil {
+		// Nothing has been sent, so the handler closes the connection.
+		h.err = err
+		return 0, err
+	}
+	return len(p), h.flush(dst, append(head, h.buf.Bytes()[end+4:]...))
+}
+
+// mayBeResponseHead reports whether b is, or starts, an HTTP response head.
+func mayBeResponseHead(b []byte) bool {
+	prefix := []byte("HTTP/")
+	if len(b) < len(prefix) {
+		return bytes.HasPrefix(prefix, b)
+	}
+	return bytes.HasPrefix(b, prefix)
+}
+
+// flush writes out the buffered data and stops intercepting.
+func (h *upgradeHead) flush(dst io.Writer, data []byte) error {
+	h.done = true
+	h.buf = bytes.Buffer{}
+	_, err := dst.Write(data)
+	return err
+}
+
+// transform applies the response rules and edits to the headers of a
+// response head. Failures that would change the status, such as a missing
+// required header, are returned instead.
+func (h *upgradeHead) transform(head []byte) ([]byte, error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(head)))
+	statusLine, err := reader.ReadLine()
+	if err != nil {
+		return head, nil
+	}
+	fields := strings.SplitN(statusLine, " ", 3)
+	if len(fields) < 2 {
+		return head, nil
+	}
+	status, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return head, nil
+	}
+	mimeHeader, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return head, nil
+	}
+
+	header := http.Header(mimeHeader)
+	var restore func()
+	if h.rw.handler.failOpen {
+		restore = snapshotHeader(header)
+	}
+	err = h.rw.handler.guard("response", restore, func() error {
+		return h.rw.transform(header, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.rw.edit(header, status)
+	if name := missing(header, h.rw.handler.required.response); name != "" {
+		return nil, fmt.Errorf("required response header %s is missing", name)
+	}
+	h.rw.handler.recordSpan(h.rw.req.Context(), h.rw.requestRenamed, h.rw.renamed)
+
+	var b bytes.Buffer
+	b.WriteString(statusLine)
+	b.WriteString("\r\n")
+	if err := header.Write(&b); err != nil {
+		return nil, err
+	}
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
+
+// upgradeConn is a hijacked connection whose writes go through upgradeHead.
+type upgradeConn struct {
+	net.Conn
+	head *upgradeHead
+}
+
+func (c *upgradeConn) Write(p []byte) (int, error) {
+	return c.head.writeTo(c.Conn, p)
+}
+
+// upgradeWriter writes through upgradeHead to the buffered writer returned
+// on hijack, flushing it so that nothing stays buffered twice.
+type upgradeWriter struct {
+	w    *bufio.Writer
+	head *upgradeHead
+}
+
+func (w *upgradeWriter) Write(p []byte) (int, error) {
+	n, err := w.head.writeTo(w.w, p)
+	if err != nil {
+		return n, err
+	}
+	return n, w.w.Flush()
+}
+
+// interceptUpgrade wraps a hijacked connection so that a response head
+// written to it, rather than through WriteHeader, is transformed too.
+func (r *responseWriter) interceptUpgrade(conn net.Conn, brw *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter) {
+	head := &upgradeHead{rw: r}
+	writer := bufio.NewWriter(&upgradeWriter{w: brw.Writer, head: head})
+	return &upgradeConn{Conn: conn, head: head}, bufio.NewReadWriter(brw.Reader, writer)
+}
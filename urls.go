@@ -0,0 +1,133 @@
+package traefik_header_rename_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// URLRewrite replaces the beginning of absolute URLs in response headers,
+// such as backend URLs in redirects.
+type URLRewrite struct {
+	// Headers lists the headers to rewrite, Location, Content-Location and
+	// Link by default. Link values may hold several URLs in angle brackets.
+	Headers []string `json:"headers"`
+	// From is an absolute URL, such as "http://internal-svc:8080", that URLs
+	// must start with to be rewritten. Scheme and host match
+	// case-insensitively, and a path only matches whole segments.
+	From string `json:"from"`
+	// To replaces From, such as "https://example.com".
+	To string `json:"to"`
+}
+
+// urlRewrite is a URLRewrite prepared for use.
+type urlRewrite struct {
+	// origin is the scheme and host of From, path the rest of it.
+	origin string
+	path   string
+	to     string
+}
+
+// urlRewrites holds the rewrites applying to each header, in order.
+type urlRewrites map[string][]urlRewrite
+
+// defaultURLHeaders are the headers URLRewrite applies to by default.
+var defaultURLHeaders = []string{"Location", "Content-Location", "Link"}
+
+// compileURLRewrites validates the configured URL rewrites.
+func compileURLRewrites(rewrites []URLRewrite) (urlRewrites, error) {
+	compiled := make(urlRewrites)
+	for i, rewrite := range rewrites {
+		from, err := url.Parse(rewrite.From)
+		if err != nil {
+			return nil, fmt.Errorf("url rewrite %d: from: %w", i, err)
+		}
+		if from.Scheme == "" || from.Host == "" || from.RawQuery != "" || from.Fragment != "" {
+			return nil, fmt.Errorf("url rewrite %d: from %q must be an absolute URL without query or fragment", i, rewrite.From)
+		}
+		if rewrite.To == "" || strings.ContainsAny(rewrite.To, "\r\n<>") {
+			return nil, fmt.Errorf("url rewrite %d: invalid to %q", i, rewrite.To)
+		}
+		if _, err := url.Parse(rewrite.To); err != nil {
+			return nil, fmt.Errorf("url rewrite %d: to: %w", i, err)
+		}
+
+		headers := rewrite.Headers
+		if len(headers) == 0 {
+			headers = defaultURLHeaders
+		}
+		r := urlRewrite{
+			origin: from.Scheme + "://" + from.Host,
+			path:   from.EscapedPath(),
+			to:     rewrite.To,
+		}
+		for _, name := range headers {
+			if name == "" {
+				return nil, fmt.Errorf("url rewrite %d: header name cannot be empty", i)
+			}
+			name = http.CanonicalHeaderKey(name)
+			compiled[name] = append(compiled[name], r)
+		}
+	}
+	return compiled, nil
+}
+
+// rewrite replaces the From prefix of u, reporting whether it matched.
+func (r urlRewrite) rewrite(u string) (string, bool) {
+	if !hasPrefixFold(u, r.origin) || !strings.HasPrefix(u[len(r.origin):], r.path) {
+		return u, false
+	}
+	rest := u[len(r.origin)+len(r.path):]
+	if rest != "" && !strings.HasSuffix(r.path, "/") && !strings.ContainsRune("/?#", rune(rest[0])) {
+		// "http://svc:8080" must not match "http://svc:80801".
+		return u, false
+	}
+	return r.to + rest, true
+}
+
+// apply rewrites the URLs of the response headers. The first matching
+// rewrite applies to each URL.
+func (u urlRewrites) apply(header http.Header) {
+	for name, rewrites := range u {
+		values := header[name]
+		for i, value := range values {
+			if name == "Link" {
+				values[i] = rewriteLinkURLs(value, rewrites)
+			} else {
+				values[i] = rewriteURL(strings.TrimSpace(value), rewrites)
+			}
+		}
+	}
+}
+
+// rewriteURL applies the first matching rewrite to u.
+func rewriteURL(u string, rewrites []urlRewrite) string {
+	for _, rewrite := range rewrites {
+		if rewritten, ok := rewrite.rewrite(u); ok {
+			return rewritten
+		}
+	}
+	return u
+}
+
+// rewriteLinkURLs rewrites each URL in angle brackets of a Link value.
+func rewriteLinkURLs(value string, rewrites []urlRewrite) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(value[:start+1])
+		b.WriteString(rewriteURL(value[start+1:end], rewrites))
+		value = value[end:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
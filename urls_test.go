@@ -0,0 +1,106 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewriteURLs(t *testing.T) {
+	tests := []struct {
+		desc      string
+		rewrites  []URLRewrite
+		header    http.Header
+		expHeader http.Header
+	}{
+		{
+			desc:     "Should rewrite the origin of a redirect",
+			rewrites: []URLRewrite{{From: "http://internal-svc:8080", To: "https://example.com"}},
+			header: http.Header{
+				"Location":         {"HTTP://Internal-Svc:8080/login?next=/"},
+				"Content-Location": {"http://internal-svc:8080"},
+			},
+			expHeader: http.Header{
+				"Location":         {"https://example.com/login?next=/"},
+				"Content-Location": {"https://example.com"},
+			},
+		},
+		{
+			desc:     "Should only match whole path segments",
+			rewrites: []URLRewrite{{From: "http://svc/api", To: "https://example.com/v1"}},
+			header: http.Header{
+				"Location":         {"http://svc/api/users"},
+				"Content-Location": {"http://svc/apis"},
+			},
+			expHeader: http.Header{
+				"Location":         {"https://example.com/v1/users"},
+				"Content-Location": {"http://svc/apis"},
+			},
+		},
+		{
+			desc:     "Should rewrite every URL of Link",
+			rewrites: []URLRewrite{{From: "http://svc:8080", To: "https://example.com"}},
+			header: http.Header{
+				"Link": {`<http://svc:8080/p/2>; rel="next", <https://cdn.example.com/a.css>; rel=preload`},
+			},
+			expHeader: http.Header{
+				"Link": {`<https://example.com/p/2>; rel="next", <https://cdn.example.com/a.css>; rel=preload`},
+			},
+		},
+		{
+			desc: "Should apply the first matching rewrite to the configured headers",
+			rewrites: []URLRewrite{
+				{Headers: []string{"x-callback"}, From: "http://svc:8080/hooks", To: "https://hooks.example.com"},
+				{Headers: []string{"X-Callback"}, From: "http://svc:8080", To: "https://example.com"},
+			},
+			header: http.Header{
+				"X-Callback": {"http://svc:8080/hooks/1", "http://svc:8080/other"},
+				"Location":   {"http://svc:8080/"},
+			},
+			expHeader: http.Header{
+				"X-Callback": {"https://hooks.example.com/1", "https://example.com/other"},
+				"Location":   {"http://svc:8080/"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := &Config{RewriteURLs: test.rewrites}
+
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				for k, v := range test.header {
+					rw.Header()[k] = v
+				}
+				rw.WriteHeader(http.StatusFound)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for k, expected := range test.expHeader {
+				if values := recorder.Header()[k]; !testEq(values, expected) {
+					t.Errorf("%s: Slice arent equals: expect: %+v, result: %+v", k, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteURLsValidation(t *testing.T) {
+	for _, rewrite := range []URLRewrite{
+		{From: "/relative", To: "https://example.com"},
+		{From: "http://svc?x=1", To: "https://example.com"},
+		{From: "http://svc", To: ""},
+	} {
+		if _, err := compileURLRewrites([]URLRewrite{rewrite}); err == nil {
+			t.Errorf("expected an error for %+v", rewrite)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Severities of validation issues.
+const (
+	// SeverityError marks problems New fails on.
+	SeverityError = "error"
+	// SeverityWarning marks configurations that are valid but probably unintended.
+	SeverityWarning = "warning"
+)
+
+// ValidationIssue describes one problem found by Validate.
+type ValidationIssue struct {
+	Severity string
+	// Field locates the problem, such as "renameData[2]". It is empty for
+	// problems that are not tied to one rule.
+	Field   string
+	Message string
+}
+
+func (i ValidationIssue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+}
+
+// Validate reports all problems of the configuration at once, e.g. to lint
+// configurations before deploying them: the errors of each rule New would
+// fail on, then warnings about duplicate existing header names, rename cycles
+// such as A to B and B to A, renames into hop-by-hop headers and the findings
+// of Analyze. Rules are numbered as in the configuration once Renames and
+// ExtendsFile are merged. Options other than rules only report their first
+// error. Rules of RulesFile and RulesURL are not loaded.
+func (c *Config) Validate() []ValidationIssue {
+	var issues []ValidationIssue
+	resolved, err := c.resolve()
+	if err != nil {
+		issues = append(issues, ValidationIssue{Severity: SeverityError, Message: err.Error()})
+		resolved = c
+	}
+
+	issues = append(issues, resolved.validateRules("renameData", resolved.RenameData, false)...)
+	issues = append(issues, resolved.validateRules("requestRenameData", resolved.RequestRenameData, true)...)
+	names := make([]string, 0, len(resolved.Variants))
+	for name := range resolved.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		variant := resolved.Variants[name]
+		issues = append(issues, resolved.validateRules(fmt.Sprintf("variants[%s].renameData", name), variant.RenameData, false)...)
+		issues = append(issues, resolved.validateRules(fmt.Sprintf("variants[%s].requestRenameData", name), variant.RequestRenameData, true)...)
+	}
+	hosts := make([]string, 0, len(resolved.HostRules))
+	for host := range resolved.HostRules {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		issues = append(issues, resolved.validateRules(fmt.Sprintf("hostRules[%s]", host), resolved.HostRules[host], false)...)
+	}
+	for _, warning := range resolved.analyze() {
+		issues = append(issues, ValidationIssue{
+			Severity: SeverityWarning,
+			Field:    fmt.Sprintf("%s[%d]", warning.Field, warning.Rule),
+			Message:  warning.Message,
+		})
+	}
+
+	if resolved.isEmpty() {
+		issues = append(issues, ValidationIssue{Severity: SeverityError, Message: errNoRules.Error()})
+	}
+	if _, err := newRuleSource(resolved); err != nil {
+		issues = append(issues, ValidationIssue{Severity: SeverityError, Message: err.Error()})
+	}
+	// The other options are checked by New, with an empty rule set so that it
+	// neither stops at a rule error nor loads a rule source.
+	options := *resolved
+	options.RenameData, options.RequestRenameData = nil, nil
+	options.Variants, options.HostRules = nil, nil
+	options.RulesFile, options.RulesURL, options.RulesRefreshInterval = "", "", ""
+	// Nor does it log metrics in the background.
+	if _, err := compileMetrics("", options.MetricsLogInterval); err != nil {
+		issues = append(issues, ValidationIssue{Severity: SeverityError, Message: err.Error()})
+	}
+	options.MetricsLogInterval = ""
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	if _, err := NewWithOptions(context.Background(), noop, &options, "validate", WithRuleSet(&CompiledRuleSet{})); err != nil {
+		issues = append(issues, ValidationIssue{Severity: SeverityError, Message: err.Error()})
+	}
+	return issues
+}
+
+// validateRules reports the problems of one list of rules, whose issues are
+// located under field.
+func (c *Config) validateRules(field string, renames []RenameRule, request bool) []ValidationIssue {
+	var issues []ValidationIssue
+	report := func(i int, severity, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{
+			Severity: severity,
+			Field:    fmt.Sprintf("%s[%d]", field, i),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// sources maps the direction and existing header name of exact rules to
+	// the first rule renaming them, renamesTo maps existing header names to
+	// new names, and edges lists the rules adding to renamesTo.
+	sources := make(map[string]int)
+	renamesTo := make(map[string][]string)
+	var edges []int
+	for i, rename := range renames {
+		if request {
+			if err := checkRequestRename(rename); err != nil {
+				report(i, SeverityError, "%v", err)
+				continue
+			}
+		}
+		rules, err := compileRename(rename)
+		if err != nil {
+			report(i, SeverityError, "%v", err)
+			continue
+		}
+
+		for _, rule := range rules {
+			if err := rule.validateTarget(c.AllowFramingHeaders); err != nil {
+				report(i, SeverityError, "%v", err)
+			} else if !rule.isPattern() && isHopByHop(rule.NewHeaderName) {
+				report(i, SeverityWarning, "new header name %q is a hop-by-hop header, which is not forwarded", rule.NewHeaderName)
+			}
+		}
+		if !rename.enabled() || rules[0].isPattern() {
+			continue
+		}
+
+		source := http.CanonicalHeaderKey(rename.ExistingHeaderName)
+		key := rename.Direction + " " + source
+		if first, ok := sources[key]; ok {
+			report(i, SeverityWarning, "existing header %q is already renamed by %s[%d]", rename.ExistingHeaderName, field, first)
+		} else {
+			sources[key] = i
+		}
+		for _, rule := range rules {
+			if target := http.CanonicalHeaderKey(rule.NewHeaderName); target != source {
+				renamesTo[source] = append(renamesTo[source], target)
+				edges = append(edges, i)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, i := range edges {
+		source := http.CanonicalHeaderKey(renames[i].ExistingHeaderName)
+		cycle := findCycle(renamesTo, source)
+		if cycle == nil {
+			continue
+		}
+		members := append([]string(nil), cycle[1:]...)
+		sort.Strings(members)
+		if key := strings.Join(members, ","); !seen[key] {
+			seen[key] = true
+			report(i, SeverityWarning, "rename cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+	return issues
+}
+
+// findCycle returns a path of renames from start back to start, nil if
+// there is none.
+func findCycle(renamesTo map[string][]string, start string) []string {
+	previous := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, next := range renamesTo[name] {
+			if next == start {
+				path := []string{start}
+				for n := name; n != start; n = previous[n] {
+					path = append([]string{n}, path...)
+				}
+				return append([]string{start}, path...)
+			}
+			if _, ok := previous[next]; !ok {
+				previous[next] = name
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// isHopByHop reports whether name is a hop-by-hop header.
+func isHopByHop(name string) bool {
+	return containsFold(hopByHopHeaders, name)
+}
@@ -0,0 +1,97 @@
+package traefik_header_rename_plugin
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		desc      string
+		config    Config
+		expIssues []string
+	}{
+		{
+			desc: "Should report nothing for a valid configuration",
+			config: Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"},
+				},
+			},
+		},
+		{
+			desc: "Should report every invalid rule",
+			config: Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "", NewHeaderName: "X-New"},
+					{ExistingHeaderName: "X-Ok", NewHeaderName: "X-Fine"},
+					{ExistingHeaderName: "X-Old", NewHeaderName: "Content-Length"},
+				},
+				RequestRenameData: []RenameRule{
+					{ExistingHeaderName: "X-Old", NewHeaderName: "X-New", StatusCodes: "200"},
+				},
+			},
+			expIssues: []string{
+				"error: renameData[0]: existing header name cannot be empty",
+				`error: renameData[2]: new header name "Content-Length" controls response framing, set allowFramingHeaders to rename into it`,
+				"error: requestRenameData[0]: statusCodes only applies to responses",
+			},
+		},
+		{
+			desc: "Should warn about duplicates, cycles and hop-by-hop targets",
+			config: Config{
+				RenameData: []RenameRule{
+					{ExistingHeaderName: "X-A", NewHeaderName: "X-B"},
+					{ExistingHeaderName: "x-b", NewHeaderName: "X-C"},
+					{ExistingHeaderName: "X-C", NewHeaderName: "X-A"},
+					{ExistingHeaderName: "X-A", NewHeaderName: "X-D"},
+					{ExistingHeaderName: "X-Te", NewHeaderName: "TE"},
+				},
+			},
+			expIssues: []string{
+				`warning: renameData[3]: existing header "X-A" is already renamed by renameData[0]`,
+				`warning: renameData[4]: new header name "TE" is a hop-by-hop header, which is not forwarded`,
+				"warning: renameData[0]: rename cycle X-A -> X-B -> X-C -> X-A",
+			},
+		},
+		{
+			desc: "Should report rules of variants and host rules, and other options",
+			config: Config{
+				Variants: map[string]RuleVariant{
+					"b": {RenameData: []RenameRule{{ExistingHeaderName: "X-Old"}}},
+				},
+				HostRules: map[string][]RenameRule{
+					"example.com": {{ExistingHeaderName: "X-Old", NewHeaderName: "X New"}},
+				},
+				ConflictStatus: 200,
+			},
+			expIssues: []string{
+				"error: variants[b].renameData[0]: new header name cannot be empty",
+				`error: hostRules[example.com][0]: new header name "X New" is not a valid header name`,
+				"error: conflictStatus 200 is not an error status",
+			},
+		},
+		{
+			desc: "Should locate the findings of Analyze",
+			config: Config{
+				RequestRenameData: []RenameRule{
+					{ExistingHeaderName: "Author*", NewHeaderName: "X-$1"},
+				},
+			},
+			expIssues: []string{
+				`warning: requestRenameData[0]: pattern "Author*" matches security-sensitive headers: Authorization`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var issues []string
+			for _, issue := range test.config.Validate() {
+				issues = append(issues, issue.String())
+			}
+			if !testEq(issues, test.expIssues) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expIssues, issues)
+			}
+		})
+	}
+}
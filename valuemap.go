@@ -0,0 +1,66 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Behaviors for values missing from a value map.
+const (
+	// UnmappedKeep passes unmapped values through unchanged, the default.
+	UnmappedKeep = "keep"
+	// UnmappedDrop removes unmapped values.
+	UnmappedDrop = "drop"
+)
+
+// ValueMap translates the values of a renamed header through a lookup table.
+type ValueMap struct {
+	Values map[string]string `json:"values"`
+	// Default replaces unmapped values. When empty, OnUnmapped applies.
+	Default string `json:"default"`
+	// OnUnmapped selects what happens to unmapped values, see the Unmapped*
+	// constants.
+	OnUnmapped string `json:"onUnmapped"`
+}
+
+// compileValueMap validates a value map and returns its transformation.
+func compileValueMap(m *ValueMap) (valueTransform, error) {
+	if len(m.Values) == 0 {
+		return nil, errors.New("values cannot be empty")
+	}
+	switch m.OnUnmapped {
+	case "", UnmappedKeep:
+	case UnmappedDrop:
+		if m.Default != "" {
+			return nil, errors.New("default and onUnmapped drop are mutually exclusive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown onUnmapped %q", m.OnUnmapped)
+	}
+
+	// Copy so that the caller cannot change the compiled rule.
+	values := make(map[string]string, len(m.Values))
+	for from, to := range m.Values {
+		values[from] = to
+	}
+	return mapValues(values, m.Default, m.OnUnmapped == UnmappedDrop), nil
+}
+
+// mapValues translates each value through the table.
+func mapValues(table map[string]string, def string, drop bool) valueTransform {
+	return func(values []string, _ *http.Request) ([]string, error) {
+		mapped := make([]string, 0, len(values))
+		for _, value := range values {
+			switch to, ok := table[value]; {
+			case ok:
+				mapped = append(mapped, to)
+			case def != "":
+				mapped = append(mapped, def)
+			case !drop:
+				mapped = append(mapped, value)
+			}
+		}
+		return mapped, nil
+	}
+}
@@ -0,0 +1,90 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValueMap(t *testing.T) {
+	regions := map[string]string{"dc-ams-1": "eu-west", "dc-fra-2": "eu-central"}
+
+	tests := []struct {
+		desc     string
+		valueMap *ValueMap
+		values   []string
+		expected []string
+	}{
+		{
+			desc:     "Should pass unmapped values through by default",
+			valueMap: &ValueMap{Values: regions},
+			values:   []string{"dc-ams-1", "dc-nyc-1"},
+			expected: []string{"eu-west", "dc-nyc-1"},
+		},
+		{
+			desc:     "Should replace unmapped values by the default",
+			valueMap: &ValueMap{Values: regions, Default: "other"},
+			values:   []string{"dc-fra-2", "dc-nyc-1"},
+			expected: []string{"eu-central", "other"},
+		},
+		{
+			desc:     "Should drop unmapped values",
+			valueMap: &ValueMap{Values: regions, OnUnmapped: UnmappedDrop},
+			values:   []string{"dc-nyc-1", "dc-ams-1"},
+			expected: []string{"eu-west"},
+		},
+		{
+			desc:     "Should remove the header when every value is dropped",
+			valueMap: &ValueMap{Values: regions, OnUnmapped: UnmappedDrop},
+			values:   []string{"dc-nyc-1"},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["X-Datacenter"] = test.values
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			config := &Config{RenameData: []RenameRule{
+				{ExistingHeaderName: "X-Datacenter", NewHeaderName: "X-Region", ValueMap: test.valueMap},
+			}}
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if values := recorder.Header()["X-Region"]; !testEq(values, test.expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", test.expected, values)
+			}
+			if values, ok := recorder.Header()["X-Datacenter"]; ok {
+				t.Errorf("expected X-Datacenter to be removed, got %+v", values)
+			}
+		})
+	}
+}
+
+func TestCompileValueMapErrors(t *testing.T) {
+	tests := []struct {
+		desc     string
+		valueMap *ValueMap
+	}{
+		{desc: "Should reject an empty table", valueMap: &ValueMap{}},
+		{desc: "Should reject a default with drop", valueMap: &ValueMap{Values: map[string]string{"a": "b"}, Default: "c", OnUnmapped: UnmappedDrop}},
+		{desc: "Should reject unknown behaviors", valueMap: &ValueMap{Values: map[string]string{"a": "b"}, OnUnmapped: "fail"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := compileValueMap(test.valueMap); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
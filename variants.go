@@ -0,0 +1,69 @@
+package traefik_header_rename_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RuleVariant holds the rules applied to requests selecting a variant.
+type RuleVariant struct {
+	RenameData        []RenameRule `json:"renameData"`
+	RequestRenameData []RenameRule `json:"requestRenameData"`
+}
+
+// variants selects the rules of a request by the value of a header or cookie.
+type variants struct {
+	header  string
+	cookie  string
+	indexes map[string]*ruleIndexes
+}
+
+// compileVariants compiles and indexes the rules of each variant.
+func (r *renameHeaders) compileVariants(config *Config) (variants, error) {
+	v := variants{header: config.VariantHeader, cookie: config.VariantCookie}
+	if len(config.Variants) == 0 {
+		if v.header != "" || v.cookie != "" {
+			return variants{}, errors.New("variantHeader and variantCookie require variants")
+		}
+		return v, nil
+	}
+	if v.header == "" && v.cookie == "" {
+		return variants{}, errors.New("variants require variantHeader or variantCookie")
+	}
+
+	v.indexes = make(map[string]*ruleIndexes, len(config.Variants))
+	for name, variant := range config.Variants {
+		set, err := CompileRules(variant.RenameData)
+		if err != nil {
+			return variants{}, fmt.Errorf("variant %q: %w", name, err)
+		}
+		requestRules, err := compileRequestRules(variant.RequestRenameData)
+		if err != nil {
+			return variants{}, fmt.Errorf("variant %q: %w", name, err)
+		}
+		if v.indexes[name], err = r.indexRules(set, requestRules); err != nil {
+			return variants{}, fmt.Errorf("variant %q: %w", name, err)
+		}
+	}
+	return v, nil
+}
+
+// rulesFor returns the rules of the variant selected by req, nil if none is.
+// The cookie is only looked at when the header names no variant.
+func (v *variants) rulesFor(req *http.Request) *ruleIndexes {
+	if v.indexes == nil {
+		return nil
+	}
+	if v.header != "" {
+		if indexes, ok := v.indexes[req.Header.Get(v.header)]; ok {
+			return indexes
+		}
+	}
+	if v.cookie != "" {
+		if cookie, err := req.Cookie(v.cookie); err == nil {
+			return v.indexes[cookie.Value]
+		}
+	}
+	return nil
+}
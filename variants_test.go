@@ -0,0 +1,109 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	config := &Config{
+		RenameData:        []RenameRule{{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Id"}},
+		RequestRenameData: []RenameRule{{ExistingHeaderName: "X-Client", NewHeaderName: "X-Client-Id"}},
+		VariantHeader:     "X-Experiment",
+		VariantCookie:     "experiment",
+		Variants: map[string]RuleVariant{
+			"b": {
+				RenameData:        []RenameRule{{ExistingHeaderName: "X-Upstream-Id", NewHeaderName: "X-Request-Id"}},
+				RequestRenameData: []RenameRule{{ExistingHeaderName: "X-Client", NewHeaderName: "X-Caller"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc          string
+		header        string
+		cookie        string
+		expReqHeader  string
+		expRespHeader string
+	}{
+		{desc: "Should apply the default rules without variant", expReqHeader: "X-Client-Id", expRespHeader: "X-Id"},
+		{desc: "Should apply the default rules for unknown variants", header: "c", expReqHeader: "X-Client-Id", expRespHeader: "X-Id"},
+		{desc: "Should select the variant by header", header: "b", expReqHeader: "X-Caller", expRespHeader: "X-Request-Id"},
+		{desc: "Should select the variant by cookie", cookie: "b", expReqHeader: "X-Caller", expRespHeader: "X-Request-Id"},
+		{desc: "Should fall back to the cookie when the header names no variant", header: "c", cookie: "b", expReqHeader: "X-Caller", expRespHeader: "X-Request-Id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var forwarded http.Header
+			next := func(rw http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Clone()
+				rw.Header().Set("X-Upstream-Id", "42")
+				rw.WriteHeader(http.StatusOK)
+			}
+
+			handler, err := New(context.Background(), http.HandlerFunc(next), config, "rewriteHeader")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Client", "web")
+			if test.header != "" {
+				req.Header.Set("X-Experiment", test.header)
+			}
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "experiment", Value: test.cookie})
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			expected := []string{"web"}
+			if values := forwarded[test.expReqHeader]; !testEq(values, expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+			}
+			expected = []string{"42"}
+			if values := recorder.Header()[test.expRespHeader]; !testEq(values, expected) {
+				t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, values)
+			}
+		})
+	}
+}
+
+func TestVariantsErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config *Config
+	}{
+		{
+			desc:   "Should reject variants without selector",
+			config: &Config{Variants: map[string]RuleVariant{"b": {}}},
+		},
+		{
+			desc: "Should reject a selector without variants",
+			config: &Config{
+				RenameData:    []RenameRule{{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar"}},
+				VariantHeader: "X-Experiment",
+			},
+		},
+		{
+			desc: "Should reject invalid variant rules",
+			config: &Config{
+				VariantHeader: "X-Experiment",
+				Variants: map[string]RuleVariant{
+					"b": {RequestRenameData: []RenameRule{{ExistingHeaderName: "X-Foo", NewHeaderName: "X-Bar", StatusCodes: "200"}}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := New(context.Background(), http.NotFoundHandler(), test.config, "rewriteHeader"); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package traefik_header_rename_plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Defaults of AuditWebhook.
+const (
+	defaultAuditBatchSize     = 20
+	defaultAuditQueueSize     = 1000
+	defaultAuditFlushInterval = time.Second
+	defaultAuditRequestID     = "X-Request-Id"
+	// auditTimeout bounds posting one batch of events.
+	auditTimeout = 10 * time.Second
+)
+
+// AuditWebhook posts an event to a webhook whenever one of Headers is
+// renamed, in either direction, or removed by removeHeaders. Events are sent
+// in the background as JSON arrays of up to BatchSize events, at least every
+// FlushInterval. When the webhook cannot keep up and QueueSize events are
+// waiting, new events are dropped rather than delaying traffic, and failed
+// batches are not retried.
+type AuditWebhook struct {
+	URL string `json:"url"`
+	// Headers lists the audited header names, which may contain wildcards.
+	// All headers are audited when it is empty.
+	Headers       []string `json:"headers"`
+	BatchSize     int      `json:"batchSize"`
+	QueueSize     int      `json:"queueSize"`
+	FlushInterval string   `json:"flushInterval"`
+	// RequestIDHeader is the request header identifying requests in events,
+	// X-Request-Id by default.
+	RequestIDHeader string `json:"requestIDHeader"`
+}
+
+// auditEvent is one rename or removal, as posted to the webhook.
+type auditEvent struct {
+	Time       string `json:"time"`
+	Middleware string `json:"middleware"`
+	Direction  string `json:"direction"`
+	Action     string `json:"action"`
+	Rule       string `json:"rule,omitempty"`
+	From       string `json:"from"`
+	To         string `json:"to,omitempty"`
+	RequestID  string `json:"requestId,omitempty"`
+	Host       string `json:"host"`
+	Path       string `json:"path"`
+}
+
+// auditWebhook is an AuditWebhook prepared for use. It starts posting with
+// the first event, until the context of the handler is done.
+type auditWebhook struct {
+	url           string
+	headers       []headerMatcher
+	batchSize     int
+	flushInterval time.Duration
+	requestID     string
+	middleware    string
+	client        *http.Client
+
+	ctx    context.Context
+	start  sync.Once
+	events chan auditEvent
+}
+
+// compileAuditWebhook validates the webhook options.
+func compileAuditWebhook(ctx context.Context, w *AuditWebhook, middleware string) (*auditWebhook, error) {
+	if w == nil {
+		return nil, nil
+	}
+	target, err := url.Parse(w.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("auditWebhook: url %q is not an absolute HTTP URL", w.URL)
+	}
+	if w.BatchSize < 0 || w.QueueSize < 0 {
+		return nil, fmt.Errorf("auditWebhook: batchSize and queueSize cannot be negative")
+	}
+
+	compiled := &auditWebhook{
+		url:           w.URL,
+		batchSize:     w.BatchSize,
+		flushInterval: defaultAuditFlushInterval,
+		requestID:     w.RequestIDHeader,
+		middleware:    middleware,
+		client:        &http.Client{Timeout: auditTimeout},
+		ctx:           ctx,
+	}
+	compiled.headers, err = compileHeaderMatchers(w.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("auditWebhook: %w", err)
+	}
+	if compiled.batchSize == 0 {
+		compiled.batchSize = defaultAuditBatchSize
+	}
+	queueSize := w.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultAuditQueueSize
+	}
+	compiled.events = make(chan auditEvent, queueSize)
+	if w.FlushInterval != "" {
+		compiled.flushInterval, err = time.ParseDuration(w.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("auditWebhook: invalid flushInterval: %w", err)
+		}
+		if compiled.flushInterval <= 0 {
+			return nil, fmt.Errorf("auditWebhook: flushInterval %s must be positive", w.FlushInterval)
+		}
+	}
+	if compiled.requestID == "" {
+		compiled.requestID = defaultAuditRequestID
+	}
+	return compiled, nil
+}
+
+// audits reports whether events about the header name are sent.
+func (w *auditWebhook) audits(name string) bool {
+	if len(w.headers) == 0 {
+		return true
+	}
+	for _, m := range w.headers {
+		if m.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// renamed records the renames of audited headers applied to the request or,
+// status not 0, to its response.
+func (w *auditWebhook) renamed(req *http.Request, status int, renamed []RenamedHeader) {
+	if w == nil {
+		return
+	}
+	for _, rename := range renamed {
+		if w.audits(rename.From) || w.audits(rename.To) {
+			w.enqueue(w.event(req, status, "renamed", rename.Rule, rename.From, rename.To))
+		}
+	}
+}
+
+// removed records the removal of audited headers from the request or, status
+// not 0, from its response.
+func (w *auditWebhook) removed(req *http.Request, status int, names []string) {
+	if w == nil {
+		return
+	}
+	for _, name := range names {
+		if w.audits(name) {
+			w.enqueue(w.event(req, status, "removed", "", name, ""))
+		}
+	}
+}
+
+func (w *auditWebhook) event(req *http.Request, status int, action, rule, from, to string) auditEvent {
+	direction := "response"
+	if status == 0 {
+		direction = "request"
+	}
+	return auditEvent{
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+		Middleware: w.middleware,
+		Direction:  direction,
+		Action:     action,
+		Rule:       rule,
+		From:       from,
+		To:         to,
+		RequestID:  req.Header.Get(w.requestID),
+		Host:       req.Host,
+		Path:       req.URL.Path,
+	}
+}
+
+// enqueue queues an event, dropping it when the queue is full.
+func (w *auditWebhook) enqueue(event auditEvent) {
+	w.start.Do(func() {
+		go w.run()
+	})
+	select {
+	case w.events <- event:
+	default:
+	}
+}
+
+// run posts the queued events in batches until the context is done, when
+// the events still queued are posted a last time.
+func (w *auditWebhook) run() {
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]auditEvent, 0, w.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			w.post(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case <-w.ctx.Done():
+			for {
+				select {
+				case event := <-w.events:
+					batch = append(batch, event)
+					if len(batch) == w.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case event := <-w.events:
+			batch = append(batch, event)
+			if len(batch) == w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// post sends one batch of events. Errors are ignored, the batch is lost.
+func (w *auditWebhook) post(batch []auditEvent) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
+	// The context of the handler may be done already, when the last events
+	// are posted.
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+}
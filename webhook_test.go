@@ -0,0 +1,114 @@
+package traefik_header_rename_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuditWebhook(t *testing.T) {
+	received := make(chan []auditEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var batch []auditEvent
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		received <- batch
+	}))
+	defer server.Close()
+
+	config := &Config{
+		RenameData: []RenameRule{
+			{ExistingHeaderName: "X-User", NewHeaderName: "X-Backend-User"},
+			{ExistingHeaderName: "X-Other", NewHeaderName: "X-Other-New"},
+		},
+		RequestRenameData: []RenameRule{
+			{Name: "identity", ExistingHeaderName: "X-Auth-User", NewHeaderName: "X-User-Id"},
+		},
+		RequestRemoveHeaders: []string{"X-Auth-*", "X-Debug"},
+		AuditWebhook: &AuditWebhook{
+			URL:           server.URL,
+			Headers:       []string{"X-Auth-*", "X-User"},
+			BatchSize:     3,
+			FlushInterval: "1h",
+		},
+	}
+
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-User", "u")
+		rw.Header().Set("X-Other", "o")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, err := New(ctx, http.HandlerFunc(next), config, "rewriteHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("X-Auth-User", "u")
+	req.Header.Set("X-Auth-Role", "admin")
+	req.Header.Set("X-Debug", "1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var batch []auditEvent
+	select {
+	case batch = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch received")
+	}
+
+	expected := []string{
+		"request renamed identity X-Auth-User X-User-Id",
+		"request removed  X-Auth-Role ",
+		"response renamed X-User->X-Backend-User X-User X-Backend-User",
+	}
+	var result []string
+	for _, event := range batch {
+		result = append(result, event.Direction+" "+event.Action+" "+event.Rule+" "+event.From+" "+event.To)
+		if event.RequestID != "abc" || event.Host != "example.com" || event.Path != "/api" || event.Middleware != "rewriteHeader" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+	if !testEq(result, expected) {
+		t.Errorf("Slice arent equals: expect: %+v, result: %+v", expected, result)
+	}
+
+	// Remaining events are posted once the handler is done.
+	req.Header.Set("X-Auth-User", "u")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	cancel()
+	select {
+	case batch = <-received:
+		if len(batch) != 2 {
+			t.Errorf("unexpected batch %+v", batch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch received")
+	}
+}
+
+func TestAuditWebhookErrors(t *testing.T) {
+	for _, webhook := range []*AuditWebhook{
+		{URL: "/relative"},
+		{URL: "ftp://example.com"},
+		{URL: "http://example.com", BatchSize: -1},
+		{URL: "http://example.com", FlushInterval: "soon"},
+		{URL: "http://example.com", FlushInterval: "0s"},
+		{URL: "http://example.com", Headers: []string{""}},
+	} {
+		config := &Config{
+			RenameData:   []RenameRule{{ExistingHeaderName: "X-Old", NewHeaderName: "X-New"}},
+			AuditWebhook: webhook,
+		}
+		if _, err := New(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), config, "rewriteHeader"); err == nil {
+			t.Errorf("%+v: expected an error", webhook)
+		}
+	}
+}